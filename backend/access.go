@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// Api keys and access tiers
+//
+// Clients authenticate with an api key, either as bearer
+// token or in the X-Api-Key header. Only the sha256 of a key
+// is configured. The tier of the key controls the rate limits,
+// if redacted details are shown and the access to admin endpoints.
+//
+// Requests without key have the public tier.
+
+const ACCESS_TIER_PUBLIC = "public"
+
+const API_KEY_HEADER = "X-Api-Key"
+
+type Access struct {
+	Key  string // Name of the api key
+	Tier AccessTierConfig
+}
+
+var publicAccess = &Access{
+	Tier: AccessTierConfig{
+		Name: ACCESS_TIER_PUBLIC,
+	},
+}
+
+type accessContextKey struct{}
+
+type AccessKeys struct {
+	keys  map[string]ApiKeyConfig // by hash
+	tiers map[string]AccessTierConfig
+}
+
+func NewAccessKeys(config AccessConfig) *AccessKeys {
+	keys := make(map[string]ApiKeyConfig)
+	for _, key := range config.Keys {
+		keys[key.Hash] = key
+	}
+
+	return &AccessKeys{
+		keys:  keys,
+		tiers: config.Tiers,
+	}
+}
+
+func hashApiKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// Get the api key from the request
+func requestApiKey(req *http.Request) string {
+	authorization := req.Header.Get("Authorization")
+	if strings.HasPrefix(authorization, "Bearer ") {
+		return strings.TrimSpace(
+			strings.TrimPrefix(authorization, "Bearer "))
+	}
+	return req.Header.Get(API_KEY_HEADER)
+}
+
+// Get the access of the request. Unknown keys are
+// rejected, instead of falling back to public access.
+func (self *AccessKeys) Authenticate(req *http.Request) (*Access, error) {
+	key := requestApiKey(req)
+	if key == "" {
+		return publicAccess, nil
+	}
+
+	apiKey, ok := self.keys[hashApiKey(key)]
+	if !ok {
+		return nil, NewUnauthorizedError("Invalid api key")
+	}
+
+	access := &Access{
+		Key:  apiKey.Name,
+		Tier: self.tiers[apiKey.Tier],
+	}
+	return access, nil
+}
+
+// Attach the access to the request
+func withAccess(req *http.Request, access *Access) *http.Request {
+	ctx := context.WithValue(req.Context(), accessContextKey{}, access)
+	return req.WithContext(ctx)
+}
+
+// Get the access of an authenticated request
+func requestAccess(req *http.Request) *Access {
+	access, ok := req.Context().Value(accessContextKey{}).(*Access)
+	if !ok {
+		return publicAccess
+	}
+	return access
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ecix/alice-lg/backend/api"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func makeAccessConfig() AccessConfig {
+	return AccessConfig{
+		Enabled: true,
+		Tiers: map[string]AccessTierConfig{
+			"partner": AccessTierConfig{
+				Name:          "partner",
+				ExpensiveRate: 1,
+			},
+			"staff": AccessTierConfig{
+				Name:    "staff",
+				Details: true,
+				Admin:   true,
+			},
+		},
+		Keys: []ApiKeyConfig{
+			ApiKeyConfig{
+				Name: "member",
+				Hash: hashApiKey("partner-key"),
+				Tier: "partner",
+			},
+			ApiKeyConfig{
+				Name: "noc",
+				Hash: hashApiKey("staff-key"),
+				Tier: "staff",
+			},
+		},
+	}
+}
+
+func TestAccessKeysAuthenticate(t *testing.T) {
+	keys := NewAccessKeys(makeAccessConfig())
+
+	req := httptest.NewRequest("GET", "/api/status", nil)
+	access, err := keys.Authenticate(req)
+	if err != nil || access.Tier.Name != ACCESS_TIER_PUBLIC {
+		t.Error("Expected public access, got:", access, err)
+	}
+
+	req.Header.Set("Authorization", "Bearer staff-key")
+	access, err = keys.Authenticate(req)
+	if err != nil || access.Key != "noc" || !access.Tier.Admin {
+		t.Error("Expected staff access, got:", access, err)
+	}
+
+	req = httptest.NewRequest("GET", "/api/status", nil)
+	req.Header.Set(API_KEY_HEADER, "partner-key")
+	access, err = keys.Authenticate(req)
+	if err != nil || access.Key != "member" || access.Tier.Name != "partner" {
+		t.Error("Expected partner access, got:", access, err)
+	}
+
+	// Unknown keys are rejected
+	req.Header.Set(API_KEY_HEADER, "staff-key2")
+	_, err = keys.Authenticate(req)
+	if status, _ := apiErrorResponse(err); status != http.StatusUnauthorized {
+		t.Error("Expected 401, got:", status)
+	}
+}
+
+func TestRateLimitsTiers(t *testing.T) {
+	config := makeAccessConfig()
+	limits, err := NewRateLimits(RateLimitConfig{
+		Rate:           1,
+		Burst:          1,
+		ExpensiveRate:  1,
+		ExpensiveBurst: 1,
+	}, config.Tiers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := NewAccessKeys(config)
+	req := httptest.NewRequest("GET", "/api/status", nil)
+	req.Header.Set(API_KEY_HEADER, "partner-key")
+	access, _ := keys.Authenticate(req)
+
+	// The default budget of the tier is unlimited
+	for i := 0; i < 5; i++ {
+		if err := limits.Check(req, access, RATE_LIMIT_DEFAULT); err != nil {
+			t.Error("Expected request", i, "to be allowed:", err)
+		}
+	}
+
+	if err := limits.Check(req, access, RATE_LIMIT_EXPENSIVE); err != nil {
+		t.Error("Expected request to be allowed:", err)
+	}
+	if err := limits.Check(req, access, RATE_LIMIT_EXPENSIVE); err == nil {
+		t.Error("Expected request to be limited")
+	}
+
+	// Public clients of the same address have their own budget
+	if err := limits.Check(req, publicAccess, RATE_LIMIT_EXPENSIVE); err != nil {
+		t.Error("Expected public request to be allowed:", err)
+	}
+}
+
+func TestAdminEndpoint(t *testing.T) {
+	AliceAccessKeys = NewAccessKeys(makeAccessConfig())
+	defer func() { AliceAccessKeys = nil }()
+
+	handle := adminEndpoint(func(req *http.Request, params httprouter.Params) (api.Response, error) {
+		return api.AccessResponse{}, nil
+	})
+
+	expected := []struct {
+		key    string
+		status int
+	}{
+		{"", http.StatusUnauthorized},
+		{"invalid", http.StatusUnauthorized},
+		{"partner-key", http.StatusForbidden},
+		{"staff-key", http.StatusOK},
+	}
+
+	for _, e := range expected {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/api/admin/access", nil)
+		if e.key != "" {
+			req.Header.Set(API_KEY_HEADER, e.key)
+		}
+		handle(res, req, nil)
+		if res.Code != e.status {
+			t.Error("Expected status", e.status, "for key", e.key, "got:", res.Code)
+		}
+	}
+}
+
+func TestRedactorKeepDetails(t *testing.T) {
+	redactor, err := NewRedactor(RedactionConfig{
+		DetailsDeny:    []string{"input_filter"},
+		HideNeighbours: []string{"ID9*"},
+		MaskAddresses:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	details := map[string]interface{}{
+		"input_filter":     "filter_in",
+		"neighbor_address": "10.0.0.1",
+	}
+	redacted := redactor.KeepDetails().RedactDetails(details)
+	if len(redacted) != 2 || redacted["neighbor_address"] != "10.0.0.1" {
+		t.Error("Expected details to be kept:", redacted)
+	}
+
+	if !redactor.KeepDetails().HideNeighbourId("ID99_AS1") {
+		t.Error("Expected neighbour to be hidden")
+	}
+
+	// The redactor is not modified
+	if len(redactor.RedactDetails(details)) != 1 {
+		t.Error("Expected details to be redacted")
+	}
+}
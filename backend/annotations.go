@@ -0,0 +1,41 @@
+package main
+
+import (
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+// Route and neighbour annotations
+//
+// Enrich routes and neighbours with information from
+// the local validation and metadata subsystems.
+// Everything is modified in place.
+
+func annotateRoutes(sourceId int, routes []api.Route) {
+	if AliceRpkiValidator != nil {
+		AliceRpkiValidator.AnnotateRoutes(routes)
+	}
+	if AliceIrrDatabase != nil {
+		AliceIrrDatabase.AnnotateRoutes(sourceId, routes)
+	}
+	if AliceAsnMetadata != nil {
+		AliceAsnMetadata.AnnotateRoutes(routes)
+	}
+	if AliceBogonDetector != nil {
+		AliceBogonDetector.AnnotateRoutes(routes)
+	}
+	if len(AliceConfig.Ui.BgpCommunities) > 0 {
+		AliceConfig.Ui.BgpCommunities.AnnotateRoutes(routes)
+	}
+}
+
+func annotateRoutesResponse(sourceId int, response *api.RoutesResponse) {
+	annotateRoutes(sourceId, response.Imported)
+	annotateRoutes(sourceId, response.Filtered)
+	annotateRoutes(sourceId, response.NotExported)
+}
+
+func annotateNeighbours(neighbours []api.Neighbour) {
+	if AliceAsnMetadata != nil {
+		AliceAsnMetadata.AnnotateNeighbours(neighbours)
+	}
+}
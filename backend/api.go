@@ -1,11 +1,12 @@
 package main
 
 import (
-	"compress/gzip"
-	"encoding/json"
 	"net/http"
 
+	"context"
 	"log"
+	"net"
+	"sort"
 	"strings"
 	"time"
 
@@ -28,56 +29,302 @@ import (
 //     List         /api/routeservers
 //     Status       /api/routeservers/:id/status
 //     Neighbours   /api/routeservers/:id/neighbours
+//     Neighbour    /api/routeservers/:id/neighbours/:neighbourId
 //     Routes       /api/routeservers/:id/neighbours/:neighbourId/routes
+//     IRR Report   /api/routeservers/:id/neighbours/:neighbourId/irr
+//     History      /api/routeservers/:id/neighbours/:neighbourId/history
+//     Route        /api/routeservers/:id/routes/:prefix?neighbour=<neighbourId>
+//     Visibility   /api/routeservers/:id/visibility?target=<neighbourId>
+//                  &prefix=<prefix> and / or &neighbour=<neighbourId>
+//     Matrix       /api/routeservers/:id/visibility/matrix?neighbour=<neighbourId>
+//     Export       /api/routeservers/:id/export?format=<ndjson|csv|mrt>
+//                  &state=<imported|filtered|not_exported>
 //
 //   Querying
 //     LookupPrefix /api/routeservers/:id/lookup/prefix?q=<prefix>
+//                  Optional: &rpki=<valid|invalid|not-found>
+//     LookupBogons /api/lookup/bogons
+//     LookupNeighbours /api/lookup/neighbours?asn=&address=&state=&description=
+//                      &routes_received_min=&routes_received_max=&group=
+//                      &import_limit_usage_min=
+//     LookupImportLimits /api/lookup/import-limits?threshold=<percent>&group=
+//     Compare      /api/compare?a=<id>&b=<id>
+//                  /api/compare?group=<group>
+//
+//   Export
+//     The routes, neighbours and lookup endpoints support
+//     &format=<json|csv|ndjson> or the Accept header.
+//
+//   Streaming
+//     Events       /api/events?source=<id>&asn=<asn>
+//
+//   Admin
+//     Access       /api/admin/access
+//     Metrics      /metrics (admin key without internal listener)
+//
+//   Caching
+//     Responses carry an ETag and Cache-Control matching the
+//     upstream ttl, lookups are validated by the last refresh
+//     of the stores. If-None-Match and If-Modified-Since are
+//     answered with 304 Not Modified.
+//
+// The versioned api v2 is available at /api/v2,
+// see api_v2.go and /api/v2/openapi.json. It covers the
+// routeservers list, status, neighbours, neighbour, routes
+// and the prefix and neighbours lookups. Visibility, matrix,
+// compare, bogons, import limits, history, route details,
+// IRR report, export and events are only available in v1.
 //
 
 type apiEndpoint func(*http.Request, httprouter.Params) (api.Response, error)
 
 // Wrap handler for access controll, throtteling and compression
 func endpoint(wrapped apiEndpoint) httprouter.Handle {
+	return guard(RATE_LIMIT_DEFAULT, makeEndpoint(wrapped))
+}
+
+// Endpoints querying the routeservers or searching
+// all routes have a separate budget
+func expensiveEndpoint(wrapped apiEndpoint) httprouter.Handle {
+	return guard(RATE_LIMIT_EXPENSIVE, makeEndpoint(wrapped))
+}
+
+// Lookups are answered from the stores
+func storeEndpoint(wrapped apiEndpoint) httprouter.Handle {
+	return guard(RATE_LIMIT_EXPENSIVE, makeStoreEndpoint(wrapped))
+}
+
+// Admin endpoints require a key with an admin tier
+func adminEndpoint(wrapped apiEndpoint) httprouter.Handle {
+	return guard(RATE_LIMIT_DEFAULT, requireAdmin(makeEndpoint(wrapped)))
+}
+
+// Authenticate the client and reject requests
+// exceeding the budget of the client
+func guard(budget int, handle httprouter.Handle) httprouter.Handle {
+	return guardWith(budget, writeErrorResponse, handle)
+}
+
+func guardWith(
+	budget int,
+	writeError func(http.ResponseWriter, error),
+	handle httprouter.Handle,
+) httprouter.Handle {
 	return func(res http.ResponseWriter,
 		req *http.Request,
 		params httprouter.Params) {
 
+		access := publicAccess
+		if AliceAccessKeys != nil {
+			var err error
+			access, err = AliceAccessKeys.Authenticate(req)
+			if err != nil {
+				writeError(res, err)
+				return
+			}
+		}
+
+		if AliceRateLimits != nil {
+			err := AliceRateLimits.Check(req, access, budget)
+			if err != nil {
+				writeError(res, err)
+				return
+			}
+		}
+
+		handle(res, withAccess(req, access), params)
+	}
+}
+
+func requireAdmin(handle httprouter.Handle) httprouter.Handle {
+	return func(res http.ResponseWriter,
+		req *http.Request,
+		params httprouter.Params) {
+
+		access := requestAccess(req)
+		if access.Key == "" {
+			writeErrorResponse(res, NewUnauthorizedError("Api key required"))
+			return
+		}
+		if !access.Tier.Admin {
+			writeErrorResponse(res, NewForbiddenError(
+				"Access tier %s may not use admin endpoints", access.Tier.Name))
+			return
+		}
+
+		handle(res, req, params)
+	}
+}
+
+func makeEndpoint(wrapped apiEndpoint) httprouter.Handle {
+	return makeEndpointWith(wrapped, false)
+}
+
+// Endpoints answered from the stores are validated by the
+// last refresh and their encoded responses are cached.
+func makeStoreEndpoint(wrapped apiEndpoint) httprouter.Handle {
+	return makeEndpointWith(wrapped, true)
+}
+
+func makeEndpointWith(wrapped apiEndpoint, stored bool) httprouter.Handle {
+	return func(res http.ResponseWriter,
+		req *http.Request,
+		params httprouter.Params) {
+
+		format, explicit, err := validateExportFormat(req)
+		if err != nil {
+			writeErrorResponse(res, err)
+			return
+		}
+
+		gzipped := acceptsGzip(req)
+		key := responseCacheKey(req, format)
+
+		// The response will not change until the stores are
+		// refreshed, so conditional requests and cached
+		// responses are answered without the handler.
+		version := time.Time{}
+		if stored {
+			version = storesRefreshedAt()
+		}
+		if !version.IsZero() {
+			validators := makeStoreValidators(key, version)
+			if notModified(req, validators) {
+				writeCacheHeaders(res, validators)
+				res.WriteHeader(http.StatusNotModified)
+				return
+			}
+			if AliceResponseCache != nil {
+				cached, ok := AliceResponseCache.Get(key, version)
+				if ok {
+					writeCacheHeaders(res, cached.Validators)
+					writeJsonPayload(res, cached.Payload, cached.Gzipped)
+					return
+				}
+			}
+		}
+
 		// Get result from handler
-		result, err := wrapped(req, params)
+		var result api.Response
+		err = withRequestTimeout(req, func(req *http.Request) error {
+			var err error
+			result, err = wrapped(req, params)
+			return err
+		})
+
+		// Remove sensitive information
+		if err == nil {
+			result, err = redactResponse(req, result)
+		}
+
 		if err != nil {
-			result = api.ErrorResponse{
-				Error: err.Error(),
+			writeErrorResponse(res, err)
+			return
+		}
+
+		// Export as csv or ndjson, fall back to json
+		// if the format was not requested explicitly.
+		var table *exportTable
+		if format != EXPORT_FORMAT_JSON {
+			var ok bool
+			table, ok = makeExportTable(result)
+			if !ok && explicit {
+				writeErrorResponse(res, NewInvalidParamError(
+					"Format %s is not supported by this endpoint.", format))
+				return
+			}
+		}
+
+		validators, ok := makeUpstreamValidators(key, result)
+		if !version.IsZero() {
+			validators, ok = makeStoreValidators(key, version), true
+		}
+		if ok {
+			writeCacheHeaders(res, validators)
+			if notModified(req, validators) {
+				res.WriteHeader(http.StatusNotModified)
+				return
 			}
-			payload, _ := json.Marshal(result)
-			http.Error(res, string(payload), http.StatusInternalServerError)
+		}
+
+		if table != nil {
+			writeExport(res, req, format, table)
 			return
 		}
 
-		// Encode json
-		payload, err := json.Marshal(result)
+		payload, err := encodeJsonResponse(result, gzipped)
 		if err != nil {
-			msg := "Could not encode result as json"
-			http.Error(res, msg, http.StatusInternalServerError)
-			log.Println(err)
-			log.Println("This is most likely due to an older version of go.")
-			log.Println("Consider upgrading to golang > 1.8")
+			writeJsonError(res, err)
 			return
 		}
+		if !version.IsZero() && AliceResponseCache != nil {
+			AliceResponseCache.Set(key, version, &cachedResponse{
+				Validators: validators,
+				Payload:    payload,
+				Gzipped:    gzipped,
+			})
+		}
 
-		// Set response header
-		res.Header().Set("Content-Type", "application/json")
+		writeJsonPayload(res, payload, gzipped)
+	}
+}
 
-		// Check if compression is supported
-		if strings.Contains(req.Header.Get("Accept-Encoding"), "gzip") {
-			// Compress response
-			res.Header().Set("Content-Encoding", "gzip")
-			gz := gzip.NewWriter(res)
-			defer gz.Close()
-			gz.Write(payload)
-		} else {
-			res.Write(payload) // Fall back to uncompressed response
-		}
+// Run the handler with the request timeout: Requests to the
+// sources are canceled with the context of the request, so a
+// slow source results in an error response before the write
+// timeout closes the connection.
+func withRequestTimeout(req *http.Request, handle func(*http.Request) error) error {
+	if AliceConfig == nil || AliceConfig.Server.RequestTimeout <= 0 {
+		return handle(req)
 	}
+
+	ctx, cancel := context.WithTimeout(req.Context(), AliceConfig.Server.RequestTimeout)
+	defer cancel()
+
+	err := handle(req.WithContext(ctx))
+	if ctx.Err() == context.DeadlineExceeded {
+		return NewRequestTimeoutError() // the result is incomplete
+	}
+	return err
+}
+
+// Get the redactor for the access of the request,
+// nil if nothing is redacted.
+func requestRedactor(req *http.Request) *Redactor {
+	if AliceRedactor == nil {
+		return nil
+	}
+	if requestAccess(req).Tier.Details {
+		return AliceRedactor.KeepDetails()
+	}
+	return AliceRedactor
+}
+
+func redactResponse(req *http.Request, result api.Response) (api.Response, error) {
+	redactor := requestRedactor(req)
+	if redactor == nil {
+		return result, nil
+	}
+	return redactor.RedactResponse(result)
+}
+
+// Encode the result as json, compress if supported
+func writeJsonResponse(res http.ResponseWriter, req *http.Request, result interface{}) {
+	gzipped := acceptsGzip(req)
+	payload, err := encodeJsonResponse(result, gzipped)
+	if err != nil {
+		writeJsonError(res, err)
+		return
+	}
+	writeJsonPayload(res, payload, gzipped)
+}
+
+func writeJsonError(res http.ResponseWriter, err error) {
+	msg := "Could not encode result as json"
+	http.Error(res, msg, http.StatusInternalServerError)
+	log.Println(err)
+	log.Println("This is most likely due to an older version of go.")
+	log.Println("Consider upgrading to golang > 1.8")
 }
 
 // Register api endpoints
@@ -94,15 +341,69 @@ func apiRegisterEndpoints(router *httprouter.Router) error {
 		endpoint(apiStatus))
 	router.GET("/api/routeservers/:id/neighbours",
 		endpoint(apiNeighboursList))
+	router.GET("/api/routeservers/:id/neighbours/:neighbourId",
+		expensiveEndpoint(apiNeighbourShow))
 	router.GET("/api/routeservers/:id/neighbours/:neighbourId/routes",
-		endpoint(apiRoutesList))
+		expensiveEndpoint(apiRoutesList))
+
+	// Export visibility
+	router.GET("/api/routeservers/:id/visibility",
+		expensiveEndpoint(apiExportVisibility))
+	router.GET("/api/routeservers/:id/visibility/matrix",
+		expensiveEndpoint(apiExportMatrix))
+
+	// IRR compliance
+	if AliceConfig.Irr.Enabled == true {
+		router.GET("/api/routeservers/:id/neighbours/:neighbourId/irr",
+			expensiveEndpoint(apiIrrReport))
+	}
 
 	// Querying
 	if AliceConfig.Server.EnablePrefixLookup == true {
 		router.GET("/api/lookup/prefix",
-			endpoint(apiLookupPrefixGlobal))
+			storeEndpoint(apiLookupPrefixGlobal))
+		router.GET("/api/lookup/neighbours",
+			storeEndpoint(apiLookupNeighboursGlobal))
+		router.GET("/api/lookup/import-limits",
+			storeEndpoint(apiLookupImportLimitsGlobal))
+		router.GET("/api/compare",
+			storeEndpoint(apiCompareRouteservers))
+
+		// Candidate paths are taken from the routes store
+		router.GET("/api/routeservers/:id/routes/*prefix",
+			expensiveEndpoint(apiRouteShow))
+
+		// The history is recorded by the neighbours store
+		router.GET("/api/routeservers/:id/neighbours/:neighbourId/history",
+			endpoint(apiNeighbourHistory))
+
+		// Bulk export of the routes store
+		if AliceConfig.Export.Enabled == true {
+			router.GET("/api/routeservers/:id/export",
+				guard(RATE_LIMIT_EXPENSIVE, apiRoutesExport))
+		}
+
+		// Events are published by the stores
+		router.GET("/api/events",
+			guard(RATE_LIMIT_DEFAULT, apiEventsStream))
+
+		if AliceConfig.Bogons.Enabled == true {
+			router.GET("/api/lookup/bogons",
+				storeEndpoint(apiLookupBogonsGlobal))
+		}
 	}
 
+	// Api v2
+	return apiV2RegisterEndpoints(router)
+}
+
+// Register admin endpoints, these are served on the
+// internal listener if configured.
+func apiRegisterAdminEndpoints(router *httprouter.Router) error {
+	if AliceConfig.Access.Enabled == true {
+		router.GET("/api/admin/access",
+			adminEndpoint(apiAccessShow))
+	}
 	return nil
 }
 
@@ -129,11 +430,50 @@ func apiConfigShow(_req *http.Request, _params httprouter.Params) (api.Response,
 		NoexportReasons: SerializeReasons(
 			AliceConfig.Ui.RoutesNoexports.Reasons),
 		RoutesColumns:       AliceConfig.Ui.RoutesColumns,
+		BgpCommunities:      AliceConfig.Ui.BgpCommunities.Serialize(),
 		PrefixLookupEnabled: AliceConfig.Server.EnablePrefixLookup,
+		RpkiEnabled:         AliceConfig.Rpki.Enabled,
+		IrrEnabled:          AliceConfig.Irr.Enabled,
+		BogonsEnabled:       AliceConfig.Bogons.Enabled,
+		AsnMetadataEnabled:  AliceConfig.AsnMetadata.Enabled,
 	}
 	return result, nil
 }
 
+// Handle Access Endpoint: List tiers and the names of
+// the api keys, the hashes are not exposed.
+func apiAccessShow(_req *http.Request, _params httprouter.Params) (api.Response, error) {
+	tiers := []api.AccessTier{}
+	for _, tier := range AliceConfig.Access.Tiers {
+		tiers = append(tiers, api.AccessTier{
+			Name:           tier.Name,
+			Rate:           tier.Rate,
+			Burst:          tier.Burst,
+			ExpensiveRate:  tier.ExpensiveRate,
+			ExpensiveBurst: tier.ExpensiveBurst,
+			Details:        tier.Details,
+			Admin:          tier.Admin,
+		})
+	}
+	sort.Slice(tiers, func(i, j int) bool {
+		return tiers[i].Name < tiers[j].Name
+	})
+
+	keys := []api.ApiKey{}
+	for _, key := range AliceConfig.Access.Keys {
+		keys = append(keys, api.ApiKey{
+			Name: key.Name,
+			Tier: key.Tier,
+		})
+	}
+
+	response := api.AccessResponse{
+		Tiers: tiers,
+		Keys:  keys,
+	}
+	return response, nil
+}
+
 // Handle Routeservers List
 func apiRouteserversList(_req *http.Request, _params httprouter.Params) (api.Response, error) {
 	// Get list of sources from config,
@@ -142,8 +482,9 @@ func apiRouteserversList(_req *http.Request, _params httprouter.Params) (api.Res
 	sources := AliceConfig.Sources
 	for _, source := range sources {
 		routeservers = append(routeservers, api.Routeserver{
-			Id:   source.Id,
-			Name: source.Name,
+			Id:    source.Id,
+			Name:  source.Name,
+			Group: source.Group,
 		})
 	}
 
@@ -156,37 +497,393 @@ func apiRouteserversList(_req *http.Request, _params httprouter.Params) (api.Res
 }
 
 // Handle status
-func apiStatus(_req *http.Request, params httprouter.Params) (api.Response, error) {
+func apiStatus(req *http.Request, params httprouter.Params) (api.Response, error) {
 	rsId, err := validateSourceId(params.ByName("id"))
 	if err != nil {
 		return nil, err
 	}
 	source := AliceConfig.Sources[rsId].getInstance()
-	result, err := source.Status()
+	result, err := source.Status(req.Context())
 	return result, err
 }
 
 // Handle get neighbours on routeserver
-func apiNeighboursList(_req *http.Request, params httprouter.Params) (api.Response, error) {
+func apiNeighboursList(req *http.Request, params httprouter.Params) (api.Response, error) {
 	rsId, err := validateSourceId(params.ByName("id"))
 	if err != nil {
 		return nil, err
 	}
 	source := AliceConfig.Sources[rsId].getInstance()
-	result, err := source.Neighbours()
-	return result, err
+	result, err := source.Neighbours(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	annotateNeighbours(result.Neighbours)
+
+	// Add RPKI invalid routes from the local store. The
+	// counts are omitted until the store was refreshed.
+	if AliceRpkiValidator != nil && AliceRoutesStore != nil &&
+		AliceConfig.Server.EnablePrefixLookup {
+		if _, refresh := AliceRoutesStore.RoutesAt(rsId); !refresh.IsZero() {
+			invalid := AliceRoutesStore.RpkiInvalidCountsAt(rsId)
+			for i, neighbour := range result.Neighbours {
+				count := invalid[neighbour.Id]
+				result.Neighbours[i].RoutesRpkiInvalid = &count
+			}
+		}
+	}
+
+	return result, nil
 }
 
 // Handle routes
-func apiRoutesList(_req *http.Request, params httprouter.Params) (api.Response, error) {
+func apiRoutesList(req *http.Request, params httprouter.Params) (api.Response, error) {
 	rsId, err := validateSourceId(params.ByName("id"))
 	if err != nil {
 		return nil, err
 	}
-	neighbourId := params.ByName("neighbourId")
+	neighbourId, err := validateNeighbourId(rsId, params.ByName("neighbourId"))
+	if err != nil {
+		return nil, err
+	}
 	source := AliceConfig.Sources[rsId].getInstance()
-	result, err := source.Routes(neighbourId)
-	return result, err
+	result, err := source.Routes(req.Context(), neighbourId)
+	if err != nil {
+		return nil, err
+	}
+
+	annotateRoutesResponse(rsId, &result)
+
+	return result, nil
+}
+
+// Handle neighbour details
+func apiNeighbourShow(req *http.Request, params httprouter.Params) (api.Response, error) {
+	rsId, err := validateSourceId(params.ByName("id"))
+	if err != nil {
+		return nil, err
+	}
+	neighbourId, err := validateNeighbourId(rsId, params.ByName("neighbourId"))
+	if err != nil {
+		return nil, err
+	}
+
+	neighbour, err := lookupNeighbour(req.Context(), rsId, neighbourId)
+	if err != nil {
+		return nil, err
+	}
+	neighbours := []api.Neighbour{neighbour}
+	annotateNeighbours(neighbours)
+	neighbour = neighbours[0]
+
+	source := AliceConfig.Sources[rsId].getInstance()
+	routes, err := source.Routes(req.Context(), neighbourId)
+	if err != nil {
+		return nil, err
+	}
+	annotateRoutesResponse(rsId, &routes)
+
+	response := api.NeighbourResponse{
+		Api:       routes.Api,
+		Neighbour: neighbour,
+		Stats: makeNeighbourRoutesStats(
+			routes, AliceConfig.Ui.RoutesRejections),
+	}
+
+	return response, nil
+}
+
+// Handle route details: Explain all paths of a prefix
+// and optionally the export to a neighbour.
+func apiRouteShow(req *http.Request, params httprouter.Params) (api.Response, error) {
+	rsId, err := validateSourceId(params.ByName("id"))
+	if err != nil {
+		return nil, err
+	}
+
+	// The prefix is a catch all parameter: /10.23.42.0/24
+	prefix := strings.TrimPrefix(params.ByName("prefix"), "/")
+	if _, _, err := net.ParseCIDR(prefix); err != nil {
+		return nil, NewInvalidParamError("Invalid prefix: %s", prefix)
+	}
+
+	apiStatus, paths := AliceRoutesStore.RoutePathsAt(rsId, prefix, AliceConfig.Ui)
+
+	config := AliceConfig.Sources[rsId]
+	response := api.RouteDetailResponse{
+		Api: apiStatus,
+		Routeserver: api.Routeserver{
+			Id:    config.Id,
+			Name:  config.Name,
+			Group: config.Group,
+		},
+		Prefix: prefix,
+		Paths:  paths,
+	}
+
+	neighbourId := req.URL.Query().Get("neighbour")
+	if neighbourId == "" {
+		return response, nil
+	}
+	neighbourId, err = validateNeighbourId(rsId, neighbourId)
+	if err != nil {
+		return nil, err
+	}
+
+	// The route server reports the routes not exported
+	// to a neighbour with a reason.
+	neighbour, err := lookupNeighbour(req.Context(), rsId, neighbourId)
+	if err != nil {
+		return nil, err
+	}
+	routes, err := config.getInstance().Routes(req.Context(), neighbourId)
+	if err != nil {
+		return nil, err
+	}
+	annotateRoutesResponse(rsId, &routes)
+
+	notExported := makeRoutePaths(
+		config, prefix, "not_exported", routes.NotExported, AliceConfig.Ui)
+
+	// A prefix is exported if there is a best path,
+	// which is not withheld from the neighbour.
+	exported := false
+	for _, path := range paths {
+		if path.State == "imported" && path.Primary {
+			exported = len(notExported) == 0
+		}
+	}
+
+	response.Paths = append(response.Paths, notExported...)
+	response.Neighbour = &neighbour
+	response.Exported = &exported
+
+	return response, nil
+}
+
+// Handle export visibility: Are the routes of a prefix
+// or neighbour exported to the target?
+func apiExportVisibility(req *http.Request, params httprouter.Params) (api.Response, error) {
+	rsId, err := validateSourceId(params.ByName("id"))
+	if err != nil {
+		return nil, err
+	}
+	targetId, err := validateQueryString(req, "target")
+	if err != nil {
+		return nil, err
+	}
+	targetId, err = validateNeighbourId(rsId, targetId)
+	if err != nil {
+		return nil, err
+	}
+
+	query := req.URL.Query()
+	prefix := query.Get("prefix")
+	neighbourId := query.Get("neighbour")
+	if prefix == "" && neighbourId == "" {
+		return nil, NewInvalidParamError("Query param prefix or neighbour is missing.")
+	}
+	if neighbourId != "" {
+		neighbourId, err = validateNeighbourId(rsId, neighbourId)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if prefix != "" {
+		if _, _, err := net.ParseCIDR(prefix); err != nil {
+			return nil, NewInvalidParamError("Invalid prefix: %s", prefix)
+		}
+	}
+
+	config := AliceConfig.Sources[rsId]
+	source := config.getInstance()
+
+	target, err := lookupNeighbour(req.Context(), rsId, targetId)
+	if err != nil {
+		return nil, err
+	}
+	target.Details = nil
+
+	// Get the announced routes: Either from the neighbour
+	// or all paths of the prefix from the routes store.
+	routes := []api.Route{}
+	if neighbourId != "" {
+		result, err := source.Routes(req.Context(), neighbourId)
+		if err != nil {
+			return nil, err
+		}
+		for _, route := range result.Imported {
+			if prefix == "" || route.Network == prefix {
+				routes = append(routes, route)
+			}
+		}
+	} else {
+		if AliceConfig.Server.EnablePrefixLookup == false {
+			return nil, NewInvalidParamError("Prefix lookup is disabled, query param neighbour is required.")
+		}
+		routes = AliceRoutesStore.ImportedRoutesAt(rsId, prefix)
+	}
+
+	noexport, err := AliceNoexportsCache.RoutesNotExported(req.Context(), source, rsId, targetId)
+	if err != nil {
+		return nil, err
+	}
+
+	response := api.ExportVisibilityResponse{
+		Api: noexport.Api,
+		Routeserver: api.Routeserver{
+			Id:    config.Id,
+			Name:  config.Name,
+			Group: config.Group,
+		},
+		Target: target,
+		Routes: exportVisibility(
+			routes, noexport.NotExported, AliceConfig.Ui.RoutesNoexports),
+	}
+
+	return response, nil
+}
+
+// Handle export matrix: Which neighbours do not
+// receive the routes of a neighbour?
+func apiExportMatrix(req *http.Request, params httprouter.Params) (api.Response, error) {
+	rsId, err := validateSourceId(params.ByName("id"))
+	if err != nil {
+		return nil, err
+	}
+	neighbourId, err := validateQueryString(req, "neighbour")
+	if err != nil {
+		return nil, err
+	}
+	neighbourId, err = validateNeighbourId(rsId, neighbourId)
+	if err != nil {
+		return nil, err
+	}
+
+	config := AliceConfig.Sources[rsId]
+	source := config.getInstance()
+
+	neighbour, err := lookupNeighbour(req.Context(), rsId, neighbourId)
+	if err != nil {
+		return nil, err
+	}
+	neighbour.Details = nil
+
+	targets, err := source.Neighbours(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	response := api.ExportMatrixResponse{
+		Api: targets.Api,
+		Routeserver: api.Routeserver{
+			Id:    config.Id,
+			Name:  config.Name,
+			Group: config.Group,
+		},
+		Neighbour: neighbour,
+		Targets: exportMatrix(req.Context(), source, rsId, neighbourId,
+			targets.Neighbours, AliceConfig.Ui.RoutesNoexports),
+	}
+
+	return response, nil
+}
+
+// Get a neighbour from the local store,
+// fall back to querying the source
+func lookupNeighbour(ctx context.Context, rsId int, neighbourId string) (api.Neighbour, error) {
+	neighbour := AliceNeighboursStore.GetNeighbourAt(rsId, neighbourId)
+	if neighbour.Id != "" {
+		return neighbour, nil
+	}
+
+	source := AliceConfig.Sources[rsId].getInstance()
+	result, err := source.Neighbours(ctx)
+	if err != nil {
+		return api.Neighbour{}, err
+	}
+	for _, neighbour := range result.Neighbours {
+		if neighbour.Id == neighbourId {
+			return neighbour, nil
+		}
+	}
+
+	return api.Neighbour{}, NewNotFoundError("Neighbour not found: %s", neighbourId)
+}
+
+// Handle neighbour state history
+func apiNeighbourHistory(_req *http.Request, params httprouter.Params) (api.Response, error) {
+	rsId, err := validateSourceId(params.ByName("id"))
+	if err != nil {
+		return nil, err
+	}
+	neighbourId, err := validateNeighbourId(rsId, params.ByName("neighbourId"))
+	if err != nil {
+		return nil, err
+	}
+
+	history, ok := AliceNeighboursStore.GetNeighbourHistoryAt(rsId, neighbourId)
+	if !ok {
+		return nil, NewNotFoundError("No history for neighbour: %s", neighbourId)
+	}
+
+	return history, nil
+}
+
+// Handle IRR compliance report for a neighbour
+func apiIrrReport(req *http.Request, params httprouter.Params) (api.Response, error) {
+	rsId, err := validateSourceId(params.ByName("id"))
+	if err != nil {
+		return nil, err
+	}
+	neighbourId, err := validateNeighbourId(rsId, params.ByName("neighbourId"))
+	if err != nil {
+		return nil, err
+	}
+	neighbour, err := lookupNeighbour(req.Context(), rsId, neighbourId)
+	if err != nil {
+		return nil, err
+	}
+
+	source := AliceConfig.Sources[rsId].getInstance()
+	routes, err := source.Routes(req.Context(), neighbourId)
+	if err != nil {
+		return nil, err
+	}
+
+	asSet := AliceIrrDatabase.AsSetForNeighbour(neighbour.Asn)
+	report := api.IrrReportResponse{
+		Api:          routes.Api,
+		NeighbourId:  neighbourId,
+		Asn:          neighbour.Asn,
+		AsSet:        asSet,
+		AsSetMembers: len(AliceIrrDatabase.ExpandAsSet(asSet)),
+
+		OriginNotInAsSet: []api.Route{},
+		NoRouteObject:    []api.Route{},
+	}
+
+	received := make([]api.Route, 0, len(routes.Imported)+len(routes.Filtered))
+	received = append(received, routes.Imported...)
+	received = append(received, routes.Filtered...)
+	for _, route := range received {
+		check := AliceIrrDatabase.Check(route, neighbour.Asn)
+		route.Irr = &check
+
+		if !check.OriginInAsSet {
+			report.OriginNotInAsSet = append(report.OriginNotInAsSet, route)
+		}
+		if !check.RouteObject {
+			report.NoRouteObject = append(report.NoRouteObject, route)
+		}
+		if check.OriginInAsSet && check.RouteObject {
+			report.CompliantRoutes += 1
+		}
+	}
+	report.TotalRoutes = len(received)
+
+	return report, nil
 }
 
 // Handle global lookup
@@ -203,7 +900,13 @@ func apiLookupPrefixGlobal(req *http.Request, params httprouter.Params) (api.Res
 	}
 
 	// Get pagination params
-	limit, offset, err := validatePaginationParams(req, 50, 0)
+	limit, offset, err := validateExportPaginationParams(req, 50, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// Optional: Filter by RPKI state
+	rpkiState, err := validateRpkiStateParam(req)
 	if err != nil {
 		return nil, err
 	}
@@ -225,17 +928,61 @@ func apiLookupPrefixGlobal(req *http.Request, params httprouter.Params) (api.Res
 		neighbours := AliceNeighboursStore.LookupNeighbours(q)
 		routes = AliceRoutesStore.LookupPrefixForNeighbours(neighbours)
 	}
+	sortLookupRoutes(routes)
+
+	if rpkiState != "" {
+		routes = filterLookupRoutesByRpkiState(routes, rpkiState)
+	}
+
+	// Hide routes before paginating
+	if redactor := requestRedactor(req); redactor != nil {
+		routes = redactor.RedactLookupRoutes(routes)
+	}
 
 	// Paginate result
 	totalRoutes := len(routes)
-	cap := offset + limit
-	if cap > totalRoutes {
-		cap = totalRoutes
+	offset, end := paginate(totalRoutes, limit, offset)
+
+	queryDuration := time.Since(t0)
+	response := api.RoutesLookupResponseGlobal{
+		Routes: routes[offset:end],
+
+		TotalRoutes: totalRoutes,
+		Limit:       limit,
+		Offset:      offset,
+
+		Time: float64(queryDuration) / 1000.0 / 1000.0, // nano -> micro -> milli
+	}
+
+	return response, nil
+}
+
+// Handle global bogons lookup: List all imported
+// routes with bogon prefixes or ASNs
+func apiLookupBogonsGlobal(req *http.Request, params httprouter.Params) (api.Response, error) {
+	// Get pagination params
+	limit, offset, err := validateExportPaginationParams(req, 50, 0)
+	if err != nil {
+		return nil, err
 	}
 
+	// Measure response time
+	t0 := time.Now()
+
+	routes := AliceRoutesStore.LookupBogons(AliceBogonDetector)
+
+	// Hide routes before paginating
+	if redactor := requestRedactor(req); redactor != nil {
+		routes = redactor.RedactLookupRoutes(routes)
+	}
+
+	// Paginate result
+	totalRoutes := len(routes)
+	offset, end := paginate(totalRoutes, limit, offset)
+
 	queryDuration := time.Since(t0)
 	response := api.RoutesLookupResponseGlobal{
-		Routes: routes[offset:cap],
+		Routes: routes[offset:end],
 
 		TotalRoutes: totalRoutes,
 		Limit:       limit,
@@ -246,3 +993,135 @@ func apiLookupPrefixGlobal(req *http.Request, params httprouter.Params) (api.Res
 
 	return response, nil
 }
+
+// Handle global neighbours lookup with structured filters
+func apiLookupNeighboursGlobal(req *http.Request, params httprouter.Params) (api.Response, error) {
+	query, err := validateNeighboursQuery(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get pagination params
+	limit, offset, err := validateExportPaginationParams(req, 50, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// Measure response time
+	t0 := time.Now()
+
+	neighbours := AliceNeighboursStore.FilterNeighbours(query)
+
+	// Hide neighbours before paginating
+	if redactor := requestRedactor(req); redactor != nil {
+		neighbours = redactor.RedactLookupNeighbours(neighbours)
+	}
+
+	// Paginate result
+	totalNeighbours := len(neighbours)
+	offset, end := paginate(totalNeighbours, limit, offset)
+
+	queryDuration := time.Since(t0)
+	response := api.NeighboursLookupResponseGlobal{
+		Neighbours: neighbours[offset:end],
+
+		TotalNeighbours: totalNeighbours,
+		Limit:           limit,
+		Offset:          offset,
+
+		Time: float64(queryDuration) / 1000.0 / 1000.0, // nano -> micro -> milli
+	}
+
+	return response, nil
+}
+
+// Handle lookup of neighbours close to their import limit
+func apiLookupImportLimitsGlobal(req *http.Request, params httprouter.Params) (api.Response, error) {
+	threshold, err := validateIntParam(req, "threshold",
+		AliceConfig.Events.ImportLimitThreshold)
+	if err != nil {
+		return nil, err
+	}
+	if threshold <= 0 {
+		return nil, NewInvalidParamError("Query param threshold must be a positive percentage.")
+	}
+
+	// Get pagination params
+	limit, offset, err := validateExportPaginationParams(req, 50, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	// Measure response time
+	t0 := time.Now()
+
+	query := NeighboursQuery{
+		Group:               req.URL.Query().Get("group"),
+		MaxRoutesReceived:   -1,
+		MinImportLimitUsage: threshold,
+	}
+	neighbours := AliceNeighboursStore.FilterNeighbours(query)
+
+	// Closest to the limit first
+	sort.SliceStable(neighbours, func(i, j int) bool {
+		return neighbours[i].ImportLimitUsage > neighbours[j].ImportLimitUsage
+	})
+
+	// Hide neighbours before paginating
+	if redactor := requestRedactor(req); redactor != nil {
+		neighbours = redactor.RedactLookupNeighbours(neighbours)
+	}
+
+	// Paginate result
+	totalNeighbours := len(neighbours)
+	offset, end := paginate(totalNeighbours, limit, offset)
+
+	queryDuration := time.Since(t0)
+	response := api.NeighboursLookupResponseGlobal{
+		Neighbours: neighbours[offset:end],
+
+		TotalNeighbours: totalNeighbours,
+		Limit:           limit,
+		Offset:          offset,
+
+		Time: float64(queryDuration) / 1000.0 / 1000.0, // nano -> micro -> milli
+	}
+
+	return response, nil
+}
+
+// Handle comparison of route servers
+func apiCompareRouteservers(req *http.Request, params httprouter.Params) (api.Response, error) {
+	pairs, err := validateCompareSources(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Measure response time
+	t0 := time.Now()
+
+	// Sources not refreshed yet would show up as
+	// missing all routes of the other source.
+	for _, pair := range pairs {
+		for _, sourceId := range pair {
+			if _, refresh := AliceRoutesStore.RoutesAt(sourceId); refresh.IsZero() {
+				return nil, NewNotReadyError(
+					"Routes of source %d are not available yet", sourceId)
+			}
+		}
+	}
+
+	comparisons := []api.RoutesComparison{}
+	for _, pair := range pairs {
+		comparisons = append(comparisons,
+			AliceRoutesStore.CompareAt(pair[0], pair[1]))
+	}
+
+	queryDuration := time.Since(t0)
+	response := api.RoutesComparisonResponse{
+		Comparisons: comparisons,
+		Time:        float64(queryDuration) / 1000.0 / 1000.0, // nano -> micro -> milli
+	}
+
+	return response, nil
+}
@@ -13,6 +13,11 @@ type Details map[string]interface{}
 // Error Handling
 type ErrorResponse struct {
 	Error string `json:"error"`
+	Code  string `json:"code"` // e.g. invalid_param, not_found
+
+	// Failures of a source
+	SourceId   *int `json:"source_id,omitempty"`
+	RetryAfter int  `json:"retry_after,omitempty"` // seconds
 }
 
 // Config
@@ -25,7 +30,13 @@ type ConfigResponse struct {
 
 	RoutesColumns map[string]string `json:"routes_columns"`
 
+	BgpCommunities map[string]string `json:"bgp_communities"`
+
 	PrefixLookupEnabled bool `json:"prefix_lookup_enabled"`
+	RpkiEnabled         bool `json:"rpki_enabled"`
+	IrrEnabled          bool `json:"irr_enabled"`
+	AsnMetadataEnabled  bool `json:"asn_metadata_enabled"`
+	BogonsEnabled       bool `json:"bogons_enabled"`
 }
 
 type Rejection struct {
@@ -68,8 +79,9 @@ type StatusResponse struct {
 
 // Routeservers
 type Routeserver struct {
-	Id   int    `json:"id"`
-	Name string `json:"name"`
+	Id    int    `json:"id"`
+	Name  string `json:"name"`
+	Group string `json:"group"`
 }
 
 type RouteserversResponse struct {
@@ -94,6 +106,20 @@ type Neighbour struct {
 	Uptime          time.Duration `json:"uptime"`
 	LastError       string        `json:"last_error"`
 
+	// Max-prefix: The limit is 0 if there is none.
+	// Usage is the percentage of the limit in use.
+	ImportLimit      int     `json:"import_limit"`
+	RouteLimitUsed   int     `json:"route_limit_used"`
+	ImportLimitUsage float64 `json:"import_limit_usage"`
+
+	// Update counters, if provided by the source
+	RouteChanges *RouteChanges `json:"route_changes,omitempty"`
+
+	// Enrichments
+	RoutesRpkiInvalid *int   `json:"routes_rpki_invalid,omitempty"` // requires the routes store
+	AsName            string `json:"as_name,omitempty"`
+	Organisation      string `json:"organisation,omitempty"`
+
 	// Original response
 	Details map[string]interface{} `json:"details"`
 }
@@ -116,19 +142,135 @@ type NeighboursResponse struct {
 	Neighbours Neighbours `json:"neighbours"`
 }
 
+// Route update counters of a session
+type RouteChangesCounters struct {
+	Received int `json:"received"`
+	Rejected int `json:"rejected"`
+	Filtered int `json:"filtered"`
+	Ignored  int `json:"ignored"`
+	Accepted int `json:"accepted"`
+}
+
+type RouteChanges struct {
+	ImportUpdates   RouteChangesCounters `json:"import_updates"`
+	ImportWithdraws RouteChangesCounters `json:"import_withdraws"`
+	ExportUpdates   RouteChangesCounters `json:"export_updates"`
+	ExportWithdraws RouteChangesCounters `json:"export_withdraws"`
+}
+
+// Neighbour details
+type IrrSummary struct {
+	Checked       int `json:"checked"`
+	OriginInAsSet int `json:"origin_in_as_set"`
+	RouteObject   int `json:"route_object"`
+}
+
+type NeighbourRoutesStats struct {
+	Imported    int `json:"imported"`
+	Filtered    int `json:"filtered"`
+	NotExported int `json:"not_exported"`
+
+	// Filtered routes by reject reason id, "unknown"
+	// if the route has no reject reason community
+	FilteredByReason map[string]int `json:"filtered_by_reason"`
+
+	// Imported and filtered routes
+	PrefixLengthsIpv4 map[int]int `json:"prefix_lengths_ipv4"`
+	PrefixLengthsIpv6 map[int]int `json:"prefix_lengths_ipv6"`
+	OriginAsns        map[int]int `json:"origin_asns"`
+
+	// Only if enabled
+	Rpki map[string]int `json:"rpki,omitempty"`
+	Irr  *IrrSummary    `json:"irr,omitempty"`
+}
+
+type NeighbourResponse struct {
+	Api       ApiStatus            `json:"api"`
+	Neighbour Neighbour            `json:"neighbour"`
+	Stats     NeighbourRoutesStats `json:"stats"`
+}
+
 type NeighboursLookupResults map[int][]Neighbour
 
+// Neighbour state history
+type NeighbourStateChange struct {
+	Timestamp     time.Time `json:"timestamp"`
+	PreviousState string    `json:"previous_state"`
+	State         string    `json:"state"`
+}
+
+type NeighbourHistoryResponse struct {
+	NeighbourId string                 `json:"neighbour_id"`
+	State       string                 `json:"state"`
+	Events      []NeighbourStateChange `json:"events"`
+
+	FlapCount int     `json:"flap_count"`
+	FlapScore float64 `json:"flap_score"`
+	Flapping  bool    `json:"flapping"`
+}
+
+// Lookup Neighbours
+type LookupNeighbour struct {
+	Neighbour
+
+	Routeserver Routeserver `json:"routeserver"`
+}
+
+type NeighboursLookupResponseGlobal struct {
+	Neighbours []LookupNeighbour `json:"neighbours"`
+
+	// Pagination
+	TotalNeighbours int `json:"total_neighbours"`
+	Limit           int `json:"limit"`
+	Offset          int `json:"offset"`
+
+	// Meta
+	Time float64 `json:"query_duration_ms"`
+}
+
+// AS names and organisations
+type AsInfo struct {
+	Asn          int    `json:"asn"`
+	Name         string `json:"name"`
+	Organisation string `json:"organisation"`
+	Country      string `json:"country"`
+}
+
 // BGP
 type Community []int
+type ExtCommunity []string // e.g. [rt, 65000, 1]
+
+type CommunityLabel struct {
+	Community string `json:"community"`
+	Label     string `json:"label"`
+}
 
 type BgpInfo struct {
-	Origin           string      `json:"origin"`
-	AsPath           []int       `json:"as_path"`
-	NextHop          string      `json:"next_hop"`
-	Communities      []Community `json:"communities"`
-	LargeCommunities []Community `json:"large_communities"`
-	LocalPref        int         `json:"local_pref"`
-	Med              int         `json:"med"`
+	Origin           string         `json:"origin"`
+	AsPath           []int          `json:"as_path"`
+	NextHop          string         `json:"next_hop"`
+	Communities      []Community    `json:"communities"`
+	ExtCommunities   []ExtCommunity `json:"ext_communities"`
+	LargeCommunities []Community    `json:"large_communities"`
+	LocalPref        int            `json:"local_pref"`
+	Med              int            `json:"med"`
+
+	// Enrichments
+	AsPathInfo []AsInfo `json:"as_path_info,omitempty"`
+}
+
+// RPKI origin validation states
+const (
+	RPKI_VALID     = "valid"
+	RPKI_INVALID   = "invalid"
+	RPKI_NOT_FOUND = "not-found"
+)
+
+// IRR prefix and origin checks
+type IrrCheck struct {
+	AsSet         string `json:"as_set"`
+	OriginInAsSet bool   `json:"origin_in_as_set"`
+	RouteObject   bool   `json:"route_object"`
 }
 
 // Prefixes
@@ -142,7 +284,14 @@ type Route struct {
 	Metric    int           `json:"metric"`
 	Bgp       BgpInfo       `json:"bgp"`
 	Age       time.Duration `json:"age"`
-	Type      []string      `json:"type"` // [BGP, unicast, univ]
+	Type      []string      `json:"type"`    // [BGP, unicast, univ]
+	Primary   bool          `json:"primary"` // best path
+
+	// Enrichments
+	Rpki            string           `json:"rpki,omitempty"` // valid, invalid, not-found
+	Irr             *IrrCheck        `json:"irr,omitempty"`
+	CommunityLabels []CommunityLabel `json:"community_labels,omitempty"`
+	Warnings        []string         `json:"warnings,omitempty"`
 
 	Details Details `json:"details"`
 }
@@ -163,11 +312,82 @@ type LookupRoute struct {
 	Metric    int           `json:"metric"`
 	Bgp       BgpInfo       `json:"bgp"`
 	Age       time.Duration `json:"age"`
-	Type      []string      `json:"type"` // [BGP, unicast, univ]
+	Type      []string      `json:"type"`    // [BGP, unicast, univ]
+	Primary   bool          `json:"primary"` // best path
+
+	// Enrichments
+	Rpki            string           `json:"rpki,omitempty"` // valid, invalid, not-found
+	Irr             *IrrCheck        `json:"irr,omitempty"`
+	CommunityLabels []CommunityLabel `json:"community_labels,omitempty"`
+	Warnings        []string         `json:"warnings,omitempty"`
 
 	Details Details `json:"details"`
 }
 
+// Route details
+type RouteReason struct {
+	Id     int    `json:"id"`
+	Reason string `json:"reason"`
+}
+
+type RoutePath struct {
+	LookupRoute
+
+	RejectReason   *RouteReason `json:"reject_reason,omitempty"`
+	NoexportReason *RouteReason `json:"noexport_reason,omitempty"`
+}
+
+type RouteDetailResponse struct {
+	Api         ApiStatus   `json:"api"`
+	Routeserver Routeserver `json:"routeserver"`
+	Prefix      string      `json:"prefix"`
+
+	// All candidate paths on the route server
+	Paths []RoutePath `json:"paths"`
+
+	// Export to a neighbour, if requested
+	Neighbour *Neighbour `json:"neighbour,omitempty"`
+	Exported  *bool      `json:"exported,omitempty"`
+}
+
+// Export visibility
+type ExportVisibility struct {
+	Prefix      string `json:"prefix"`
+	NeighbourId string `json:"neighbour_id"`
+	Asn         int    `json:"asn"` // first ASN of the path
+	Primary     bool   `json:"primary"`
+
+	// Only best paths are exported
+	Exported bool         `json:"exported"`
+	Reason   *RouteReason `json:"reason,omitempty"`
+}
+
+type ExportVisibilityResponse struct {
+	Api         ApiStatus          `json:"api"`
+	Routeserver Routeserver        `json:"routeserver"`
+	Target      Neighbour          `json:"target"`
+	Routes      []ExportVisibility `json:"routes"`
+}
+
+type ExportMatrixEntry struct {
+	Target Neighbour `json:"target"`
+
+	RoutesNotExported int `json:"routes_not_exported"`
+
+	// Routes by noexport reason id, "unknown"
+	// if the route has no reason community
+	Reasons map[string]int `json:"reasons"`
+
+	Error string `json:"error,omitempty"`
+}
+
+type ExportMatrixResponse struct {
+	Api         ApiStatus           `json:"api"`
+	Routeserver Routeserver         `json:"routeserver"`
+	Neighbour   Neighbour           `json:"neighbour"`
+	Targets     []ExportMatrixEntry `json:"targets"`
+}
+
 type Routes []Route
 
 // Implement sorting interface for routes
@@ -190,6 +410,44 @@ type RoutesResponse struct {
 	NotExported []Route   `json:"not_exported"`
 }
 
+// Route server comparison
+type RouteStateDiff struct {
+	Prefix string `json:"prefix"`
+	StateA string `json:"state_a"`
+	StateB string `json:"state_b"`
+}
+
+type RouteAttributesDiff struct {
+	Prefix     string   `json:"prefix"`
+	Attributes []string `json:"attributes"` // as_path, med, ...
+}
+
+// Differences of the routes of a neighbour ASN
+type NeighbourRoutesDiff struct {
+	Asn int `json:"asn"`
+
+	OnlyA            []string              `json:"only_a"`
+	OnlyB            []string              `json:"only_b"`
+	StateDiffers     []RouteStateDiff      `json:"state_differs"`
+	AttributesDiffer []RouteAttributesDiff `json:"attributes_differ"`
+}
+
+type RoutesComparison struct {
+	A Routeserver `json:"a"`
+	B Routeserver `json:"b"`
+
+	Consistent bool `json:"consistent"`
+
+	// Only neighbours with differences
+	Neighbours []NeighbourRoutesDiff `json:"neighbours"`
+}
+
+type RoutesComparisonResponse struct {
+	Comparisons []RoutesComparison `json:"comparisons"`
+
+	Time float64 `json:"query_duration_ms"`
+}
+
 type RoutesLookupResponse struct {
 	Api    ApiStatus     `json:"api"`
 	Routes []LookupRoute `json:"routes"`
@@ -206,3 +464,53 @@ type RoutesLookupResponseGlobal struct {
 	// Meta
 	Time float64 `json:"query_duration_ms"`
 }
+
+// IRR compliance report
+type IrrReportResponse struct {
+	Api ApiStatus `json:"api"`
+
+	NeighbourId  string `json:"neighbour_id"`
+	Asn          int    `json:"asn"`
+	AsSet        string `json:"as_set"`
+	AsSetMembers int    `json:"as_set_members"`
+
+	TotalRoutes     int `json:"total_routes"`
+	CompliantRoutes int `json:"compliant_routes"`
+
+	OriginNotInAsSet []Route `json:"origin_not_in_as_set"`
+	NoRouteObject    []Route `json:"no_route_object"`
+}
+
+// Events
+type Event struct {
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+
+	Routeserver Routeserver `json:"routeserver"`
+	Neighbour   *Neighbour  `json:"neighbour,omitempty"`
+
+	Data map[string]interface{} `json:"data,omitempty"`
+}
+
+// Api keys and access tiers
+type AccessTier struct {
+	Name string `json:"name"`
+
+	Rate           float64 `json:"rate"`
+	Burst          int     `json:"burst"`
+	ExpensiveRate  float64 `json:"expensive_rate"`
+	ExpensiveBurst int     `json:"expensive_burst"`
+
+	Details bool `json:"details"`
+	Admin   bool `json:"admin"`
+}
+
+type ApiKey struct {
+	Name string `json:"name"`
+	Tier string `json:"tier"`
+}
+
+type AccessResponse struct {
+	Tiers []AccessTier `json:"tiers"`
+	Keys  []ApiKey     `json:"keys"`
+}
@@ -0,0 +1,111 @@
+package v2
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Duration is serialized as ISO 8601 duration,
+// e.g. P2DT3H4M5.5S. Fractions are limited to milliseconds.
+type Duration time.Duration
+
+var durationPattern = regexp.MustCompile(
+	`^(-)?P(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+func (d Duration) String() string {
+	duration := time.Duration(d).Round(time.Millisecond)
+	if duration == 0 {
+		return "PT0S"
+	}
+
+	result := "P"
+	if duration < 0 {
+		result = "-P"
+		duration = -duration
+	}
+
+	days := duration / (24 * time.Hour)
+	duration -= days * 24 * time.Hour
+	hours := duration / time.Hour
+	duration -= hours * time.Hour
+	minutes := duration / time.Minute
+	duration -= minutes * time.Minute
+
+	if days > 0 {
+		result += fmt.Sprintf("%dD", days)
+	}
+	if duration == 0 && hours == 0 && minutes == 0 {
+		return result
+	}
+
+	result += "T"
+	if hours > 0 {
+		result += fmt.Sprintf("%dH", hours)
+	}
+	if minutes > 0 {
+		result += fmt.Sprintf("%dM", minutes)
+	}
+	if duration > 0 {
+		seconds := strconv.FormatFloat(duration.Seconds(), 'f', 3, 64)
+		seconds = strings.TrimRight(strings.TrimRight(seconds, "0"), ".")
+		result += seconds + "S"
+	}
+
+	return result
+}
+
+// Parse an ISO 8601 duration. Years, months
+// and weeks are not supported.
+func ParseDuration(value string) (Duration, error) {
+	match := durationPattern.FindStringSubmatch(value)
+	if match == nil || value == "P" || strings.HasSuffix(value, "T") {
+		return 0, fmt.Errorf("invalid duration: %s", value)
+	}
+
+	var duration time.Duration
+	units := []time.Duration{24 * time.Hour, time.Hour, time.Minute}
+	for i, unit := range units {
+		if match[i+2] == "" {
+			continue
+		}
+		n, err := strconv.Atoi(match[i+2])
+		if err != nil {
+			return 0, err
+		}
+		duration += time.Duration(n) * unit
+	}
+	if match[5] != "" {
+		seconds, err := strconv.ParseFloat(match[5], 64)
+		if err != nil {
+			return 0, err
+		}
+		duration += time.Duration(seconds * float64(time.Second))
+	}
+
+	if match[1] == "-" {
+		duration = -duration
+	}
+
+	return Duration(duration), nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+	duration, err := ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*d = duration
+	return nil
+}
@@ -0,0 +1,67 @@
+package v2
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestDurationString(t *testing.T) {
+	expected := []struct {
+		duration time.Duration
+		iso      string
+	}{
+		{0, "PT0S"},
+		{1500 * time.Millisecond, "PT1.5S"},
+		{12345 * time.Microsecond, "PT0.012S"},
+		{90 * time.Minute, "PT1H30M"},
+		{48 * time.Hour, "P2D"},
+		{50*time.Hour + 4*time.Minute + 5*time.Second, "P2DT2H4M5S"},
+		{-30 * time.Second, "-PT30S"},
+	}
+
+	for _, e := range expected {
+		if iso := Duration(e.duration).String(); iso != e.iso {
+			t.Error("Expected", e.iso, "for", e.duration, "got:", iso)
+		}
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	for _, value := range []string{"PT0S", "PT1.5S", "PT1H30M", "P2D", "P2DT2H4M5S", "-PT30S"} {
+		duration, err := ParseDuration(value)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+		if duration.String() != value {
+			t.Error("Expected", value, "got:", duration)
+		}
+	}
+
+	for _, value := range []string{"", "P", "PT", "P1Y", "1H", "PT1.5H"} {
+		if _, err := ParseDuration(value); err == nil {
+			t.Error("Expected error for:", value)
+		}
+	}
+}
+
+func TestDurationJson(t *testing.T) {
+	neighbour := Neighbour{
+		Id:     "ID1",
+		Uptime: Duration(26 * time.Hour),
+	}
+
+	payload, err := json.Marshal(neighbour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := Neighbour{}
+	if err := json.Unmarshal(payload, &result); err != nil {
+		t.Fatal(err)
+	}
+	if result.Uptime != neighbour.Uptime {
+		t.Error("Expected uptime", neighbour.Uptime, "got:", result.Uptime)
+	}
+}
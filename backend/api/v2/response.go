@@ -0,0 +1,153 @@
+package v2
+
+import (
+	"time"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+// Api v2
+//
+// All responses share the same envelope: The result is
+// in data, lists are paginated and meta information like
+// the cache status is in meta. Durations are ISO 8601
+// durations and route server ids are strings.
+
+// General api response
+type Response struct {
+	Data interface{} `json:"data"`
+	Meta Meta        `json:"meta"`
+}
+
+type Meta struct {
+	Version     string    `json:"version"`
+	GeneratedAt time.Time `json:"generated_at"`
+
+	QueryDuration Duration `json:"query_duration"`
+
+	// Set if the result is from a source
+	Cache *Cache `json:"cache,omitempty"`
+
+	// Set if the result is a list
+	Pagination *Pagination `json:"pagination,omitempty"`
+}
+
+type Cache struct {
+	FromCache bool      `json:"from_cache"`
+	CachedAt  time.Time `json:"cached_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+type Pagination struct {
+	Total  int `json:"total"`
+	Limit  int `json:"limit"`
+	Offset int `json:"offset"`
+}
+
+// Error handling
+type ErrorResponse struct {
+	Error Error `json:"error"`
+}
+
+type Error struct {
+	Code    string `json:"code"` // e.g. invalid_param, not_found
+	Message string `json:"message"`
+
+	// Failures of a source
+	RouteserverId string   `json:"routeserver_id,omitempty"`
+	RetryAfter    Duration `json:"retry_after,omitempty"`
+}
+
+// Routeservers
+type Routeserver struct {
+	Id    string `json:"id"`
+	Name  string `json:"name"`
+	Group string `json:"group"`
+}
+
+type RouteserverStatus struct {
+	RouteserverId string `json:"routeserver_id"`
+
+	ServerTime   time.Time `json:"server_time"`
+	LastReboot   time.Time `json:"last_reboot"`
+	LastReconfig time.Time `json:"last_reconfig"`
+	Message      string    `json:"message"`
+	RouterId     string    `json:"router_id"`
+	Version      string    `json:"version"`
+	Backend      string    `json:"backend"`
+}
+
+// Neighbours
+type Neighbour struct {
+	Id            string `json:"id"`
+	RouteserverId string `json:"routeserver_id"`
+
+	Address     string   `json:"address"`
+	Asn         int      `json:"asn"`
+	State       string   `json:"state"`
+	Description string   `json:"description"`
+	Uptime      Duration `json:"uptime"`
+	LastError   string   `json:"last_error"`
+
+	RoutesReceived    int  `json:"routes_received"`
+	RoutesFiltered    int  `json:"routes_filtered"`
+	RoutesExported    int  `json:"routes_exported"`
+	RoutesPreferred   int  `json:"routes_preferred"`
+	RoutesRpkiInvalid *int `json:"routes_rpki_invalid,omitempty"`
+
+	// Max-prefix: The limit is 0 if there is none
+	ImportLimit      int     `json:"import_limit"`
+	ImportLimitUsage float64 `json:"import_limit_usage"` // percent
+
+	RouteChanges *api.RouteChanges `json:"route_changes,omitempty"`
+
+	AsName       string `json:"as_name,omitempty"`
+	Organisation string `json:"organisation,omitempty"`
+
+	Details map[string]interface{} `json:"details"`
+}
+
+type NeighbourDetail struct {
+	Neighbour
+
+	Stats api.NeighbourRoutesStats `json:"stats"`
+}
+
+// Routes
+const (
+	ROUTE_STATE_IMPORTED     = "imported"
+	ROUTE_STATE_FILTERED     = "filtered"
+	ROUTE_STATE_NOT_EXPORTED = "not_exported"
+)
+
+type Route struct {
+	Id            string `json:"id"`
+	RouteserverId string `json:"routeserver_id"`
+	NeighbourId   string `json:"neighbour_id"`
+
+	Network string `json:"network"`
+	State   string `json:"state"` // imported, filtered, not_exported
+	Primary bool   `json:"primary"`
+
+	Interface string      `json:"interface"`
+	Gateway   string      `json:"gateway"`
+	Metric    int         `json:"metric"`
+	Bgp       api.BgpInfo `json:"bgp"`
+	Age       Duration    `json:"age"`
+	Type      []string    `json:"type"`
+
+	// Enrichments
+	Rpki            string               `json:"rpki,omitempty"`
+	Irr             *api.IrrCheck        `json:"irr,omitempty"`
+	CommunityLabels []api.CommunityLabel `json:"community_labels,omitempty"`
+	Warnings        []string             `json:"warnings,omitempty"`
+
+	Details map[string]interface{} `json:"details"`
+}
+
+// Lookups include the neighbour of the route
+type LookupRoute struct {
+	Route
+
+	Neighbour Neighbour `json:"neighbour"`
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/ecix/alice-lg/backend/api"
+	"github.com/ecix/alice-lg/backend/sources"
+)
+
+// Api errors
+//
+// Handlers return an ApiError to respond with a status
+// other than 500. The code allows clients to handle errors
+// without parsing the message. Failures of a source are
+// reported with the source id and a hint when to retry.
+
+const (
+	ERROR_CODE_INVALID_PARAM         = "invalid_param"
+	ERROR_CODE_NOT_FOUND             = "not_found"
+	ERROR_CODE_RATE_LIMITED          = "rate_limited"
+	ERROR_CODE_UNAUTHORIZED          = "unauthorized"
+	ERROR_CODE_FORBIDDEN             = "forbidden"
+	ERROR_CODE_NOT_READY             = "not_ready"
+	ERROR_CODE_UPSTREAM_UNAVAILABLE  = "upstream_unavailable"
+	ERROR_CODE_UPSTREAM_TIMEOUT      = "upstream_timeout"
+	ERROR_CODE_UPSTREAM_BAD_RESPONSE = "upstream_bad_response"
+	ERROR_CODE_INTERNAL              = "internal_error"
+)
+
+// Seconds to wait before retrying a failed upstream request
+const (
+	RETRY_AFTER_UNAVAILABLE = 30
+	RETRY_AFTER_TIMEOUT     = 60
+)
+
+type ApiError struct {
+	Status  int
+	Code    string
+	Message string
+
+	RetryAfter int // seconds
+}
+
+func (self *ApiError) Error() string {
+	return self.Message
+}
+
+func NewInvalidParamError(format string, args ...interface{}) error {
+	return &ApiError{
+		Status:  http.StatusBadRequest,
+		Code:    ERROR_CODE_INVALID_PARAM,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func NewNotFoundError(format string, args ...interface{}) error {
+	return &ApiError{
+		Status:  http.StatusNotFound,
+		Code:    ERROR_CODE_NOT_FOUND,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func NewUnauthorizedError(format string, args ...interface{}) error {
+	return &ApiError{
+		Status:  http.StatusUnauthorized,
+		Code:    ERROR_CODE_UNAUTHORIZED,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+func NewForbiddenError(format string, args ...interface{}) error {
+	return &ApiError{
+		Status:  http.StatusForbidden,
+		Code:    ERROR_CODE_FORBIDDEN,
+		Message: fmt.Sprintf(format, args...),
+	}
+}
+
+// The data is not available yet, e.g. the
+// routes store was not refreshed.
+func NewNotReadyError(format string, args ...interface{}) error {
+	return &ApiError{
+		Status:     http.StatusServiceUnavailable,
+		Code:       ERROR_CODE_NOT_READY,
+		Message:    fmt.Sprintf(format, args...),
+		RetryAfter: RETRY_AFTER_UNAVAILABLE,
+	}
+}
+
+// The request did not complete within the request
+// timeout, e.g. a source was slow to respond.
+func NewRequestTimeoutError() error {
+	return &ApiError{
+		Status:     http.StatusGatewayTimeout,
+		Code:       ERROR_CODE_UPSTREAM_TIMEOUT,
+		Message:    "The request timed out",
+		RetryAfter: RETRY_AFTER_TIMEOUT,
+	}
+}
+
+func NewRateLimitedError(retryAfter int) error {
+	return &ApiError{
+		Status:     http.StatusTooManyRequests,
+		Code:       ERROR_CODE_RATE_LIMITED,
+		Message:    "Too many requests",
+		RetryAfter: retryAfter,
+	}
+}
+
+// Map an error to a status and response
+func apiErrorResponse(err error) (int, api.ErrorResponse) {
+	switch e := err.(type) {
+	case *ApiError:
+		return e.Status, api.ErrorResponse{
+			Error:      e.Message,
+			Code:       e.Code,
+			RetryAfter: e.RetryAfter,
+		}
+
+	case *sources.SourceError:
+		return sourceErrorResponse(e)
+	}
+
+	return http.StatusInternalServerError, api.ErrorResponse{
+		Error: err.Error(),
+		Code:  ERROR_CODE_INTERNAL,
+	}
+}
+
+// The details of the error contain the address of the
+// source, they are logged but not sent to the client.
+func sourceErrorResponse(err *sources.SourceError) (int, api.ErrorResponse) {
+	log.Println("Error from source", err.SourceId, ":", err)
+
+	sourceId := err.SourceId
+	response := api.ErrorResponse{
+		SourceId: &sourceId,
+	}
+
+	switch err.Kind {
+	case sources.ERROR_TIMEOUT:
+		response.Error = fmt.Sprintf(
+			"Routeserver %d did not respond in time", sourceId)
+		response.Code = ERROR_CODE_UPSTREAM_TIMEOUT
+		response.RetryAfter = RETRY_AFTER_TIMEOUT
+		return http.StatusGatewayTimeout, response
+
+	case sources.ERROR_UNAVAILABLE:
+		response.Error = fmt.Sprintf(
+			"Routeserver %d is not available", sourceId)
+		response.Code = ERROR_CODE_UPSTREAM_UNAVAILABLE
+		response.RetryAfter = RETRY_AFTER_UNAVAILABLE
+		return http.StatusBadGateway, response
+	}
+
+	response.Error = fmt.Sprintf(
+		"Routeserver %d sent an invalid response", sourceId)
+	response.Code = ERROR_CODE_UPSTREAM_BAD_RESPONSE
+	return http.StatusBadGateway, response
+}
+
+// Write an error response
+func writeErrorResponse(res http.ResponseWriter, err error) {
+	status, response := apiErrorResponse(err)
+	payload, _ := json.Marshal(response)
+
+	writeErrorHeader(res, status, response.RetryAfter)
+	res.Write(payload)
+}
+
+func writeErrorHeader(res http.ResponseWriter, status int, retryAfter int) {
+	if status == http.StatusUnauthorized {
+		res.Header().Set("WWW-Authenticate", "Bearer")
+	}
+	if retryAfter > 0 {
+		res.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	}
+	res.Header().Set("Content-Type", "application/json")
+	res.WriteHeader(status)
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecix/alice-lg/backend/api"
+	"github.com/ecix/alice-lg/backend/sources"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestApiErrorResponse(t *testing.T) {
+	status, response := apiErrorResponse(NewNotFoundError("Neighbour not found: %s", "ID1"))
+	if status != http.StatusNotFound || response.Code != ERROR_CODE_NOT_FOUND {
+		t.Error("Unexpected error response:", status, response)
+	}
+	if response.Error != "Neighbour not found: ID1" {
+		t.Error("Unexpected error message:", response.Error)
+	}
+
+	status, response = apiErrorResponse(NewInvalidParamError("Invalid prefix"))
+	if status != http.StatusBadRequest || response.Code != ERROR_CODE_INVALID_PARAM {
+		t.Error("Unexpected error response:", status, response)
+	}
+
+	status, response = apiErrorResponse(fmt.Errorf("connection refused"))
+	if status != http.StatusInternalServerError || response.Code != ERROR_CODE_INTERNAL {
+		t.Error("Unexpected error response:", status, response)
+	}
+}
+
+func TestValidateNeighbourId(t *testing.T) {
+	AliceNeighboursStore = makeNeighboursStore()
+	defer func() { AliceNeighboursStore = nil }()
+
+	expected := []struct {
+		rsId   int
+		id     string
+		status int
+	}{
+		{1, "ID2233_AS2343", 0},
+		{1, "ID999_AS1234", http.StatusNotFound},
+		{1, "../status", http.StatusBadRequest},
+		{1, "ID1?foo=bar", http.StatusBadRequest},
+		{1, "", http.StatusBadRequest},
+		{42, "ID109_AS31078_194.9.117.4", 0}, // neighbours are unknown
+		{42, ".", http.StatusBadRequest},
+		{42, "..", http.StatusBadRequest},
+		{42, ".hidden", http.StatusBadRequest},
+		{42, "_bgp_rs1", 0},
+	}
+
+	for _, e := range expected {
+		_, err := validateNeighbourId(e.rsId, e.id)
+		status := 0
+		if err != nil {
+			status, _ = apiErrorResponse(err)
+		}
+		if status != e.status {
+			t.Error("Expected status", e.status, "for", e.id, "got:", status)
+		}
+	}
+}
+
+func TestSourceErrorResponse(t *testing.T) {
+	expected := []struct {
+		err        error
+		status     int
+		code       string
+		retryAfter int
+	}{
+		{sources.NewTimeoutError(1, fmt.Errorf("timeout")),
+			http.StatusGatewayTimeout, ERROR_CODE_UPSTREAM_TIMEOUT, RETRY_AFTER_TIMEOUT},
+		{sources.NewUnavailableError(1, fmt.Errorf("connection refused")),
+			http.StatusBadGateway, ERROR_CODE_UPSTREAM_UNAVAILABLE, RETRY_AFTER_UNAVAILABLE},
+		{sources.NewBadResponseError(1, fmt.Errorf("Routes response missing")),
+			http.StatusBadGateway, ERROR_CODE_UPSTREAM_BAD_RESPONSE, 0},
+	}
+
+	for _, e := range expected {
+		status, response := apiErrorResponse(e.err)
+		if status != e.status || response.Code != e.code ||
+			response.RetryAfter != e.retryAfter {
+			t.Error("Unexpected response for", e.err, ":", status, response)
+		}
+		if response.SourceId == nil || *response.SourceId != 1 {
+			t.Error("Expected source id to be set:", response)
+		}
+	}
+
+	// Details of the upstream request are not exposed
+	err := sources.NewUnavailableError(1, fmt.Errorf(
+		`Get "http://10.23.42.1:29184/routes/protocol/ID1": dial tcp: connection refused`))
+	_, response := apiErrorResponse(err)
+	if strings.Contains(response.Error, "10.23.42.1") ||
+		response.Error != "Routeserver 1 is not available" {
+		t.Error("Unexpected message:", response.Error)
+	}
+
+	// Retry hint header
+	res := httptest.NewRecorder()
+	writeErrorResponse(res, sources.NewTimeoutError(0, fmt.Errorf("timeout")))
+	if res.Code != http.StatusGatewayTimeout {
+		t.Error("Unexpected status:", res.Code)
+	}
+	if res.Header().Get("Retry-After") != "60" {
+		t.Error("Unexpected Retry-After:", res.Header().Get("Retry-After"))
+	}
+}
+
+func TestEndpointRequestTimeout(t *testing.T) {
+	AliceConfig = &Config{Server: ServerConfig{RequestTimeout: 10 * time.Millisecond}}
+	defer func() {
+		AliceConfig = nil
+	}()
+
+	canceled := false
+	handle := makeEndpoint(func(req *http.Request, params httprouter.Params) (api.Response, error) {
+		select {
+		case <-req.Context().Done():
+			canceled = true // requests to the source are canceled
+		case <-time.After(time.Second):
+		}
+		return &api.ConfigResponse{}, nil
+	})
+
+	res := httptest.NewRecorder()
+	handle(res, httptest.NewRequest("GET", "/api/config", nil), nil)
+	if res.Code != http.StatusGatewayTimeout {
+		t.Error("Expected gateway timeout, got:", res.Code)
+	}
+	if !canceled {
+		t.Error("Expected the context of the handler to be canceled")
+	}
+	if !strings.Contains(res.Body.String(), ERROR_CODE_UPSTREAM_TIMEOUT) {
+		t.Error("Unexpected response:", res.Body.String())
+	}
+}
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/ecix/alice-lg/backend/api"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Server-Sent Events
+//
+// Stream store events to the client:
+//
+//    /api/events?source=<id>&asn=<asn>
+//
+// Events are sent as `event: <type>` with the json encoded
+// api.Event as data. A heartbeat event is sent periodically
+// to keep the connection open.
+
+const EVENTS_HEARTBEAT_INTERVAL = 30 * time.Second
+
+// Per client event filter
+type EventsFilter struct {
+	SourceId int // -1: all sources
+	Asn      int // 0: all neighbours
+}
+
+func (self EventsFilter) Match(event api.Event) bool {
+	if self.SourceId >= 0 && event.Routeserver.Id != self.SourceId {
+		return false
+	}
+
+	// Only neighbour events can match an ASN
+	if self.Asn != 0 {
+		if event.Neighbour == nil || event.Neighbour.Asn != self.Asn {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Get events filter from request
+func validateEventsFilter(req *http.Request) (EventsFilter, error) {
+	filter := EventsFilter{
+		SourceId: -1,
+	}
+
+	source := req.URL.Query().Get("source")
+	if source != "" {
+		sourceId, err := validateSourceId(source)
+		if err != nil {
+			return filter, err
+		}
+		filter.SourceId = sourceId
+	}
+
+	asn, err := validateIntParam(req, "asn", 0)
+	if err != nil {
+		return filter, err
+	}
+	filter.Asn = asn
+
+	return filter, nil
+}
+
+// Write a single server sent event
+func writeServerSentEvent(w io.Writer, eventType string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", eventType, payload)
+	return err
+}
+
+// Handle events stream
+func apiEventsStream(
+	res http.ResponseWriter,
+	req *http.Request,
+	_params httprouter.Params,
+) {
+	filter, err := validateEventsFilter(req)
+	if err != nil {
+		writeErrorResponse(res, err)
+		return
+	}
+
+	flusher, ok := res.(http.Flusher)
+	if !ok {
+		http.Error(res, "Streaming is not supported",
+			http.StatusInternalServerError)
+		return
+	}
+
+	events := AliceEvents.Subscribe(100)
+	defer AliceEvents.Unsubscribe(events)
+
+	heartbeat := time.NewTicker(EVENTS_HEARTBEAT_INTERVAL)
+	defer heartbeat.Stop()
+
+	res.Header().Set("Content-Type", "text/event-stream")
+	res.Header().Set("Cache-Control", "no-cache")
+	res.Header().Set("Connection", "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no") // nginx
+	clearWriteDeadline(res)
+	res.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-req.Context().Done():
+			return // client is gone
+
+		case <-lifecycleDone():
+			return // shutting down
+
+		case event := <-events:
+			if !filter.Match(event) {
+				continue
+			}
+			if AliceRedactor != nil && AliceRedactor.HideEvent(event) {
+				continue
+			}
+			err = writeServerSentEvent(res, event.Type, event)
+
+		case now := <-heartbeat.C:
+			err = writeServerSentEvent(res, "heartbeat", map[string]interface{}{
+				"timestamp": now,
+			})
+		}
+
+		if err != nil {
+			return
+		}
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+func TestEventsFilter(t *testing.T) {
+	event := api.Event{
+		Type:        EVENT_NEIGHBOUR_DOWN,
+		Routeserver: api.Routeserver{Id: 1},
+		Neighbour:   &api.Neighbour{Asn: 31078},
+	}
+
+	expected := []struct {
+		filter EventsFilter
+		match  bool
+	}{
+		{EventsFilter{SourceId: -1}, true},
+		{EventsFilter{SourceId: 1}, true},
+		{EventsFilter{SourceId: 0}, false},
+		{EventsFilter{SourceId: -1, Asn: 31078}, true},
+		{EventsFilter{SourceId: -1, Asn: 25074}, false},
+	}
+
+	for _, e := range expected {
+		if e.filter.Match(event) != e.match {
+			t.Error("Expected filter", e.filter, "to match:", e.match)
+		}
+	}
+
+	// Refresh events have no neighbour
+	event = api.Event{Type: EVENT_ROUTES_REFRESHED}
+	if (EventsFilter{SourceId: -1, Asn: 31078}).Match(event) {
+		t.Error("Expected ASN filter not to match refresh events")
+	}
+}
+
+func TestEventsStream(t *testing.T) {
+	AliceEvents = NewEventBus(EventsConfig{})
+	defer func() { AliceEvents = nil }()
+
+	server := httptest.NewServer(http.HandlerFunc(
+		func(res http.ResponseWriter, req *http.Request) {
+			apiEventsStream(res, req, nil)
+		}))
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/api/events?asn=31078")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer res.Body.Close()
+
+	if res.Header.Get("Content-Type") != "text/event-stream" {
+		t.Error("Unexpected content type:", res.Header.Get("Content-Type"))
+	}
+
+	// The client is subscribed once the headers are sent
+	AliceEvents.Publish(
+		api.Event{Type: EVENT_ROUTES_REFRESHED},
+		api.Event{
+			Type:      EVENT_NEIGHBOUR_UP,
+			Neighbour: &api.Neighbour{Asn: 31078},
+		})
+
+	reader := bufio.NewReader(res.Body)
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if line != "event: neighbour_up\n" {
+		t.Error("Unexpected event:", line)
+	}
+
+	line, _ = reader.ReadString('\n')
+	if !strings.HasPrefix(line, "data: {\"type\":\"neighbour_up\"") {
+		t.Error("Unexpected data:", line)
+	}
+}
@@ -0,0 +1,636 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/ecix/alice-lg/backend/api"
+	"github.com/ecix/alice-lg/backend/api/v2"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Alice LG Rest API v2
+//
+// The endpoints are described by the route table, which is
+// used for registering the handlers and for generating the
+// OpenAPI document served at /api/v2/openapi.json.
+//
+// The v1 endpoints are not changed. Endpoints not in the
+// route table are only available in v1, see api.go.
+
+const API_V2_PREFIX = "/api/v2"
+
+const API_V2_DESCRIPTION = "The v2 api covers the route servers, " +
+	"their neighbours and routes and the lookups. Visibility, " +
+	"export matrix, compare, bogons, import limits, neighbour " +
+	"history, route details, IRR report, routes export and " +
+	"events are only available in the v1 api at /api."
+
+type apiV2Endpoint func(*http.Request, httprouter.Params) (*v2.Response, error)
+
+type apiV2Param struct {
+	Name        string
+	In          string // path or query
+	Type        string // string or integer
+	Description string
+	Required    bool
+}
+
+type apiV2Route struct {
+	Id      string // operation id
+	Path    string
+	Summary string
+	Params  []apiV2Param
+
+	// The data of the response is a Result,
+	// or a list of Results if paginated.
+	Result    interface{}
+	Paginated bool
+	Expensive bool
+
+	Handler apiV2Endpoint
+}
+
+var apiV2SourceIdParam = apiV2Param{
+	Name:        "id",
+	In:          "path",
+	Type:        "string",
+	Description: "Route server id",
+	Required:    true,
+}
+
+var apiV2NeighbourIdParam = apiV2Param{
+	Name:        "neighbourId",
+	In:          "path",
+	Type:        "string",
+	Description: "Neighbour id",
+	Required:    true,
+}
+
+// Get the available endpoints
+func apiV2Routes() []apiV2Route {
+	routes := []apiV2Route{
+		{
+			Id:        "listRouteservers",
+			Path:      "/routeservers",
+			Summary:   "List route servers",
+			Result:    v2.Routeserver{},
+			Paginated: true,
+			Handler:   apiV2RouteserversList,
+		},
+		{
+			Id:      "showRouteserverStatus",
+			Path:    "/routeservers/:id/status",
+			Summary: "Show the status of a route server",
+			Params:  []apiV2Param{apiV2SourceIdParam},
+			Result:  v2.RouteserverStatus{},
+			Handler: apiV2RouteserverStatus,
+		},
+		{
+			Id:        "listNeighbours",
+			Path:      "/routeservers/:id/neighbours",
+			Summary:   "List the neighbours of a route server",
+			Params:    []apiV2Param{apiV2SourceIdParam},
+			Result:    v2.Neighbour{},
+			Paginated: true,
+			Handler:   apiV2NeighboursList,
+		},
+		{
+			Id:        "showNeighbour",
+			Path:      "/routeservers/:id/neighbours/:neighbourId",
+			Summary:   "Show a neighbour with the statistics of its routes",
+			Params:    []apiV2Param{apiV2SourceIdParam, apiV2NeighbourIdParam},
+			Result:    v2.NeighbourDetail{},
+			Expensive: true,
+			Handler:   apiV2NeighbourShow,
+		},
+		{
+			Id:      "listRoutes",
+			Path:    "/routeservers/:id/neighbours/:neighbourId/routes",
+			Summary: "List the routes of a neighbour",
+			Params: []apiV2Param{
+				apiV2SourceIdParam,
+				apiV2NeighbourIdParam,
+				{
+					Name:        "state",
+					In:          "query",
+					Type:        "string",
+					Description: "Filter by state: imported, filtered or not_exported",
+				},
+			},
+			Result:    v2.Route{},
+			Paginated: true,
+			Expensive: true,
+			Handler:   apiV2RoutesList,
+		},
+	}
+
+	if AliceConfig.Server.EnablePrefixLookup == false {
+		return routes
+	}
+
+	routes = append(routes,
+		apiV2Route{
+			Id:      "lookupPrefix",
+			Path:    "/lookup/prefix",
+			Summary: "Search routes on all route servers by prefix or neighbour",
+			Params: []apiV2Param{
+				{
+					Name:        "q",
+					In:          "query",
+					Type:        "string",
+					Description: "Prefix, or neighbour ASN or name",
+					Required:    true,
+				},
+				{
+					Name:        "rpki",
+					In:          "query",
+					Type:        "string",
+					Description: "Filter by RPKI state: valid, invalid or not-found",
+				},
+			},
+			Result:    v2.LookupRoute{},
+			Paginated: true,
+			Expensive: true,
+			Handler:   apiV2LookupPrefix,
+		},
+		apiV2Route{
+			Id:      "lookupNeighbours",
+			Path:    "/lookup/neighbours",
+			Summary: "Search neighbours on all route servers",
+			Params: []apiV2Param{
+				{Name: "asn", In: "query", Type: "integer"},
+				{Name: "address", In: "query", Type: "string",
+					Description: "IP address or network"},
+				{Name: "state", In: "query", Type: "string"},
+				{Name: "description", In: "query", Type: "string"},
+				{Name: "group", In: "query", Type: "string"},
+				{Name: "routes_received_min", In: "query", Type: "integer"},
+				{Name: "routes_received_max", In: "query", Type: "integer"},
+				{Name: "import_limit_usage_min", In: "query", Type: "integer"},
+			},
+			Result:    v2.Neighbour{},
+			Paginated: true,
+			Expensive: true,
+			Handler:   apiV2LookupNeighbours,
+		})
+
+	return routes
+}
+
+// Register api v2 endpoints
+func apiV2RegisterEndpoints(router *httprouter.Router) error {
+	routes := apiV2Routes()
+	for _, route := range routes {
+		budget := RATE_LIMIT_DEFAULT
+		if route.Expensive {
+			budget = RATE_LIMIT_EXPENSIVE
+		}
+		router.GET(API_V2_PREFIX+route.Path,
+			guardWith(budget, writeErrorResponseV2, makeV2Endpoint(route.Handler)))
+	}
+
+	spec, err := json.Marshal(makeOpenApiSpec(routes))
+	if err != nil {
+		return err
+	}
+	router.GET(API_V2_PREFIX+"/openapi.json",
+		guardWith(RATE_LIMIT_DEFAULT, writeErrorResponseV2, func(
+			res http.ResponseWriter,
+			req *http.Request,
+			_params httprouter.Params,
+		) {
+			writeJsonResponse(res, req, json.RawMessage(spec))
+		}))
+
+	return nil
+}
+
+func makeV2Endpoint(wrapped apiV2Endpoint) httprouter.Handle {
+	return func(res http.ResponseWriter,
+		req *http.Request,
+		params httprouter.Params) {
+
+		// Measure response time
+		t0 := time.Now()
+
+		var result *v2.Response
+		err := withRequestTimeout(req, func(req *http.Request) error {
+			var err error
+			result, err = wrapped(req, params)
+			return err
+		})
+		if err != nil {
+			writeErrorResponseV2(res, err)
+			return
+		}
+
+		result.Meta.Version = version
+		result.Meta.GeneratedAt = time.Now().UTC()
+		result.Meta.QueryDuration = v2.Duration(time.Since(t0))
+
+		writeJsonResponse(res, req, result)
+	}
+}
+
+// Write an error response in the v2 envelope
+func writeErrorResponseV2(res http.ResponseWriter, err error) {
+	status, response := apiErrorResponse(err)
+
+	apiError := v2.Error{
+		Code:       response.Code,
+		Message:    response.Error,
+		RetryAfter: v2.Duration(time.Duration(response.RetryAfter) * time.Second),
+	}
+	if response.SourceId != nil {
+		apiError.RouteserverId = strconv.Itoa(*response.SourceId)
+	}
+	payload, _ := json.Marshal(v2.ErrorResponse{Error: apiError})
+
+	writeErrorHeader(res, status, response.RetryAfter)
+	res.Write(payload)
+}
+
+// Helper: Get the bounds of the requested page
+func paginateV2(req *http.Request, total int) (int, int, *v2.Pagination, error) {
+	limit, offset, err := validatePaginationParams(req, 50, 0)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	offset, end := paginate(total, limit, offset)
+	pagination := &v2.Pagination{
+		Total:  total,
+		Limit:  limit,
+		Offset: offset,
+	}
+	return offset, end, pagination, nil
+}
+
+// Convert the cache status of a source response
+func makeV2Cache(status api.ApiStatus) *v2.Cache {
+	return &v2.Cache{
+		FromCache: status.ResultFromCache,
+		CachedAt:  status.CacheStatus.CachedAt,
+		ExpiresAt: status.Ttl,
+	}
+}
+
+func makeV2Routeserver(config SourceConfig) v2.Routeserver {
+	return v2.Routeserver{
+		Id:    strconv.Itoa(config.Id),
+		Name:  config.Name,
+		Group: config.Group,
+	}
+}
+
+func makeV2Neighbour(rsId int, neighbour api.Neighbour) v2.Neighbour {
+	return v2.Neighbour{
+		Id:            neighbour.Id,
+		RouteserverId: strconv.Itoa(rsId),
+
+		Address:     neighbour.Address,
+		Asn:         neighbour.Asn,
+		State:       neighbour.State,
+		Description: neighbour.Description,
+		Uptime:      v2.Duration(neighbour.Uptime),
+		LastError:   neighbour.LastError,
+
+		RoutesReceived:    neighbour.RoutesReceived,
+		RoutesFiltered:    neighbour.RoutesFiltered,
+		RoutesExported:    neighbour.RoutesExported,
+		RoutesPreferred:   neighbour.RoutesPreferred,
+		RoutesRpkiInvalid: neighbour.RoutesRpkiInvalid,
+
+		ImportLimit:      neighbour.ImportLimit,
+		ImportLimitUsage: neighbour.ImportLimitUsage,
+
+		RouteChanges: neighbour.RouteChanges,
+
+		AsName:       neighbour.AsName,
+		Organisation: neighbour.Organisation,
+
+		Details: neighbour.Details,
+	}
+}
+
+func makeV2Route(rsId int, state string, route api.Route) v2.Route {
+	return v2.Route{
+		Id:            route.Id,
+		RouteserverId: strconv.Itoa(rsId),
+		NeighbourId:   route.NeighbourId,
+
+		Network: route.Network,
+		State:   state,
+		Primary: route.Primary,
+
+		Interface: route.Interface,
+		Gateway:   route.Gateway,
+		Metric:    route.Metric,
+		Bgp:       route.Bgp,
+		Age:       v2.Duration(route.Age),
+		Type:      route.Type,
+
+		Rpki:            route.Rpki,
+		Irr:             route.Irr,
+		CommunityLabels: route.CommunityLabels,
+		Warnings:        route.Warnings,
+
+		Details: route.Details,
+	}
+}
+
+func makeV2LookupRoute(route api.LookupRoute) v2.LookupRoute {
+	rsId := route.Routeserver.Id
+	return v2.LookupRoute{
+		Route: v2.Route{
+			Id:            route.Id,
+			RouteserverId: strconv.Itoa(rsId),
+			NeighbourId:   route.NeighbourId,
+
+			Network: route.Network,
+			State:   route.State,
+			Primary: route.Primary,
+
+			Interface: route.Interface,
+			Gateway:   route.Gateway,
+			Metric:    route.Metric,
+			Bgp:       route.Bgp,
+			Age:       v2.Duration(route.Age),
+			Type:      route.Type,
+
+			Rpki:            route.Rpki,
+			Irr:             route.Irr,
+			CommunityLabels: route.CommunityLabels,
+			Warnings:        route.Warnings,
+
+			Details: route.Details,
+		},
+		Neighbour: makeV2Neighbour(rsId, route.Neighbour),
+	}
+}
+
+// Handle Routeservers List
+func apiV2RouteserversList(req *http.Request, _params httprouter.Params) (*v2.Response, error) {
+	start, end, pagination, err := paginateV2(req, len(AliceConfig.Sources))
+	if err != nil {
+		return nil, err
+	}
+
+	routeservers := []v2.Routeserver{}
+	for _, config := range AliceConfig.Sources[start:end] {
+		routeservers = append(routeservers, makeV2Routeserver(config))
+	}
+
+	response := &v2.Response{
+		Data: routeservers,
+		Meta: v2.Meta{
+			Pagination: pagination,
+		},
+	}
+	return response, nil
+}
+
+// Handle status
+func apiV2RouteserverStatus(req *http.Request, params httprouter.Params) (*v2.Response, error) {
+	rsId, err := validateSourceId(params.ByName("id"))
+	if err != nil {
+		return nil, err
+	}
+	source := AliceConfig.Sources[rsId].getInstance()
+	result, err := source.Status(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	status := v2.RouteserverStatus{
+		RouteserverId: strconv.Itoa(rsId),
+		ServerTime:    result.Status.ServerTime,
+		LastReboot:    result.Status.LastReboot,
+		LastReconfig:  result.Status.LastReconfig,
+		Message:       result.Status.Message,
+		RouterId:      result.Status.RouterId,
+		Version:       result.Status.Version,
+		Backend:       result.Status.Backend,
+	}
+
+	response := &v2.Response{
+		Data: status,
+		Meta: v2.Meta{
+			Cache: makeV2Cache(result.Api),
+		},
+	}
+	return response, nil
+}
+
+// Handle neighbours list
+func apiV2NeighboursList(req *http.Request, params httprouter.Params) (*v2.Response, error) {
+	rsId, err := validateSourceId(params.ByName("id"))
+	if err != nil {
+		return nil, err
+	}
+	result, err := apiNeighboursList(req, params)
+	if err == nil {
+		result, err = redactResponse(req, result)
+	}
+	if err != nil {
+		return nil, err
+	}
+	neighboursResponse := result.(api.NeighboursResponse)
+
+	neighbours := neighboursResponse.Neighbours
+	sort.Sort(neighbours)
+
+	start, end, pagination, err := paginateV2(req, len(neighbours))
+	if err != nil {
+		return nil, err
+	}
+
+	data := []v2.Neighbour{}
+	for _, neighbour := range neighbours[start:end] {
+		data = append(data, makeV2Neighbour(rsId, neighbour))
+	}
+
+	response := &v2.Response{
+		Data: data,
+		Meta: v2.Meta{
+			Cache:      makeV2Cache(neighboursResponse.Api),
+			Pagination: pagination,
+		},
+	}
+	return response, nil
+}
+
+// Handle neighbour details
+func apiV2NeighbourShow(req *http.Request, params httprouter.Params) (*v2.Response, error) {
+	rsId, err := validateSourceId(params.ByName("id"))
+	if err != nil {
+		return nil, err
+	}
+	result, err := apiNeighbourShow(req, params)
+	if err == nil {
+		result, err = redactResponse(req, result)
+	}
+	if err != nil {
+		return nil, err
+	}
+	neighbourResponse := result.(api.NeighbourResponse)
+
+	response := &v2.Response{
+		Data: v2.NeighbourDetail{
+			Neighbour: makeV2Neighbour(rsId, neighbourResponse.Neighbour),
+			Stats:     neighbourResponse.Stats,
+		},
+		Meta: v2.Meta{
+			Cache: makeV2Cache(neighbourResponse.Api),
+		},
+	}
+	return response, nil
+}
+
+// Handle routes: All routes of the neighbour
+// in a single list, with their state.
+func apiV2RoutesList(req *http.Request, params httprouter.Params) (*v2.Response, error) {
+	rsId, err := validateSourceId(params.ByName("id"))
+	if err != nil {
+		return nil, err
+	}
+
+	state := req.URL.Query().Get("state")
+	switch state {
+	case "", v2.ROUTE_STATE_IMPORTED, v2.ROUTE_STATE_FILTERED, v2.ROUTE_STATE_NOT_EXPORTED:
+	default:
+		return nil, NewInvalidParamError("Unknown route state: %s", state)
+	}
+
+	result, err := apiRoutesList(req, params)
+	if err == nil {
+		result, err = redactResponse(req, result)
+	}
+	if err != nil {
+		return nil, err
+	}
+	routesResponse := result.(api.RoutesResponse)
+
+	routes := []v2.Route{}
+	states := []struct {
+		state  string
+		routes []api.Route
+	}{
+		{v2.ROUTE_STATE_IMPORTED, routesResponse.Imported},
+		{v2.ROUTE_STATE_FILTERED, routesResponse.Filtered},
+		{v2.ROUTE_STATE_NOT_EXPORTED, routesResponse.NotExported},
+	}
+	for _, s := range states {
+		if state != "" && state != s.state {
+			continue
+		}
+		for _, route := range s.routes {
+			routes = append(routes, makeV2Route(rsId, s.state, route))
+		}
+	}
+
+	start, end, pagination, err := paginateV2(req, len(routes))
+	if err != nil {
+		return nil, err
+	}
+
+	response := &v2.Response{
+		Data: routes[start:end],
+		Meta: v2.Meta{
+			Cache:      makeV2Cache(routesResponse.Api),
+			Pagination: pagination,
+		},
+	}
+	return response, nil
+}
+
+// Handle global prefix lookup
+func apiV2LookupPrefix(req *http.Request, params httprouter.Params) (*v2.Response, error) {
+	q, err := validateQueryString(req, "q")
+	if err != nil {
+		return nil, err
+	}
+	q, err = validatePrefixQuery(q)
+	if err != nil {
+		return nil, err
+	}
+	rpkiState, err := validateRpkiStateParam(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var routes []api.LookupRoute
+	if MaybePrefix(q) {
+		routes = AliceRoutesStore.LookupPrefix(q)
+	} else {
+		neighbours := AliceNeighboursStore.LookupNeighbours(q)
+		routes = AliceRoutesStore.LookupPrefixForNeighbours(neighbours)
+	}
+	sortLookupRoutes(routes)
+
+	if rpkiState != "" {
+		routes = filterLookupRoutesByRpkiState(routes, rpkiState)
+	}
+
+	// Hide routes before paginating
+	if redactor := requestRedactor(req); redactor != nil {
+		routes = redactor.RedactLookupRoutes(routes)
+	}
+
+	start, end, pagination, err := paginateV2(req, len(routes))
+	if err != nil {
+		return nil, err
+	}
+
+	data := []v2.LookupRoute{}
+	for _, route := range routes[start:end] {
+		data = append(data, makeV2LookupRoute(route))
+	}
+
+	response := &v2.Response{
+		Data: data,
+		Meta: v2.Meta{
+			Pagination: pagination,
+		},
+	}
+	return response, nil
+}
+
+// Handle global neighbours lookup
+func apiV2LookupNeighbours(req *http.Request, params httprouter.Params) (*v2.Response, error) {
+	query, err := validateNeighboursQuery(req)
+	if err != nil {
+		return nil, err
+	}
+
+	neighbours := AliceNeighboursStore.FilterNeighbours(query)
+
+	// Hide neighbours before paginating
+	if redactor := requestRedactor(req); redactor != nil {
+		neighbours = redactor.RedactLookupNeighbours(neighbours)
+	}
+
+	start, end, pagination, err := paginateV2(req, len(neighbours))
+	if err != nil {
+		return nil, err
+	}
+
+	data := []v2.Neighbour{}
+	for _, neighbour := range neighbours[start:end] {
+		data = append(data,
+			makeV2Neighbour(neighbour.Routeserver.Id, neighbour.Neighbour))
+	}
+
+	response := &v2.Response{
+		Data: data,
+		Meta: v2.Meta{
+			Pagination: pagination,
+		},
+	}
+	return response, nil
+}
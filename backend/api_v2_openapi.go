@@ -0,0 +1,271 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/ecix/alice-lg/backend/api/v2"
+)
+
+// OpenAPI 3 document of the v2 api
+//
+// The paths are generated from the route table, the
+// schemas from the response types by reflection.
+
+const OPENAPI_VERSION = "3.0.3"
+
+type openApiSchemas struct {
+	components map[string]interface{}
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(v2.Duration(0))
+)
+
+// Get the schema of a type, named structs
+// are added to the components.
+func (self *openApiSchemas) schemaOf(t reflect.Type) map[string]interface{} {
+	switch t {
+	case timeType:
+		return map[string]interface{}{
+			"type":   "string",
+			"format": "date-time",
+		}
+	case durationType:
+		return map[string]interface{}{
+			"type":        "string",
+			"format":      "duration",
+			"description": "ISO 8601 duration",
+			"example":     "P1DT2H3M4.5S",
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return self.schemaOf(t.Elem())
+
+	case reflect.Struct:
+		if t.Name() == "" {
+			return self.structSchema(t)
+		}
+		if _, ok := self.components[t.Name()]; !ok {
+			self.components[t.Name()] = nil // prevent recursion
+			self.components[t.Name()] = self.structSchema(t)
+		}
+		return map[string]interface{}{
+			"$ref": "#/components/schemas/" + t.Name(),
+		}
+
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{
+			"type":     "array",
+			"items":    self.schemaOf(t.Elem()),
+			"nullable": t.Kind() == reflect.Slice,
+		}
+
+	case reflect.Map:
+		return map[string]interface{}{
+			"type":                 "object",
+			"additionalProperties": self.schemaOf(t.Elem()),
+			"nullable":             true,
+		}
+
+	case reflect.Interface:
+		return map[string]interface{}{}
+
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	}
+
+	return map[string]interface{}{}
+}
+
+// Make an object schema from the json fields of a struct,
+// the fields of embedded structs are inlined.
+func (self *openApiSchemas) structSchema(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	required := []string{}
+
+	self.addProperties(t, properties, &required)
+
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": properties,
+	}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+func (self *openApiSchemas) addProperties(
+	t reflect.Type,
+	properties map[string]interface{},
+	required *[]string,
+) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		if field.Anonymous && tag == "" {
+			self.addProperties(field.Type, properties, required)
+			continue
+		}
+		if field.PkgPath != "" {
+			continue // unexported
+		}
+
+		name := field.Name
+		options := strings.Split(tag, ",")
+		if options[0] != "" {
+			name = options[0]
+		}
+
+		properties[name] = self.schemaOf(field.Type)
+
+		omitempty := false
+		for _, option := range options[1:] {
+			if option == "omitempty" {
+				omitempty = true
+			}
+		}
+		if !omitempty && field.Type.Kind() != reflect.Ptr {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// Convert httprouter params to OpenAPI path templates:
+// /routeservers/:id -> /routeservers/{id}
+func openApiPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[i] = "{" + segment[1:] + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func openApiParameter(param apiV2Param) map[string]interface{} {
+	parameter := map[string]interface{}{
+		"name":     param.Name,
+		"in":       param.In,
+		"required": param.Required,
+		"schema": map[string]interface{}{
+			"type": param.Type,
+		},
+	}
+	if param.Description != "" {
+		parameter["description"] = param.Description
+	}
+	return parameter
+}
+
+var apiV2PaginationParams = []apiV2Param{
+	{
+		Name:        "limit",
+		In:          "query",
+		Type:        "integer",
+		Description: "Page size, at most 500",
+	},
+	{
+		Name:        "offset",
+		In:          "query",
+		Type:        "integer",
+		Description: "Index of the first result",
+	},
+}
+
+func openApiJsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{
+			"schema": schema,
+		},
+	}
+}
+
+// Make the OpenAPI document for the routes
+func makeOpenApiSpec(routes []apiV2Route) map[string]interface{} {
+	schemas := &openApiSchemas{
+		components: make(map[string]interface{}),
+	}
+
+	meta := schemas.schemaOf(reflect.TypeOf(v2.Meta{}))
+	errorResponse := map[string]interface{}{
+		"description": "Error",
+		"content": openApiJsonContent(
+			schemas.schemaOf(reflect.TypeOf(v2.ErrorResponse{}))),
+	}
+
+	paths := make(map[string]interface{})
+	for _, route := range routes {
+		data := schemas.schemaOf(reflect.TypeOf(route.Result))
+		params := append([]apiV2Param{}, route.Params...)
+		if route.Paginated {
+			data = map[string]interface{}{
+				"type":  "array",
+				"items": data,
+			}
+			params = append(params, apiV2PaginationParams...)
+		}
+
+		parameters := []interface{}{}
+		for _, param := range params {
+			parameters = append(parameters, openApiParameter(param))
+		}
+
+		envelope := map[string]interface{}{
+			"type":     "object",
+			"required": []string{"data", "meta"},
+			"properties": map[string]interface{}{
+				"data": data,
+				"meta": meta,
+			},
+		}
+
+		paths[openApiPath(API_V2_PREFIX+route.Path)] = map[string]interface{}{
+			"get": map[string]interface{}{
+				"operationId": route.Id,
+				"summary":     route.Summary,
+				"parameters":  parameters,
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": "OK",
+						"content":     openApiJsonContent(envelope),
+					},
+					"default": errorResponse,
+				},
+			},
+		}
+	}
+
+	spec := map[string]interface{}{
+		"openapi": OPENAPI_VERSION,
+		"info": map[string]interface{}{
+			"title":       "Alice-LG API v2",
+			"description": API_V2_DESCRIPTION,
+			"version":     version,
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": schemas.components,
+		},
+	}
+	return spec
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ecix/alice-lg/backend/api/v2"
+)
+
+func makeApiV2TestConfig() *Config {
+	return &Config{
+		Server: ServerConfig{
+			EnablePrefixLookup: true,
+		},
+		Sources: []SourceConfig{
+			SourceConfig{Id: 0, Name: "rs0.example.net", Group: "FRA"},
+			SourceConfig{Id: 1, Name: "rs1.example.net", Group: "FRA"},
+			SourceConfig{Id: 2, Name: "rs2.example.net", Group: "MUC"},
+		},
+	}
+}
+
+func TestApiV2RouteserversList(t *testing.T) {
+	AliceConfig = makeApiV2TestConfig()
+	defer func() { AliceConfig = nil }()
+
+	handle := makeV2Endpoint(apiV2RouteserversList)
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/v2/routeservers?limit=2&offset=1", nil)
+	handle(res, req, nil)
+
+	if res.Code != http.StatusOK {
+		t.Fatal("Expected 200, got:", res.Code)
+	}
+
+	response := struct {
+		Data []v2.Routeserver `json:"data"`
+		Meta v2.Meta          `json:"meta"`
+	}{}
+	if err := json.Unmarshal(res.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(response.Data) != 2 || response.Data[0].Id != "1" || response.Data[1].Id != "2" {
+		t.Error("Unexpected routeservers:", response.Data)
+	}
+	pagination := response.Meta.Pagination
+	if pagination == nil || pagination.Total != 3 || pagination.Limit != 2 || pagination.Offset != 1 {
+		t.Error("Unexpected pagination:", pagination)
+	}
+
+	// Durations are ISO 8601
+	if !strings.Contains(res.Body.String(), `"query_duration":"PT`) {
+		t.Error("Expected ISO 8601 query duration:", res.Body.String())
+	}
+}
+
+func TestWriteErrorResponseV2(t *testing.T) {
+	res := httptest.NewRecorder()
+	writeErrorResponseV2(res, NewRateLimitedError(30))
+
+	if res.Code != http.StatusTooManyRequests {
+		t.Error("Expected 429, got:", res.Code)
+	}
+	if res.Header().Get("Retry-After") != "30" {
+		t.Error("Expected Retry-After header, got:", res.Header())
+	}
+
+	response := v2.ErrorResponse{}
+	if err := json.Unmarshal(res.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	if response.Error.Code != ERROR_CODE_RATE_LIMITED {
+		t.Error("Unexpected error code:", response.Error.Code)
+	}
+	if response.Error.RetryAfter.String() != "PT30S" {
+		t.Error("Unexpected retry after:", response.Error.RetryAfter)
+	}
+}
+
+// Collect all references of the document
+func collectOpenApiRefs(value interface{}, refs map[string]bool) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if ref, ok := value.(string); ok && key == "$ref" {
+				refs[ref] = true
+			}
+			collectOpenApiRefs(value, refs)
+		}
+	case []interface{}:
+		for _, value := range v {
+			collectOpenApiRefs(value, refs)
+		}
+	}
+}
+
+func TestMakeOpenApiSpec(t *testing.T) {
+	AliceConfig = makeApiV2TestConfig()
+	defer func() { AliceConfig = nil }()
+
+	payload, err := json.Marshal(makeOpenApiSpec(apiV2Routes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spec := map[string]interface{}{}
+	json.Unmarshal(payload, &spec)
+
+	info := spec["info"].(map[string]interface{})
+	if !strings.Contains(info["description"].(string), "only available in the v1 api") {
+		t.Error("Expected v1 only endpoints to be described:", info)
+	}
+
+	paths := spec["paths"].(map[string]interface{})
+	for _, path := range []string{
+		"/api/v2/routeservers",
+		"/api/v2/routeservers/{id}/neighbours/{neighbourId}/routes",
+		"/api/v2/lookup/prefix",
+	} {
+		if _, ok := paths[path]; !ok {
+			t.Error("Expected path in spec:", path)
+		}
+	}
+
+	// All references must resolve
+	components := spec["components"].(map[string]interface{})
+	schemas := components["schemas"].(map[string]interface{})
+	refs := map[string]bool{}
+	collectOpenApiRefs(spec, refs)
+	for ref := range refs {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		if _, ok := schemas[name]; !ok {
+			t.Error("Unresolved reference:", ref)
+		}
+	}
+
+	neighbour := schemas["Neighbour"].(map[string]interface{})
+	properties := neighbour["properties"].(map[string]interface{})
+	uptime := properties["uptime"].(map[string]interface{})
+	if uptime["format"] != "duration" {
+		t.Error("Expected uptime to be a duration:", uptime)
+	}
+	if _, ok := properties["routeserver_id"]; !ok {
+		t.Error("Expected routeserver_id in neighbour:", properties)
+	}
+}
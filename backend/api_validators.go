@@ -1,55 +1,128 @@
 package main
 
 import (
-	"fmt"
+	"math"
+	"regexp"
 	"strconv"
+	"strings"
 
+	"net"
 	"net/http"
+
+	"github.com/ecix/alice-lg/backend/api"
 )
 
 // Helper: Validate source Id
 func validateSourceId(id string) (int, error) {
 	rsId, err := strconv.Atoi(id)
 	if err != nil {
-		return 0, err
+		return 0, NewInvalidParamError("Source id is not a number: %s", id)
 	}
 
 	if rsId < 0 {
-		return 0, fmt.Errorf("Source id may not be negative")
+		return 0, NewInvalidParamError("Source id may not be negative")
 	}
 	if rsId >= len(AliceConfig.Sources) {
-		return 0, fmt.Errorf("Source id not within [0, %d]", len(AliceConfig.Sources)-1)
+		return 0, NewNotFoundError("Source id not within [0, %d]", len(AliceConfig.Sources)-1)
 	}
 
 	return rsId, nil
 }
 
+// Neighbour ids are passed to the sources, e.g. as
+// part of an url: Only allow protocol names. Ids must
+// not start with a dot, "." and ".." are path segments.
+var neighbourIdPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.:-]{0,127}$`)
+
+// Helper: Validate neighbour id. If the neighbours
+// of the source are known, the neighbour must exist.
+func validateNeighbourId(rsId int, neighbourId string) (string, error) {
+	if !neighbourIdPattern.MatchString(neighbourId) {
+		return "", NewInvalidParamError("Invalid neighbour id: %q", neighbourId)
+	}
+
+	if AliceNeighboursStore != nil {
+		found, known := AliceNeighboursStore.HasNeighbourAt(rsId, neighbourId)
+		if known && !found {
+			return "", NewNotFoundError("Neighbour not found: %s", neighbourId)
+		}
+	}
+
+	return neighbourId, nil
+}
+
 // Helper: Validate query string
 func validateQueryString(req *http.Request, key string) (string, error) {
 	query := req.URL.Query()
 	values, ok := query[key]
 	if !ok {
-		return "", fmt.Errorf("Query param %s is missing.", key)
+		return "", NewInvalidParamError("Query param %s is missing.", key)
 	}
 
 	if len(values) != 1 {
-		return "", fmt.Errorf("Query param %s is ambigous.", key)
+		return "", NewInvalidParamError("Query param %s is ambigous.", key)
 	}
 
 	value := values[0]
 	if value == "" {
-		return "", fmt.Errorf("Query param %s may not be empty.", key)
+		return "", NewInvalidParamError("Query param %s may not be empty.", key)
 	}
 
 	return value, nil
 }
 
+// Get the pairs of sources to compare: Either a and b,
+// or all sources of a group compared to the first one.
+func validateCompareSources(req *http.Request) ([][2]int, error) {
+	group := req.URL.Query().Get("group")
+	if group != "" {
+		sourceIds := []int{}
+		for _, source := range AliceConfig.Sources {
+			if source.Group == group {
+				sourceIds = append(sourceIds, source.Id)
+			}
+		}
+		if len(sourceIds) < 2 {
+			return nil, NewInvalidParamError("Group %s has less than two sources.", group)
+		}
+
+		pairs := [][2]int{}
+		for _, id := range sourceIds[1:] {
+			pairs = append(pairs, [2]int{sourceIds[0], id})
+		}
+		return pairs, nil
+	}
+
+	a, err := validateQueryString(req, "a")
+	if err != nil {
+		return nil, err
+	}
+	b, err := validateQueryString(req, "b")
+	if err != nil {
+		return nil, err
+	}
+
+	sourceA, err := validateSourceId(a)
+	if err != nil {
+		return nil, err
+	}
+	sourceB, err := validateSourceId(b)
+	if err != nil {
+		return nil, err
+	}
+	if sourceA == sourceB {
+		return nil, NewInvalidParamError("Can not compare a source with itself.")
+	}
+
+	return [][2]int{{sourceA, sourceB}}, nil
+}
+
 // Helper: Validate prefix query
 func validatePrefixQuery(value string) (string, error) {
 
 	// We should at least provide 2 chars
 	if len(value) < 2 {
-		return "", fmt.Errorf("Query too short")
+		return "", NewInvalidParamError("Query too short")
 	}
 
 	// Query constraints: Should at least include a dot or colon
@@ -57,7 +130,7 @@ func validatePrefixQuery(value string) (string, error) {
 
 	if strings.Index(value, ".") == -1 &&
 		strings.Index(value, ":") == -1 {
-		return "", fmt.Errorf("Query needs at least a ':' or '.'")
+		return "", NewInvalidParamError("Query needs at least a ':' or '.'")
 	}
 	*/
 
@@ -77,6 +150,9 @@ func validatePaginationParams(req *http.Request, limit, offset int) (int, int, e
 	if ok {
 		offset, _ = strconv.Atoi(queryOffset[0])
 	}
+	if offset < 0 {
+		return 0, 0, NewInvalidParamError("Query param offset may not be negative.")
+	}
 
 	// Cap limit to [1, 1000]
 	if limit < 1 {
@@ -88,3 +164,100 @@ func validatePaginationParams(req *http.Request, limit, offset int) (int, int, e
 
 	return limit, offset, nil
 }
+
+// Get pagination parameters of endpoints supporting exports:
+// Exports are complete, unless a limit is requested.
+func validateExportPaginationParams(req *http.Request, limit, offset int) (int, int, error) {
+	_, ok := req.URL.Query()["limit"]
+	if ok || !isExportRequest(req) {
+		return validatePaginationParams(req, limit, offset)
+	}
+
+	_, offset, err := validatePaginationParams(req, limit, offset)
+	return math.MaxInt32, offset, err
+}
+
+// Helper: Get the bounds of the requested page. The offset
+// is clamped to the total first, the limit of exports would
+// overflow otherwise.
+func paginate(total, limit, offset int) (int, int) {
+	if offset > total {
+		offset = total
+	}
+	end := total
+	if limit < total-offset {
+		end = offset + limit
+	}
+	return offset, end
+}
+
+// Get optional RPKI state filter
+func validateRpkiStateParam(req *http.Request) (string, error) {
+	state := req.URL.Query().Get("rpki")
+	switch state {
+	case "", api.RPKI_VALID, api.RPKI_INVALID, api.RPKI_NOT_FOUND:
+		return state, nil
+	}
+	return "", NewInvalidParamError("Unknown RPKI state: %s", state)
+}
+
+// Helper: Get optional integer query param
+func validateIntParam(req *http.Request, key string, fallback int) (int, error) {
+	value := req.URL.Query().Get(key)
+	if value == "" {
+		return fallback, nil
+	}
+
+	result, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, NewInvalidParamError("Query param %s is not a number.", key)
+	}
+
+	return result, nil
+}
+
+// Build structured neighbours query from request
+func validateNeighboursQuery(req *http.Request) (NeighboursQuery, error) {
+	params := req.URL.Query()
+	query := NeighboursQuery{
+		State:       params.Get("state"),
+		Description: params.Get("description"),
+		Group:       params.Get("group"),
+	}
+
+	asn, err := validateIntParam(req, "asn", 0)
+	if err != nil {
+		return query, err
+	}
+	query.Asn = asn
+
+	// Address: Either an IP address or a network in CIDR notation
+	address := params.Get("address")
+	if strings.Contains(address, "/") {
+		_, network, err := net.ParseCIDR(address)
+		if err != nil {
+			return query, NewInvalidParamError("Query param address is not a valid network.")
+		}
+		query.Network = network
+	} else if address != "" {
+		if net.ParseIP(address) == nil {
+			return query, NewInvalidParamError("Query param address is not a valid IP address.")
+		}
+		query.Address = address
+	}
+
+	query.MinRoutesReceived, err = validateIntParam(req, "routes_received_min", 0)
+	if err != nil {
+		return query, err
+	}
+	query.MaxRoutesReceived, err = validateIntParam(req, "routes_received_max", -1)
+	if err != nil {
+		return query, err
+	}
+	query.MinImportLimitUsage, err = validateIntParam(req, "import_limit_usage_min", 0)
+	if err != nil {
+		return query, err
+	}
+
+	return query, nil
+}
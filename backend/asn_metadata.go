@@ -0,0 +1,261 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+// ASN Metadata
+//
+// AS names and organisations are loaded from a local
+// dataset. Supported formats are:
+//
+//    peeringdb  A PeeringDB JSON dump or /api/net response
+//    as2org     The CAIDA AS to Organization mapping
+//
+
+const ASN_METADATA_PEERINGDB = "peeringdb"
+const ASN_METADATA_AS2ORG = "as2org"
+
+type AsnMetadataIndex map[int]api.AsInfo
+
+type AsnMetadata struct {
+	config AsnMetadataConfig
+
+	index    AsnMetadataIndex
+	reloader *FileReloader
+
+	rwlock *sync.RWMutex
+}
+
+func NewAsnMetadata(config AsnMetadataConfig) *AsnMetadata {
+	metadata := &AsnMetadata{
+		config: config,
+		index:  make(AsnMetadataIndex),
+
+		rwlock: &sync.RWMutex{},
+	}
+	metadata.reloader = NewFileReloader("ASN metadata",
+		[]string{config.File}, config.RefreshInterval, metadata.load)
+	return metadata
+}
+
+func (self *AsnMetadata) Start(ctx context.Context) {
+	log.Println("Starting ASN metadata using:", self.config.File)
+
+	// Load the dataset before the stores start to fetch data.
+	self.reloader.Start(ctx)
+
+	// Initial logging
+	self.Stats().Log()
+}
+
+// Load the dataset in the configured format
+func (self *AsnMetadata) load() error {
+	payload, err := ioutil.ReadFile(self.config.File)
+	if err != nil {
+		return err
+	}
+
+	var index AsnMetadataIndex
+	switch self.config.Format {
+	case ASN_METADATA_PEERINGDB:
+		index, err = parsePeeringdbDump(payload)
+	case ASN_METADATA_AS2ORG:
+		index, err = parseAs2org(payload)
+	default:
+		err = fmt.Errorf("Unsupported ASN metadata format: %s", self.config.Format)
+	}
+	if err != nil {
+		return err
+	}
+
+	self.rwlock.Lock()
+	self.index = index
+	self.rwlock.Unlock()
+
+	return nil
+}
+
+// Get AS info, the ASN is always set
+func (self *AsnMetadata) Lookup(asn int) api.AsInfo {
+	self.rwlock.RLock()
+	info, ok := self.index[asn]
+	self.rwlock.RUnlock()
+
+	if !ok {
+		return api.AsInfo{Asn: asn}
+	}
+	return info
+}
+
+// Add AS name and organisation to neighbours
+func (self *AsnMetadata) AnnotateNeighbours(neighbours []api.Neighbour) {
+	for i, neighbour := range neighbours {
+		info := self.Lookup(neighbour.Asn)
+		neighbours[i].AsName = info.Name
+		neighbours[i].Organisation = info.Organisation
+	}
+}
+
+// Add AS info for every hop in the AS path
+func (self *AsnMetadata) AnnotateRoutes(routes []api.Route) {
+	for i, route := range routes {
+		pathInfo := make([]api.AsInfo, 0, len(route.Bgp.AsPath))
+		for _, asn := range route.Bgp.AsPath {
+			pathInfo = append(pathInfo, self.Lookup(asn))
+		}
+		routes[i].Bgp.AsPathInfo = pathInfo
+	}
+}
+
+// Build some stats for monitoring
+func (self *AsnMetadata) Stats() AsnMetadataStats {
+	status := self.reloader.Status()
+
+	self.rwlock.RLock()
+	defer self.rwlock.RUnlock()
+
+	lastError := ""
+	if status.LastError != nil {
+		lastError = status.LastError.Error()
+	}
+
+	return AsnMetadataStats{
+		File:      self.config.File,
+		Format:    self.config.Format,
+		State:     stateToString(status.State),
+		Asns:      len(self.index),
+		UpdatedAt: status.LastRefresh,
+		LastError: lastError,
+	}
+}
+
+// PeeringDB
+
+type peeringdbNet struct {
+	Asn   int    `json:"asn"`
+	Name  string `json:"name"`
+	OrgId int    `json:"org_id"`
+}
+
+type peeringdbOrg struct {
+	Id      int    `json:"id"`
+	Name    string `json:"name"`
+	Country string `json:"country"`
+}
+
+type peeringdbDump struct {
+	// Full dump
+	Net struct {
+		Data []peeringdbNet `json:"data"`
+	} `json:"net"`
+	Org struct {
+		Data []peeringdbOrg `json:"data"`
+	} `json:"org"`
+
+	// API response
+	Data []peeringdbNet `json:"data"`
+}
+
+// Parse PeeringDB dump, organisations are optional
+func parsePeeringdbDump(payload []byte) (AsnMetadataIndex, error) {
+	dump := peeringdbDump{}
+	err := json.Unmarshal(payload, &dump)
+	if err != nil {
+		return nil, err
+	}
+
+	orgs := make(map[int]peeringdbOrg)
+	for _, org := range dump.Org.Data {
+		orgs[org.Id] = org
+	}
+
+	index := make(AsnMetadataIndex)
+	nets := append(dump.Net.Data, dump.Data...)
+	for _, net := range nets {
+		org := orgs[net.OrgId]
+		index[net.Asn] = api.AsInfo{
+			Asn:          net.Asn,
+			Name:         net.Name,
+			Organisation: org.Name,
+			Country:      org.Country,
+		}
+	}
+
+	return index, nil
+}
+
+// CAIDA AS Organizations
+//
+// The file consists of two sections, introduced by
+// a format comment:
+//
+//	# format:org_id|changed|org_name|country|source
+//	# format:aut|changed|aut_name|org_id|opaque_id|source
+func parseAs2org(payload []byte) (AsnMetadataIndex, error) {
+	orgs := make(map[string]api.AsInfo)
+	auts := make(map[int]string) // asn -> org_id
+	index := make(AsnMetadataIndex)
+
+	section := ""
+	scanner := bufio.NewScanner(bytes.NewReader(payload))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "# format:") {
+			section = strings.SplitN(line[9:], "|", 2)[0]
+			continue
+		}
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		fields := strings.Split(line, "|")
+		if len(fields) < 4 {
+			continue
+		}
+
+		switch section {
+		case "org_id":
+			orgs[fields[0]] = api.AsInfo{
+				Organisation: fields[2],
+				Country:      fields[3],
+			}
+		case "aut":
+			asn, err := strconv.Atoi(fields[0])
+			if err != nil {
+				return nil, err
+			}
+			auts[asn] = fields[3]
+			index[asn] = api.AsInfo{
+				Asn:  asn,
+				Name: fields[2],
+			}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// Resolve organisations
+	for asn, orgId := range auts {
+		info := index[asn]
+		org := orgs[orgId]
+		info.Organisation = org.Organisation
+		info.Country = org.Country
+		index[asn] = info
+	}
+
+	return index, nil
+}
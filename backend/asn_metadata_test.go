@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+)
+
+const PEERINGDB_DUMP = `
+{"net": {"data": [
+   {"id": 1, "asn": 31078, "name": "Netsign", "org_id": 23},
+   {"id": 2, "asn": 25074, "name": "MESH", "org_id": 42}]},
+ "org": {"data": [
+   {"id": 23, "name": "Netsign GmbH", "country": "DE"}]}}`
+
+const AS2ORG_FILE = `# name: AS Org
+# format:org_id|changed|org_name|country|source
+ORG-NG1-RIPE|20170101|Netsign GmbH|DE|RIPE
+# format:aut|changed|aut_name|org_id|opaque_id|source
+31078|20170101|NETSIGN-AS|ORG-NG1-RIPE|foo|RIPE
+25074|20170101|MESH-AS|ORG-UNKNOWN|foo|RIPE
+`
+
+func TestParsePeeringdbDump(t *testing.T) {
+	index, err := parsePeeringdbDump([]byte(PEERINGDB_DUMP))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info := index[31078]
+	if info.Name != "Netsign" || info.Organisation != "Netsign GmbH" {
+		t.Error("Unexpected AS info:", info)
+	}
+	if info.Country != "DE" {
+		t.Error("Expected country DE, got:", info.Country)
+	}
+
+	// Organisation unknown
+	info = index[25074]
+	if info.Name != "MESH" || info.Organisation != "" {
+		t.Error("Unexpected AS info:", info)
+	}
+
+	// API response
+	index, err = parsePeeringdbDump([]byte(`{"data": [{"asn": 23, "name": "Foo"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if index[23].Name != "Foo" {
+		t.Error("Expected AS23 to be Foo, got:", index[23])
+	}
+}
+
+func TestParseAs2org(t *testing.T) {
+	index, err := parseAs2org([]byte(AS2ORG_FILE))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(index) != 2 {
+		t.Error("Expected 2 ASNs, got:", len(index))
+	}
+
+	info := index[31078]
+	if info.Name != "NETSIGN-AS" || info.Organisation != "Netsign GmbH" {
+		t.Error("Unexpected AS info:", info)
+	}
+}
+
+func TestAsnMetadataLookup(t *testing.T) {
+	metadata := NewAsnMetadata(AsnMetadataConfig{})
+	metadata.index, _ = parseAs2org([]byte(AS2ORG_FILE))
+
+	info := metadata.Lookup(31078)
+	if info.Asn != 31078 || info.Name != "NETSIGN-AS" {
+		t.Error("Unexpected AS info:", info)
+	}
+
+	info = metadata.Lookup(23)
+	if info.Asn != 23 || info.Name != "" {
+		t.Error("Unknown ASN should only have the ASN set:", info)
+	}
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+// BGP Communities
+//
+// A catalogue of labels for standard, extended and large
+// communities. Patterns may contain wildcards, which are
+// substituted in the label in order of their occurrence:
+//
+//    65000:0      = Do not announce to any peer
+//    0:*          = Do not announce to AS*
+//    rt:65000:*   = Route target *
+//    9033:65666:* = Rejected, reason: *
+//
+
+const BGP_COMMUNITY_WILDCARD = "*"
+
+type BgpCommunityLabel struct {
+	Pattern []string
+	Label   string
+}
+
+type BgpCommunities []BgpCommunityLabel
+
+// Parse a community pattern from the config,
+// e.g. 0:* or rt:65000:1
+func parseBgpCommunityPattern(pattern string) []string {
+	parts := strings.Split(strings.TrimSpace(pattern), ":")
+	for i, part := range parts {
+		parts[i] = strings.ToLower(strings.TrimSpace(part))
+	}
+	return parts
+}
+
+// Add a label to the catalogue
+func (self BgpCommunities) Add(pattern, label string) BgpCommunities {
+	return append(self, BgpCommunityLabel{
+		Pattern: parseBgpCommunityPattern(pattern),
+		Label:   label,
+	})
+}
+
+// Match community against pattern, returns the values
+// matched by wildcards or false.
+func (self BgpCommunityLabel) match(community []string) ([]string, bool) {
+	if len(self.Pattern) != len(community) {
+		return nil, false
+	}
+
+	values := []string{}
+	for i, part := range self.Pattern {
+		if part == BGP_COMMUNITY_WILDCARD {
+			values = append(values, community[i])
+			continue
+		}
+		if part != community[i] {
+			return nil, false
+		}
+	}
+	return values, true
+}
+
+// Find the label for a community. When multiple patterns
+// match, the one with the least wildcards wins.
+func (self BgpCommunities) Lookup(community []string) (string, bool) {
+	best := -1
+	bestWildcards := 0
+	var bestValues []string
+
+	for i, entry := range self {
+		values, ok := entry.match(community)
+		if !ok {
+			continue
+		}
+		if best == -1 || len(values) < bestWildcards {
+			best = i
+			bestWildcards = len(values)
+			bestValues = values
+		}
+	}
+
+	if best == -1 {
+		return "", false
+	}
+
+	// Substitute wildcards
+	label := self[best].Label
+	for _, value := range bestValues {
+		label = strings.Replace(label, BGP_COMMUNITY_WILDCARD, value, 1)
+	}
+
+	return label, true
+}
+
+// Serialize catalogue for the config endpoint
+func (self BgpCommunities) Serialize() map[string]string {
+	result := make(map[string]string)
+	for _, entry := range self {
+		result[strings.Join(entry.Pattern, ":")] = entry.Label
+	}
+	return result
+}
+
+// Make a list of community parts from a standard
+// or large community
+func bgpCommunityParts(community api.Community) []string {
+	parts := make([]string, 0, len(community))
+	for _, value := range community {
+		parts = append(parts, strconv.Itoa(value))
+	}
+	return parts
+}
+
+// Label all communities of a route
+func (self BgpCommunities) LabelRoute(route api.Route) []api.CommunityLabel {
+	labels := []api.CommunityLabel{}
+
+	communities := [][]string{}
+	for _, c := range route.Bgp.Communities {
+		communities = append(communities, bgpCommunityParts(c))
+	}
+	for _, c := range route.Bgp.ExtCommunities {
+		communities = append(communities, []string(c))
+	}
+	for _, c := range route.Bgp.LargeCommunities {
+		communities = append(communities, bgpCommunityParts(c))
+	}
+
+	for _, community := range communities {
+		label, ok := self.Lookup(community)
+		if !ok {
+			continue
+		}
+		labels = append(labels, api.CommunityLabel{
+			Community: strings.Join(community, ":"),
+			Label:     label,
+		})
+	}
+
+	return labels
+}
+
+// Set community labels on a list of routes
+func (self BgpCommunities) AnnotateRoutes(routes []api.Route) {
+	for i, route := range routes {
+		routes[i].CommunityLabels = self.LabelRoute(route)
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+func makeBgpCommunities() BgpCommunities {
+	return BgpCommunities{}.
+		Add("65000:0", "Do not announce to any peer").
+		Add("65000:*", "Announce to AS*").
+		Add("0:*", "Do not announce to AS*").
+		Add("RT:65000:*", "Route target *").
+		Add("9033:*:*", "Function * with *").
+		Add("9033:65666:*", "Rejected, reason: *")
+}
+
+func TestBgpCommunitiesLookup(t *testing.T) {
+	communities := makeBgpCommunities()
+
+	expected := []struct {
+		community []string
+		label     string
+	}{
+		{[]string{"65000", "0"}, "Do not announce to any peer"},
+		{[]string{"65000", "2342"}, "Announce to AS2342"},
+		{[]string{"0", "6695"}, "Do not announce to AS6695"},
+		{[]string{"rt", "65000", "23"}, "Route target 23"},
+		{[]string{"9033", "65666", "9"}, "Rejected, reason: 9"},
+		{[]string{"9033", "1", "2"}, "Function 1 with 2"},
+	}
+
+	for _, e := range expected {
+		label, ok := communities.Lookup(e.community)
+		if !ok {
+			t.Error("Expected", e.community, "to have a label")
+		}
+		if label != e.label {
+			t.Error("Expected label:", e.label, "got:", label)
+		}
+	}
+
+	_, ok := communities.Lookup([]string{"23", "42"})
+	if ok {
+		t.Error("Expected 23:42 not to have a label")
+	}
+}
+
+func TestBgpCommunitiesLabelRoute(t *testing.T) {
+	communities := makeBgpCommunities()
+
+	route := api.Route{
+		Bgp: api.BgpInfo{
+			Communities: []api.Community{
+				api.Community{0, 6695},
+				api.Community{23, 42},
+			},
+			ExtCommunities: []api.ExtCommunity{
+				api.ExtCommunity{"rt", "65000", "1"},
+			},
+			LargeCommunities: []api.Community{
+				api.Community{9033, 65666, 9},
+			},
+		},
+	}
+
+	labels := communities.LabelRoute(route)
+	if len(labels) != 3 {
+		t.Fatal("Expected 3 labels, got:", labels)
+	}
+
+	if labels[0].Community != "0:6695" ||
+		labels[0].Label != "Do not announce to AS6695" {
+		t.Error("Unexpected label:", labels[0])
+	}
+	if labels[1].Community != "rt:65000:1" {
+		t.Error("Unexpected label:", labels[1])
+	}
+	if labels[2].Label != "Rejected, reason: 9" {
+		t.Error("Unexpected label:", labels[2])
+	}
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+// Bogon detection
+//
+// Flag routes with bogon prefixes or bogon ASNs in the
+// AS path. This is a safety net on top of the route
+// server filtering.
+
+var DEFAULT_BOGON_PREFIXES = []string{
+	// IPv4
+	"0.0.0.0/8",       // RFC 1122 'this' network
+	"10.0.0.0/8",      // RFC 1918 private space
+	"100.64.0.0/10",   // RFC 6598 Carrier grade nat space
+	"127.0.0.0/8",     // RFC 1122 localhost
+	"169.254.0.0/16",  // RFC 3927 link local
+	"172.16.0.0/12",   // RFC 1918 private space
+	"192.0.2.0/24",    // RFC 5737 TEST-NET-1
+	"192.88.99.0/24",  // RFC 7526 6to4 anycast relay
+	"192.168.0.0/16",  // RFC 1918 private space
+	"198.18.0.0/15",   // RFC 2544 benchmarking
+	"198.51.100.0/24", // RFC 5737 TEST-NET-2
+	"203.0.113.0/24",  // RFC 5737 TEST-NET-3
+	"224.0.0.0/4",     // multicast
+	"240.0.0.0/4",     // reserved
+
+	// IPv6
+	"::/8",          // RFC 4291 IPv4-compatible, loopback, et al
+	"0100::/64",     // RFC 6666 Discard-Only
+	"2001:2::/48",   // RFC 5180 BMWG
+	"2001:10::/28",  // RFC 4843 ORCHID
+	"2001:db8::/32", // RFC 3849 documentation
+	"2002::/16",     // RFC 7526 6to4 anycast relay
+	"3ffe::/16",     // RFC 3701 old 6bone
+	"fc00::/7",      // RFC 4193 unique local unicast
+	"fe80::/10",     // RFC 4291 link local unicast
+	"fec0::/10",     // RFC 3879 old site local unicast
+	"ff00::/8",      // RFC 4291 multicast
+}
+
+var DEFAULT_BOGON_ASNS = []string{
+	"0",                     // RFC 7607
+	"23456",                 // RFC 4893 AS_TRANS
+	"64496-64511",           // RFC 5398 documentation
+	"64512-65534",           // RFC 6996 private
+	"65535",                 // RFC 7300 last 16 bit ASN
+	"65536-65551",           // RFC 5398 documentation
+	"65552-131071",          // IANA reserved
+	"4200000000-4294967294", // RFC 6996 private
+	"4294967295",            // RFC 7300 last 32 bit ASN
+}
+
+// Globally routed IPv6 unicast space
+var IPV6_GLOBAL_UNICAST = mustParseCIDR("2000::/3")
+
+type asnRange struct {
+	from int
+	to   int
+}
+
+type BogonDetector struct {
+	prefixes       []*net.IPNet
+	asns           []asnRange
+	ipv6GlobalOnly bool
+}
+
+func NewBogonDetector(config BogonsConfig) (*BogonDetector, error) {
+	detector := &BogonDetector{
+		ipv6GlobalOnly: config.Ipv6GlobalOnly,
+	}
+
+	prefixes := config.Prefixes
+	if len(prefixes) == 0 {
+		prefixes = DEFAULT_BOGON_PREFIXES
+	}
+	for _, prefix := range prefixes {
+		_, network, err := net.ParseCIDR(prefix)
+		if err != nil {
+			return nil, err
+		}
+		detector.prefixes = append(detector.prefixes, network)
+	}
+
+	asns := config.Asns
+	if len(asns) == 0 {
+		asns = DEFAULT_BOGON_ASNS
+	}
+	for _, asns := range asns {
+		r, err := parseAsnRange(asns)
+		if err != nil {
+			return nil, err
+		}
+		detector.asns = append(detector.asns, r)
+	}
+
+	return detector, nil
+}
+
+// Check if the prefix is covered by a bogon,
+// returns the matching bogon prefix.
+func (self *BogonDetector) BogonPrefix(prefix string) (string, bool) {
+	ip, network, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return "", false
+	}
+	length, _ := network.Mask.Size()
+
+	for _, bogon := range self.prefixes {
+		bogonLength, _ := bogon.Mask.Size()
+		if bogon.Contains(ip) && length >= bogonLength {
+			return bogon.String(), true
+		}
+	}
+
+	if self.ipv6GlobalOnly && ip.To4() == nil &&
+		!IPV6_GLOBAL_UNICAST.Contains(ip) {
+		return IPV6_GLOBAL_UNICAST.String(), true
+	}
+
+	return "", false
+}
+
+// Check if ASN is a bogon
+func (self *BogonDetector) IsBogonAsn(asn int) bool {
+	for _, r := range self.asns {
+		if asn >= r.from && asn <= r.to {
+			return true
+		}
+	}
+	return false
+}
+
+// Get warnings for a route
+func (self *BogonDetector) Check(route api.Route) []string {
+	warnings := []string{}
+
+	if bogon, ok := self.BogonPrefix(route.Network); ok {
+		warnings = append(warnings,
+			fmt.Sprintf("Bogon prefix: %s is covered by %s", route.Network, bogon))
+	}
+
+	// Prepended ASNs are reported once
+	reported := make(map[int]bool)
+	for _, asn := range route.Bgp.AsPath {
+		if reported[asn] || !self.IsBogonAsn(asn) {
+			continue
+		}
+		reported[asn] = true
+		warnings = append(warnings,
+			fmt.Sprintf("Bogon ASN in AS path: %d", asn))
+	}
+
+	return warnings
+}
+
+// Add warnings to a list of routes
+func (self *BogonDetector) AnnotateRoutes(routes []api.Route) {
+	for i, route := range routes {
+		warnings := self.Check(route)
+		if len(warnings) == 0 {
+			continue
+		}
+		routes[i].Warnings = append(routes[i].Warnings, warnings...)
+	}
+}
+
+// Parse an ASN or a range of ASNs: 64512-65534
+func parseAsnRange(value string) (asnRange, error) {
+	bounds := strings.SplitN(strings.TrimSpace(value), "-", 2)
+
+	from, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+	if err != nil {
+		return asnRange{}, err
+	}
+	to := from
+	if len(bounds) == 2 {
+		to, err = strconv.Atoi(strings.TrimSpace(bounds[1]))
+		if err != nil {
+			return asnRange{}, err
+		}
+	}
+
+	if to < from {
+		return asnRange{}, fmt.Errorf("Invalid ASN range: %s", value)
+	}
+
+	return asnRange{from: from, to: to}, nil
+}
+
+func mustParseCIDR(prefix string) *net.IPNet {
+	_, network, err := net.ParseCIDR(prefix)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+func TestParseAsnRange(t *testing.T) {
+	r, err := parseAsnRange("64512-65534")
+	if err != nil {
+		t.Error(err)
+	}
+	if r.from != 64512 || r.to != 65534 {
+		t.Error("Unexpected range:", r)
+	}
+
+	r, err = parseAsnRange(" 23456 ")
+	if err != nil {
+		t.Error(err)
+	}
+	if r.from != 23456 || r.to != 23456 {
+		t.Error("Unexpected range:", r)
+	}
+
+	_, err = parseAsnRange("65534-64512")
+	if err == nil {
+		t.Error("Expected error for inverted range")
+	}
+}
+
+func TestBogonPrefix(t *testing.T) {
+	detector, err := NewBogonDetector(BogonsConfig{Ipv6GlobalOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		prefix string
+		bogon  bool
+	}{
+		{"10.23.42.0/24", true},
+		{"100.64.0.0/10", true},
+		{"100.0.0.0/8", false}, // covers, but is not covered by a bogon
+		{"193.200.230.0/24", false},
+		{"2001:db8:23::/48", true},
+		{"2a02:2b8::/32", false},
+		{"4000::/16", true}, // not global unicast
+	}
+
+	for _, e := range expected {
+		_, bogon := detector.BogonPrefix(e.prefix)
+		if bogon != e.bogon {
+			t.Error("Expected", e.prefix, "to be bogon:", e.bogon)
+		}
+	}
+}
+
+func TestBogonCheck(t *testing.T) {
+	detector, err := NewBogonDetector(BogonsConfig{
+		Prefixes: []string{"192.168.0.0/16"},
+		Asns:     []string{"64512-65534"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	route := api.Route{
+		Network: "192.168.23.0/24",
+		Bgp: api.BgpInfo{
+			AsPath: []int{31078, 64512, 64512, 64512, 23456}, // prepended
+		},
+	}
+
+	warnings := detector.Check(route)
+	if len(warnings) != 2 {
+		t.Error("Expected 2 warnings without duplicates, got:", warnings)
+	}
+
+	// AS_TRANS is not in the configured list
+	if detector.IsBogonAsn(23456) {
+		t.Error("Configured ASNs should replace the defaults")
+	}
+
+	routes := []api.Route{route}
+	detector.AnnotateRoutes(routes)
+	if len(routes[0].Warnings) != 2 {
+		t.Error("Expected route to be annotated:", routes[0].Warnings)
+	}
+}
+
+func TestLookupBogonsOrder(t *testing.T) {
+	detector, err := NewBogonDetector(BogonsConfig{
+		Prefixes: []string{"192.168.0.0/16"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store := makeRoutesExportTestStore()
+	AliceNeighboursStore = NewNeighboursStore(AliceConfig)
+	defer func() {
+		AliceNeighboursStore = nil
+		AliceConfig = nil
+	}()
+	store.routesMap[0] = api.RoutesResponse{
+		Imported: []api.Route{
+			{Id: "2", NeighbourId: "ID2_AS23", Network: "192.168.0.0/16"},
+			{Id: "2", NeighbourId: "ID1_AS2342", Network: "192.168.0.0/16"},
+			{Id: "1", NeighbourId: "ID2_AS23", Network: "192.168.0.0/16"},
+		},
+	}
+
+	routes := store.LookupBogons(detector)
+	order := []string{}
+	for _, route := range routes {
+		order = append(order, route.NeighbourId+"/"+route.Id)
+	}
+	if strings.Join(order, ",") != "ID1_AS2342/2,ID2_AS23/1,ID2_AS23/2" {
+		t.Error("Unexpected order:", order)
+	}
+}
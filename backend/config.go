@@ -1,10 +1,13 @@
 package main
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ecix/alice-lg/backend/sources"
 	"github.com/ecix/alice-lg/backend/sources/birdwatcher"
@@ -16,9 +19,163 @@ import (
 const SOURCE_UNKNOWN = 0
 const SOURCE_BIRDWATCHER = 1
 
+// Requests to a source for a single api call, e.g. the
+// routes of a neighbour are imported, filtered and not exported.
+const SOURCE_REQUESTS_PER_CALL = 3
+
+// Time to respond after the request timed out
+const REQUEST_TIMEOUT_MARGIN = 10 * time.Second
+
 type ServerConfig struct {
 	Listen             string `ini:"listen_http"`
 	EnablePrefixLookup bool   `ini:"enable_prefix_lookup"`
+
+	// TLS is terminated on the https listener, the
+	// certificate is reloaded when the files change.
+	ListenHttps   string `ini:"listen_https"`
+	TlsCert       string `ini:"tls_cert"`
+	TlsKey        string `ini:"tls_key"`
+	RedirectHttps bool   `ini:"redirect_https"`
+	Http2         bool   `ini:"http2"`
+
+	// Local reverse proxies
+	ListenUnix string `ini:"listen_unix"`
+
+	// Metrics and admin endpoints
+	ListenInternal string `ini:"listen_internal"`
+
+	ReadHeaderTimeout time.Duration `ini:"read_header_timeout"`
+	ReadTimeout       time.Duration `ini:"read_timeout"`
+	WriteTimeout      time.Duration `ini:"write_timeout"`
+	IdleTimeout       time.Duration `ini:"idle_timeout"`
+	RequestTimeout    time.Duration `ini:"request_timeout"`
+	MaxHeaderBytes    int           `ini:"max_header_bytes"`
+
+	// Time to drain connections on shutdown
+	ShutdownTimeout time.Duration `ini:"shutdown_timeout"`
+
+	// Stores are written on shutdown and restored on start
+	SnapshotFile string `ini:"snapshot_file"`
+}
+
+type RpkiConfig struct {
+	Enabled         bool          `ini:"enabled"`
+	VrpsFile        string        `ini:"vrps_file"`
+	RefreshInterval time.Duration `ini:"refresh_interval"`
+}
+
+type IrrConfig struct {
+	Enabled         bool          `ini:"enabled"`
+	Files           []string      `ini:"files" delim:","`
+	RefreshInterval time.Duration `ini:"refresh_interval"`
+
+	// Neighbour ASN -> AS-SET
+	AsSets map[int]string
+}
+
+type AsnMetadataConfig struct {
+	Enabled         bool          `ini:"enabled"`
+	File            string        `ini:"file"`
+	Format          string        `ini:"format"` // peeringdb, as2org
+	RefreshInterval time.Duration `ini:"refresh_interval"`
+}
+
+type BogonsConfig struct {
+	Enabled        bool     `ini:"enabled"`
+	Prefixes       []string `ini:"prefixes" delim:","`
+	Asns           []string `ini:"asns" delim:","`
+	Ipv6GlobalOnly bool     `ini:"ipv6_global_only"`
+}
+
+type RedactionConfig struct {
+	Enabled bool `ini:"enabled"`
+
+	// Detail keys: If the allowlist is empty, all
+	// keys not in the denylist are kept.
+	DetailsAllow []string `ini:"details_allow" delim:","`
+	DetailsDeny  []string `ini:"details_deny" delim:","`
+
+	// Hide neighbours and their routes
+	HideAsns       []int    `ini:"hide_asns" delim:","`
+	HideNeighbours []string `ini:"hide_neighbours" delim:","` // glob patterns
+
+	// Replace IP addresses in details
+	MaskAddresses bool `ini:"mask_addresses"`
+}
+
+type RateLimitConfig struct {
+	Enabled bool `ini:"enabled"`
+
+	// Budgets per client: Requests per second and burst
+	Rate           float64 `ini:"rate"`
+	Burst          int     `ini:"burst"`
+	ExpensiveRate  float64 `ini:"expensive_rate"`
+	ExpensiveBurst int     `ini:"expensive_burst"`
+
+	// Use the client address from the header,
+	// if the request is from a trusted proxy
+	TrustedProxies []string `ini:"trusted_proxies" delim:","`
+	ProxyHeader    string   `ini:"proxy_header"`
+}
+
+// Access tiers of api keys
+type AccessTierConfig struct {
+	Name string
+
+	// Budgets of the key, unlimited if not set
+	Rate           float64 `ini:"rate"`
+	Burst          int     `ini:"burst"`
+	ExpensiveRate  float64 `ini:"expensive_rate"`
+	ExpensiveBurst int     `ini:"expensive_burst"`
+
+	Details bool `ini:"details"` // Do not redact details
+	Admin   bool `ini:"admin"`   // Access to admin endpoints
+}
+
+type ApiKeyConfig struct {
+	Name string
+
+	Hash string `ini:"hash"` // sha256 of the key, hex encoded
+	Tier string `ini:"tier"`
+}
+
+type AccessConfig struct {
+	Enabled bool `ini:"enabled"`
+
+	// Keys may be kept in a separate file
+	KeyFile string `ini:"key_file"`
+
+	Tiers map[string]AccessTierConfig
+	Keys  []ApiKeyConfig
+}
+
+type ExportConfig struct {
+	Enabled bool `ini:"enabled"`
+
+	// Allowed access tiers, all clients if empty
+	Tiers []string `ini:"tiers" delim:","`
+}
+
+type EventsConfig struct {
+	// Minimum increase of filtered routes between two refreshes
+	FilteredIncreaseThreshold int `ini:"filtered_increase_threshold"`
+
+	// Usage of the import limit in percent
+	ImportLimitThreshold int `ini:"import_limit_threshold"`
+}
+
+type WebhookConfig struct {
+	Name string
+
+	Url     string        `ini:"url"`
+	Secret  string        `ini:"secret"`
+	Retries int           `ini:"retries"`
+	Timeout time.Duration `ini:"timeout"`
+
+	// Rules: Empty lists match everything
+	Events       []string `ini:"events" delim:","`
+	Asns         []int    `ini:"asns" delim:","`
+	Routeservers []int    `ini:"routeservers" delim:","`
 }
 
 type RejectionsConfig struct {
@@ -40,12 +197,15 @@ type UiConfig struct {
 
 	RoutesRejections RejectionsConfig
 	RoutesNoexports  NoexportsConfig
+
+	BgpCommunities BgpCommunities
 }
 
 type SourceConfig struct {
-	Id   int
-	Name string
-	Type int
+	Id    int
+	Name  string
+	Group string
+	Type  int
 
 	// Source configurations
 	Birdwatcher birdwatcher.Config
@@ -54,9 +214,22 @@ type SourceConfig struct {
 type Config struct {
 	Server  ServerConfig
 	Ui      UiConfig
+	Rpki    RpkiConfig
+	Irr     IrrConfig
+	Bogons  BogonsConfig
 	Sources []SourceConfig
 	File    string
 
+	Redaction RedactionConfig
+	RateLimit RateLimitConfig
+	Access    AccessConfig
+	Export    ExportConfig
+
+	Events   EventsConfig
+	Webhooks []WebhookConfig
+
+	AsnMetadata AsnMetadataConfig
+
 	instances map[SourceConfig]sources.Source
 }
 
@@ -150,8 +323,93 @@ func getRoutesNoexports(config *ini.File) (NoexportsConfig, error) {
 	return noexportsConfig, nil
 }
 
+// Get UI config: BGP community labels
+func getBgpCommunities(config *ini.File) (BgpCommunities, error) {
+	communities := BgpCommunities{}
+	section := config.Section("bgp_communities")
+
+	for _, key := range section.Keys() {
+		pattern := parseBgpCommunityPattern(key.Name())
+		if len(pattern) < 2 || len(pattern) > 3 {
+			return communities, fmt.Errorf("Invalid bgp community: %s", key.Name())
+		}
+		communities = communities.Add(key.Name(), key.MustString(""))
+	}
+
+	return communities, nil
+}
+
+// Get the server config, timeouts default to
+// values suitable for an exposed server.
+func getServerConfig(config *ini.File) (ServerConfig, error) {
+	serverConfig := ServerConfig{
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		IdleTimeout:       120 * time.Second,
+		MaxHeaderBytes:    1 << 16,
+		ShutdownTimeout:   30 * time.Second,
+		Http2:             true,
+	}
+
+	err := config.Section("server").MapTo(&serverConfig)
+	if err != nil {
+		return serverConfig, err
+	}
+
+	if serverConfig.MaxHeaderBytes <= 0 {
+		return serverConfig, fmt.Errorf("server: max_header_bytes must be positive")
+	}
+
+	if serverConfig.Listen == "" &&
+		serverConfig.ListenHttps == "" &&
+		serverConfig.ListenUnix == "" {
+		return serverConfig, fmt.Errorf(
+			"server: one of listen_http, listen_https or listen_unix is required")
+	}
+
+	if serverConfig.ListenHttps != "" &&
+		(serverConfig.TlsCert == "" || serverConfig.TlsKey == "") {
+		return serverConfig, fmt.Errorf(
+			"server: listen_https requires tls_cert and tls_key")
+	}
+
+	if serverConfig.RedirectHttps &&
+		(serverConfig.ListenHttps == "" || serverConfig.Listen == "") {
+		return serverConfig, fmt.Errorf(
+			"server: redirect_https requires listen_http and listen_https")
+	}
+
+	return serverConfig, nil
+}
+
+// Derive the request timeout from the timeouts of the sources,
+// a request may query a source sequentially. The write timeout
+// leaves time to respond after the request timed out.
+func getServerTimeouts(server ServerConfig, sources []SourceConfig) (ServerConfig, error) {
+	if server.RequestTimeout == 0 {
+		for _, source := range sources {
+			timeout := SOURCE_REQUESTS_PER_CALL * source.Birdwatcher.Timeout
+			if timeout > server.RequestTimeout {
+				server.RequestTimeout = timeout
+			}
+		}
+	}
+
+	if server.WriteTimeout == 0 {
+		server.WriteTimeout = server.RequestTimeout + REQUEST_TIMEOUT_MARGIN
+	}
+
+	if server.RequestTimeout > 0 && server.WriteTimeout <= server.RequestTimeout {
+		return server, fmt.Errorf(
+			"server: write_timeout must be longer than request_timeout (%s)",
+			server.RequestTimeout)
+	}
+
+	return server, nil
+}
+
 // Get the UI configuration from the config file
-func getUiConfig(config *ini.File) (UiConfig, error) {
+func getUiConfig(config, communitiesConfig *ini.File) (UiConfig, error) {
 	uiConfig := UiConfig{}
 
 	// Get route columns
@@ -171,16 +429,303 @@ func getUiConfig(config *ini.File) (UiConfig, error) {
 		return uiConfig, err
 	}
 
+	communities, err := getBgpCommunities(communitiesConfig)
+	if err != nil {
+		return uiConfig, err
+	}
+
 	// Make config
 	uiConfig = UiConfig{
 		RoutesColumns:    routesColumns,
 		RoutesRejections: rejections,
 		RoutesNoexports:  noexports,
+
+		BgpCommunities: communities,
 	}
 
 	return uiConfig, nil
 }
 
+// Get RPKI validation config
+func getRpkiConfig(config *ini.File) (RpkiConfig, error) {
+	rpkiConfig := RpkiConfig{
+		RefreshInterval: 10 * time.Minute,
+	}
+
+	err := config.Section("rpki").MapTo(&rpkiConfig)
+	if err != nil {
+		return rpkiConfig, err
+	}
+
+	if rpkiConfig.Enabled && rpkiConfig.VrpsFile == "" {
+		return rpkiConfig, fmt.Errorf("rpki is enabled but vrps_file is missing")
+	}
+	if rpkiConfig.RefreshInterval <= 0 {
+		return rpkiConfig, fmt.Errorf("rpki refresh_interval must be positive")
+	}
+
+	return rpkiConfig, nil
+}
+
+// Get IRR config and the AS-SETs of the neighbours
+func getIrrConfig(config *ini.File) (IrrConfig, error) {
+	asSets := make(map[int]string)
+	irrConfig := IrrConfig{
+		RefreshInterval: time.Hour,
+	}
+
+	err := config.Section("irr").MapTo(&irrConfig)
+	if err != nil {
+		return irrConfig, err
+	}
+
+	if irrConfig.Enabled && len(irrConfig.Files) == 0 {
+		return irrConfig, fmt.Errorf("irr is enabled but no files are configured")
+	}
+	if irrConfig.RefreshInterval <= 0 {
+		return irrConfig, fmt.Errorf("irr refresh_interval must be positive")
+	}
+
+	asSetsConfig := config.Section("irr_as_sets")
+	for _, key := range asSetsConfig.Keys() {
+		asn, err := strconv.Atoi(key.Name())
+		if err != nil {
+			return irrConfig, err
+		}
+		asSets[asn] = asSetsConfig.Key(key.Name()).MustString("")
+	}
+
+	irrConfig.AsSets = asSets
+
+	return irrConfig, nil
+}
+
+// Get ASN metadata config
+func getAsnMetadataConfig(config *ini.File) (AsnMetadataConfig, error) {
+	metadataConfig := AsnMetadataConfig{
+		Format:          ASN_METADATA_PEERINGDB,
+		RefreshInterval: 24 * time.Hour,
+	}
+
+	err := config.Section("asn_metadata").MapTo(&metadataConfig)
+	if err != nil {
+		return metadataConfig, err
+	}
+
+	if metadataConfig.Enabled && metadataConfig.File == "" {
+		return metadataConfig, fmt.Errorf("asn_metadata is enabled but file is missing")
+	}
+	if metadataConfig.RefreshInterval <= 0 {
+		return metadataConfig, fmt.Errorf("asn_metadata refresh_interval must be positive")
+	}
+
+	return metadataConfig, nil
+}
+
+// Get bogon detection config, the default lists
+// are used if no prefixes or ASNs are configured.
+func getBogonsConfig(config *ini.File) (BogonsConfig, error) {
+	bogonsConfig := BogonsConfig{
+		Ipv6GlobalOnly: true,
+	}
+
+	err := config.Section("bogons").MapTo(&bogonsConfig)
+	if err != nil {
+		return bogonsConfig, err
+	}
+
+	// Validate lists
+	_, err = NewBogonDetector(bogonsConfig)
+	if err != nil {
+		return bogonsConfig, err
+	}
+
+	return bogonsConfig, nil
+}
+
+// Get redaction config
+func getRedactionConfig(config *ini.File) (RedactionConfig, error) {
+	redactionConfig := RedactionConfig{}
+
+	err := config.Section("redaction").MapTo(&redactionConfig)
+	if err != nil {
+		return redactionConfig, err
+	}
+
+	// Validate patterns
+	_, err = NewRedactor(redactionConfig)
+	if err != nil {
+		return redactionConfig, err
+	}
+
+	return redactionConfig, nil
+}
+
+// Get rate limit config
+func getRateLimitConfig(config *ini.File) (RateLimitConfig, error) {
+	rateLimitConfig := RateLimitConfig{
+		Rate:           10,
+		Burst:          20,
+		ExpensiveRate:  1,
+		ExpensiveBurst: 5,
+		ProxyHeader:    "X-Forwarded-For",
+	}
+
+	err := config.Section("rate_limit").MapTo(&rateLimitConfig)
+	if err != nil {
+		return rateLimitConfig, err
+	}
+
+	if rateLimitConfig.Rate <= 0 || rateLimitConfig.ExpensiveRate <= 0 {
+		return rateLimitConfig, fmt.Errorf("rate_limit: rates must be positive")
+	}
+
+	// Validate proxies
+	_, err = parseNetworks(rateLimitConfig.TrustedProxies)
+	if err != nil {
+		return rateLimitConfig, err
+	}
+
+	return rateLimitConfig, nil
+}
+
+// Get access config: Tiers are configured in [access.tier.<name>]
+// sections, keys in [access.key.<name>] sections of the config
+// or the key file.
+func getAccessConfig(config *ini.File) (AccessConfig, error) {
+	accessConfig := AccessConfig{
+		Tiers: make(map[string]AccessTierConfig),
+		Keys:  []ApiKeyConfig{},
+	}
+
+	err := config.Section("access").MapTo(&accessConfig)
+	if err != nil {
+		return accessConfig, err
+	}
+
+	for _, section := range config.ChildSections("access.tier") {
+		tier := AccessTierConfig{
+			Name: strings.TrimPrefix(section.Name(), "access.tier."),
+		}
+		err := section.MapTo(&tier)
+		if err != nil {
+			return accessConfig, err
+		}
+		if tier.Name == ACCESS_TIER_PUBLIC {
+			return accessConfig, fmt.Errorf(
+				"%s: tier is reserved for requests without key", section.Name())
+		}
+		accessConfig.Tiers[tier.Name] = tier
+	}
+
+	keys, err := getApiKeys(config)
+	if err != nil {
+		return accessConfig, err
+	}
+	accessConfig.Keys = append(accessConfig.Keys, keys...)
+
+	if accessConfig.KeyFile != "" {
+		keyFile, err := ini.LoadSources(ini.LoadOptions{
+			KeyValueDelimiters: "=",
+		}, accessConfig.KeyFile)
+		if err != nil {
+			return accessConfig, err
+		}
+		keys, err := getApiKeys(keyFile)
+		if err != nil {
+			return accessConfig, err
+		}
+		accessConfig.Keys = append(accessConfig.Keys, keys...)
+	}
+
+	// Validate keys
+	for _, key := range accessConfig.Keys {
+		if _, ok := accessConfig.Tiers[key.Tier]; !ok {
+			return accessConfig, fmt.Errorf(
+				"api key %s: unknown tier %s", key.Name, key.Tier)
+		}
+	}
+
+	return accessConfig, nil
+}
+
+func getApiKeys(config *ini.File) ([]ApiKeyConfig, error) {
+	keys := []ApiKeyConfig{}
+	for _, section := range config.ChildSections("access.key") {
+		key := ApiKeyConfig{
+			Name: strings.TrimPrefix(section.Name(), "access.key."),
+		}
+		err := section.MapTo(&key)
+		if err != nil {
+			return keys, err
+		}
+
+		key.Hash = strings.ToLower(key.Hash)
+		if hash, err := hex.DecodeString(key.Hash); err != nil || len(hash) != sha256.Size {
+			return keys, fmt.Errorf("%s: hash is not a sha256 hex digest", section.Name())
+		}
+
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Get bulk export config, the tiers must be
+// configured access tiers.
+func getExportConfig(config *ini.File, access AccessConfig) (ExportConfig, error) {
+	exportConfig := ExportConfig{}
+
+	err := config.Section("export").MapTo(&exportConfig)
+	if err != nil {
+		return exportConfig, err
+	}
+
+	for _, tier := range exportConfig.Tiers {
+		if _, ok := access.Tiers[tier]; !ok && tier != ACCESS_TIER_PUBLIC {
+			return exportConfig, fmt.Errorf("export: unknown tier %s", tier)
+		}
+	}
+
+	return exportConfig, nil
+}
+
+// Get events config
+func getEventsConfig(config *ini.File) (EventsConfig, error) {
+	eventsConfig := EventsConfig{
+		FilteredIncreaseThreshold: 10,
+		ImportLimitThreshold:      90,
+	}
+	err := config.Section("events").MapTo(&eventsConfig)
+	return eventsConfig, err
+}
+
+// Get webhooks: Every [webhook.<name>] section
+// configures a target
+func getWebhooks(config *ini.File) ([]WebhookConfig, error) {
+	webhooks := []WebhookConfig{}
+
+	for _, section := range config.ChildSections("webhook") {
+		webhook := WebhookConfig{
+			Name:    strings.TrimPrefix(section.Name(), "webhook."),
+			Retries: 3,
+			Timeout: 10 * time.Second,
+		}
+
+		err := section.MapTo(&webhook)
+		if err != nil {
+			return webhooks, err
+		}
+
+		if webhook.Url == "" {
+			return webhooks, fmt.Errorf("%s has no url", section.Name())
+		}
+
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, nil
+}
+
 func getSources(config *ini.File) ([]SourceConfig, error) {
 	sources := []SourceConfig{}
 
@@ -214,9 +759,10 @@ func getSources(config *ini.File) ([]SourceConfig, error) {
 
 		// Make config
 		config := SourceConfig{
-			Id:   sourceId,
-			Name: section.Key("name").MustString("Unknown Source"),
-			Type: backendType,
+			Id:    sourceId,
+			Name:  section.Key("name").MustString("Unknown Source"),
+			Group: section.Key("group").MustString(""),
+			Type:  backendType,
 		}
 
 		// Set backend
@@ -229,6 +775,8 @@ func getSources(config *ini.File) ([]SourceConfig, error) {
 				ServerTime:      "2006-01-02T15:04:05.999999999Z07:00",
 				ServerTimeShort: "2006-01-02",
 				ServerTimeExt:   "Mon, 02 Jan 2006 15:04:05 -0700",
+
+				Timeout: 30 * time.Second,
 			}
 			backendConfig.MapTo(&c)
 			config.Birdwatcher = c
@@ -263,9 +811,23 @@ func loadConfig(file string) (*Config, error) {
 		return nil, err
 	}
 
+	// Only use '=' as delimiter for the bgp communities, as
+	// the patterns contain ':'. Lines of other sections without
+	// '=' are kept as boolean keys, so they do not fail here.
+	communitiesConfig, err := ini.LoadSources(ini.LoadOptions{
+		Loose:              true,
+		KeyValueDelimiters: "=",
+		AllowBooleanKeys:   true,
+	}, file)
+	if err != nil {
+		return nil, err
+	}
+
 	// Map sections
-	server := ServerConfig{}
-	parsedConfig.Section("server").MapTo(&server)
+	server, err := getServerConfig(parsedConfig)
+	if err != nil {
+		return nil, err
+	}
 
 	// Get all sources
 	sources, err := getSources(parsedConfig)
@@ -273,17 +835,100 @@ func loadConfig(file string) (*Config, error) {
 		return nil, err
 	}
 
+	server, err = getServerTimeouts(server, sources)
+	if err != nil {
+		return nil, err
+	}
+
 	// Get UI configurations
-	ui, err := getUiConfig(parsedConfig)
+	ui, err := getUiConfig(parsedConfig, communitiesConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get RPKI configuration
+	rpki, err := getRpkiConfig(parsedConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get IRR configuration
+	irr, err := getIrrConfig(parsedConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get ASN metadata configuration
+	asnMetadata, err := getAsnMetadataConfig(parsedConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get bogons configuration
+	bogons, err := getBogonsConfig(parsedConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get redaction configuration
+	redaction, err := getRedactionConfig(parsedConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get rate limits
+	rateLimit, err := getRateLimitConfig(parsedConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get api keys and access tiers
+	access, err := getAccessConfig(parsedConfig)
 	if err != nil {
 		return nil, err
 	}
 
+	// Get bulk export configuration
+	export, err := getExportConfig(parsedConfig, access)
+	if err != nil {
+		return nil, err
+	}
+
+	// Get events and webhooks
+	events, err := getEventsConfig(parsedConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks, err := getWebhooks(parsedConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	// Events are published by the stores, which
+	// only run with the prefix lookup enabled.
+	if len(webhooks) > 0 && !server.EnablePrefixLookup {
+		return nil, fmt.Errorf("webhooks require enable_prefix_lookup")
+	}
+
 	config := &Config{
 		Server:  server,
 		Ui:      ui,
+		Rpki:    rpki,
+		Irr:     irr,
+		Bogons:  bogons,
 		Sources: sources,
 		File:    file,
+
+		Redaction: redaction,
+		RateLimit: rateLimit,
+		Access:    access,
+		Export:    export,
+
+		Events:   events,
+		Webhooks: webhooks,
+
+		AsnMetadata: asnMetadata,
 	}
 
 	return config, nil
@@ -1,7 +1,12 @@
 package main
 
 import (
+	"io/ioutil"
+	"os"
 	"testing"
+	"time"
+
+	"github.com/go-ini/ini"
 )
 
 // Test configuration loading and parsing
@@ -26,3 +31,103 @@ func TestLoadConfigs(t *testing.T) {
 		t.Error("Rejection reasons missing")
 	}
 }
+
+// Load the example config, which is shipped
+// with the repository.
+func TestLoadExampleConfig(t *testing.T) {
+	config, err := loadConfig("../etc/alicelg/alice.example.conf")
+	if err != nil {
+		t.Fatal("Could not load example config:", err)
+	}
+
+	if len(config.Sources) != 2 {
+		t.Error("Expected 2 sources, got:", len(config.Sources))
+	}
+
+	// Community patterns contain ':'
+	label, ok := config.Ui.BgpCommunities.Lookup([]string{"0", "6695"})
+	if !ok || label != "Do not announce to AS6695" {
+		t.Error("Unexpected label for 0:6695:", label)
+	}
+
+	if config.Ui.RoutesColumns["bgp.as_path"] != "AS_Path" {
+		t.Error("Routes columns not loaded:", config.Ui.RoutesColumns)
+	}
+
+	if !config.Access.Tiers["staff"].Admin || config.Access.Tiers["partner"].Rate != 50 {
+		t.Error("Access tiers not loaded:", config.Access.Tiers)
+	}
+
+	// The sources use the default timeout of 30s
+	if config.Server.RequestTimeout != 90*time.Second ||
+		config.Server.WriteTimeout != 100*time.Second ||
+		config.Server.MaxHeaderBytes != 65536 {
+		t.Error("Unexpected server config:", config.Server)
+	}
+}
+
+func TestRefreshIntervalConfig(t *testing.T) {
+	expected := []struct {
+		config string
+		valid  bool
+	}{
+		{"[rpki]\nrefresh_interval = 5m", true},
+		{"[rpki]\nrefresh_interval = 0", false},
+		{"[irr]\nrefresh_interval = -1", false},
+		{"[asn_metadata]\nrefresh_interval = 0", false},
+	}
+
+	for _, e := range expected {
+		parsed, err := ini.Load([]byte(e.config))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, rpkiErr := getRpkiConfig(parsed)
+		_, irrErr := getIrrConfig(parsed)
+		_, metadataErr := getAsnMetadataConfig(parsed)
+		valid := rpkiErr == nil && irrErr == nil && metadataErr == nil
+		if valid != e.valid {
+			t.Error("Unexpected result for", e.config, "-", rpkiErr, irrErr, metadataErr)
+		}
+	}
+}
+
+// Only the bgp communities use '=' as the single delimiter
+func TestLoadConfigDelimiters(t *testing.T) {
+	file, err := ioutil.TempFile("", "alice-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("[server]\nlisten_http: :7340\n\n" +
+		"[routes_columns]\ngateway: Gateway\n\n" +
+		"[bgp_communities]\n65000:* = Announce to AS*\n")
+	file.Close()
+
+	config, err := loadConfig(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Server.Listen != ":7340" || config.Ui.RoutesColumns["gateway"] != "Gateway" {
+		t.Error("Expected ':' as delimiter, got:", config.Server.Listen, config.Ui.RoutesColumns)
+	}
+	label, ok := config.Ui.BgpCommunities.Lookup([]string{"65000", "23"})
+	if !ok || label != "Announce to AS23" {
+		t.Error("Unexpected label for 65000:23:", label)
+	}
+}
+
+func TestWebhooksRequirePrefixLookup(t *testing.T) {
+	file, err := ioutil.TempFile("", "alice-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString("[server]\nlisten_http = :7340\n\n" +
+		"[webhook.chat]\nurl = https://chat.example.com/hooks/alice\n")
+	file.Close()
+
+	if _, err := loadConfig(file.Name()); err == nil {
+		t.Error("Expected webhooks without prefix lookup to be rejected")
+	}
+}
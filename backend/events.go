@@ -0,0 +1,232 @@
+package main
+
+import (
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+// Events
+//
+// The stores publish events on refresh, e.g. when a
+// neighbour goes down or its filtered routes increase.
+// Subscribers (like webhooks) receive all events and
+// are responsible for filtering.
+
+const (
+	EVENT_NEIGHBOUR_UP                 = "neighbour_up"
+	EVENT_NEIGHBOUR_DOWN               = "neighbour_down"
+	EVENT_NEIGHBOUR_FILTERED_INCREASED = "neighbour_filtered_increased"
+	EVENT_NEIGHBOUR_IMPORT_LIMIT       = "neighbour_import_limit"
+	EVENT_ROUTES_REFRESHED             = "routes_refreshed"
+	EVENT_NEIGHBOURS_REFRESHED         = "neighbours_refreshed"
+)
+
+type EventBus struct {
+	config EventsConfig
+
+	subscribers map[chan api.Event]bool
+	rwlock      *sync.RWMutex
+
+	// Events not received by slow subscribers
+	dropped uint64
+}
+
+func NewEventBus(config EventsConfig) *EventBus {
+	bus := &EventBus{
+		config:      config,
+		subscribers: make(map[chan api.Event]bool),
+		rwlock:      &sync.RWMutex{},
+	}
+	return bus
+}
+
+// Get a channel receiving all events. Events are dropped
+// if the subscriber can not keep up.
+func (self *EventBus) Subscribe(buffer int) chan api.Event {
+	events := make(chan api.Event, buffer)
+
+	self.rwlock.Lock()
+	self.subscribers[events] = true
+	self.rwlock.Unlock()
+
+	return events
+}
+
+func (self *EventBus) Unsubscribe(events chan api.Event) {
+	self.rwlock.Lock()
+	delete(self.subscribers, events)
+	self.rwlock.Unlock()
+
+	close(events)
+}
+
+// Dispatch events to all subscribers
+func (self *EventBus) Publish(events ...api.Event) {
+	self.rwlock.RLock()
+	defer self.rwlock.RUnlock()
+
+	dropped := 0
+	for _, event := range events {
+		for subscriber, _ := range self.subscribers {
+			select {
+			case subscriber <- event:
+			default: // subscriber is too slow
+				dropped += 1
+			}
+		}
+	}
+
+	if dropped > 0 {
+		atomic.AddUint64(&self.dropped, uint64(dropped))
+		log.Println("Dropped", dropped, "events for slow subscribers")
+	}
+}
+
+// Number of events dropped since the start
+func (self *EventBus) Dropped() uint64 {
+	return atomic.LoadUint64(&self.dropped)
+}
+
+// Compare the neighbours of a route server before and
+// after a refresh and publish changes
+func (self *EventBus) PublishNeighbourChanges(
+	source SourceConfig,
+	previous NeighboursIndex,
+	current NeighboursIndex,
+) {
+	now := time.Now()
+	rs := api.Routeserver{
+		Id:    source.Id,
+		Name:  source.Name,
+		Group: source.Group,
+	}
+
+	for id, neighbour := range current {
+		prev, ok := previous[id]
+		if !ok {
+			continue // new neighbour or first refresh
+		}
+		events := neighbourEvents(self.config, rs, prev, neighbour, now)
+		self.Publish(events...)
+	}
+}
+
+// Publish completion of a neighbours refresh
+func (self *EventBus) PublishNeighboursRefreshed(
+	source SourceConfig,
+	previous NeighboursIndex,
+	current NeighboursIndex,
+) {
+	up := 0
+	for _, neighbour := range current {
+		if isStateUp(neighbour.State) {
+			up += 1
+		}
+	}
+
+	self.Publish(api.Event{
+		Type:      EVENT_NEIGHBOURS_REFRESHED,
+		Timestamp: time.Now(),
+		Routeserver: api.Routeserver{
+			Id:    source.Id,
+			Name:  source.Name,
+			Group: source.Group,
+		},
+		Data: map[string]interface{}{
+			"neighbours":       len(current),
+			"neighbours_up":    up,
+			"neighbours_delta": len(current) - len(previous),
+		},
+	})
+}
+
+// Publish completion of a routes refresh
+func (self *EventBus) PublishRoutesRefreshed(
+	source SourceConfig,
+	previous api.RoutesResponse,
+	current api.RoutesResponse,
+) {
+	self.Publish(api.Event{
+		Type:      EVENT_ROUTES_REFRESHED,
+		Timestamp: time.Now(),
+		Routeserver: api.Routeserver{
+			Id:    source.Id,
+			Name:  source.Name,
+			Group: source.Group,
+		},
+		Data: map[string]interface{}{
+			"imported":       len(current.Imported),
+			"filtered":       len(current.Filtered),
+			"imported_delta": len(current.Imported) - len(previous.Imported),
+			"filtered_delta": len(current.Filtered) - len(previous.Filtered),
+		},
+	})
+}
+
+// Make events for a neighbour
+func neighbourEvents(
+	config EventsConfig,
+	rs api.Routeserver,
+	previous api.Neighbour,
+	current api.Neighbour,
+	now time.Time,
+) []api.Event {
+	events := []api.Event{}
+
+	// The details may be large and are not of interest here
+	neighbour := current
+	neighbour.Details = nil
+
+	makeEvent := func(eventType string, data map[string]interface{}) api.Event {
+		return api.Event{
+			Type:        eventType,
+			Timestamp:   now,
+			Routeserver: rs,
+			Neighbour:   &neighbour,
+			Data:        data,
+		}
+	}
+
+	// State changes
+	wasUp := isStateUp(previous.State)
+	isUp := isStateUp(current.State)
+	if wasUp && !isUp {
+		events = append(events, makeEvent(EVENT_NEIGHBOUR_DOWN, map[string]interface{}{
+			"previous_state": previous.State,
+		}))
+	}
+	if !wasUp && isUp {
+		events = append(events, makeEvent(EVENT_NEIGHBOUR_UP, map[string]interface{}{
+			"previous_state": previous.State,
+		}))
+	}
+
+	// Filtered routes
+	increase := current.RoutesFiltered - previous.RoutesFiltered
+	if config.FilteredIncreaseThreshold > 0 &&
+		increase >= config.FilteredIncreaseThreshold {
+		events = append(events, makeEvent(EVENT_NEIGHBOUR_FILTERED_INCREASED, map[string]interface{}{
+			"previous": previous.RoutesFiltered,
+			"current":  current.RoutesFiltered,
+		}))
+	}
+
+	// Import limit: Only notify when crossing the threshold
+	threshold := float64(config.ImportLimitThreshold)
+	if current.ImportLimit > 0 && threshold > 0 {
+		if current.ImportLimitUsage >= threshold &&
+			previous.ImportLimitUsage < threshold {
+			events = append(events, makeEvent(EVENT_NEIGHBOUR_IMPORT_LIMIT, map[string]interface{}{
+				"routes_received": current.RouteLimitUsed,
+				"import_limit":    current.ImportLimit,
+				"usage":           current.ImportLimitUsage,
+			}))
+		}
+	}
+
+	return events
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+func TestNeighbourEvents(t *testing.T) {
+	config := EventsConfig{
+		FilteredIncreaseThreshold: 10,
+		ImportLimitThreshold:      90,
+	}
+	rs := api.Routeserver{Id: 1, Name: "rs1"}
+	now := time.Now()
+
+	previous := api.Neighbour{
+		Id:             "ID109_AS31078",
+		State:          "up",
+		RoutesReceived: 80,
+		RoutesFiltered: 2,
+
+		ImportLimit:      100,
+		RouteLimitUsed:   80,
+		ImportLimitUsage: 80.0,
+
+		Details: map[string]interface{}{
+			"import_limit": float64(100),
+		},
+	}
+
+	// Nothing happened
+	events := neighbourEvents(config, rs, previous, previous, now)
+	if len(events) != 0 {
+		t.Error("Expected no events, got:", events)
+	}
+
+	// Neighbour goes down
+	current := previous
+	current.State = "start"
+	events = neighbourEvents(config, rs, previous, current, now)
+	if len(events) != 1 || events[0].Type != EVENT_NEIGHBOUR_DOWN {
+		t.Error("Expected neighbour down event, got:", events)
+	}
+	if events[0].Neighbour.Details != nil {
+		t.Error("Details should not be included in events")
+	}
+
+	// And up again
+	events = neighbourEvents(config, rs, current, previous, now)
+	if len(events) != 1 || events[0].Type != EVENT_NEIGHBOUR_UP {
+		t.Error("Expected neighbour up event, got:", events)
+	}
+
+	// Filtered routes and import limit
+	current = previous
+	current.RoutesFiltered = 12
+	current.RoutesReceived = 95
+	current.RouteLimitUsed = 95
+	current.ImportLimitUsage = 95.0
+	events = neighbourEvents(config, rs, previous, current, now)
+	if len(events) != 2 {
+		t.Fatal("Expected 2 events, got:", events)
+	}
+	if events[0].Type != EVENT_NEIGHBOUR_FILTERED_INCREASED {
+		t.Error("Expected filtered increased event, got:", events[0].Type)
+	}
+	if events[1].Type != EVENT_NEIGHBOUR_IMPORT_LIMIT {
+		t.Error("Expected import limit event, got:", events[1].Type)
+	}
+
+	// Import limit was already exceeded
+	events = neighbourEvents(config, rs, current, current, now)
+	if len(events) != 0 {
+		t.Error("Expected no events, got:", events)
+	}
+}
+
+func TestEventBus(t *testing.T) {
+	bus := NewEventBus(EventsConfig{})
+	events := bus.Subscribe(1)
+
+	bus.Publish(api.Event{Type: "a"}, api.Event{Type: "b"})
+
+	event := <-events
+	if event.Type != "a" {
+		t.Error("Expected event a, got:", event.Type)
+	}
+
+	// The second event was dropped
+	select {
+	case event = <-events:
+		t.Error("Unexpected event:", event)
+	default:
+	}
+	if bus.Dropped() != 1 {
+		t.Error("Expected 1 dropped event, got:", bus.Dropped())
+	}
+
+	bus.Unsubscribe(events)
+	bus.Publish(api.Event{Type: "c"})
+}
@@ -0,0 +1,370 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+// Export of routes and neighbours
+//
+// The routes, neighbours and lookup endpoints can respond
+// with CSV or NDJSON instead of JSON. The format is requested
+// with the format query param or the Accept header.
+// Exports are streamed row by row and are not paginated,
+// unless a limit is requested.
+
+const (
+	EXPORT_FORMAT_JSON   = "json"
+	EXPORT_FORMAT_CSV    = "csv"
+	EXPORT_FORMAT_NDJSON = "ndjson"
+)
+
+// Flush the response after this number of rows
+const EXPORT_FLUSH_ROWS = 1000
+
+var exportContentTypes = map[string]string{
+	EXPORT_FORMAT_JSON:   "application/json",
+	EXPORT_FORMAT_CSV:    "text/csv; charset=utf-8",
+	EXPORT_FORMAT_NDJSON: "application/x-ndjson",
+}
+
+var exportMediaTypes = map[string]string{
+	"application/json":     EXPORT_FORMAT_JSON,
+	"text/csv":             EXPORT_FORMAT_CSV,
+	"application/x-ndjson": EXPORT_FORMAT_NDJSON,
+	"application/ndjson":   EXPORT_FORMAT_NDJSON,
+}
+
+// Get the requested format. The query param takes
+// precedence over the Accept header.
+func validateExportFormat(req *http.Request) (string, bool, error) {
+	format := req.URL.Query().Get("format")
+	if format != "" {
+		if _, ok := exportContentTypes[format]; !ok {
+			return "", true, NewInvalidParamError("Unknown format: %s", format)
+		}
+		return format, true, nil
+	}
+
+	// Use the supported media type with the highest
+	// quality, the first one if they are equal.
+	format = EXPORT_FORMAT_JSON
+	quality := 0.0
+	for _, mediaRange := range strings.Split(req.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(mediaRange)
+		if err != nil {
+			continue
+		}
+		accepted, ok := exportMediaTypes[mediaType]
+		if !ok {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			q, err = strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+		}
+		if q > quality { // q=0 is not acceptable
+			format = accepted
+			quality = q
+		}
+	}
+
+	return format, false, nil
+}
+
+// Check if the response is an export
+func isExportRequest(req *http.Request) bool {
+	format, _, err := validateExportFormat(req)
+	return err == nil && format != EXPORT_FORMAT_JSON
+}
+
+// Rows of an export
+type exportTable struct {
+	Columns []string
+	Len     int
+
+	Record func(i int) interface{} // ndjson
+	Row    func(i int) []string    // csv
+}
+
+// Routes with their state
+type exportRoute struct {
+	api.Route
+	State string `json:"state"`
+}
+
+var exportRouteColumns = []string{
+	"neighbour_id", "state", "network", "primary",
+	"gateway", "next_hop", "as_path", "origin", "local_pref", "med",
+	"communities", "large_communities", "ext_communities",
+	"rpki", "age",
+}
+
+var exportNeighbourColumns = []string{
+	"id", "address", "asn", "as_name", "state", "description",
+	"routes_received", "routes_filtered", "routes_exported",
+	"routes_preferred", "import_limit", "import_limit_usage",
+	"uptime", "last_error",
+}
+
+var exportRouteserverColumns = []string{
+	"routeserver_id", "routeserver",
+}
+
+// Prevent spreadsheets from evaluating free text as formula
+func csvText(value string) string {
+	if value != "" && strings.ContainsRune("=+-@", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func csvCommunities(communities []api.Community) string {
+	values := make([]string, 0, len(communities))
+	for _, community := range communities {
+		parts := make([]string, 0, len(community))
+		for _, part := range community {
+			parts = append(parts, strconv.Itoa(part))
+		}
+		values = append(values, strings.Join(parts, ":"))
+	}
+	return strings.Join(values, " ")
+}
+
+func csvExtCommunities(communities []api.ExtCommunity) string {
+	values := make([]string, 0, len(communities))
+	for _, community := range communities {
+		values = append(values, strings.Join(community, ":"))
+	}
+	return strings.Join(values, " ")
+}
+
+func csvAsPath(path []int) string {
+	values := make([]string, 0, len(path))
+	for _, asn := range path {
+		values = append(values, strconv.Itoa(asn))
+	}
+	return strings.Join(values, " ")
+}
+
+func csvRouteRow(route api.Route, state string) []string {
+	return []string{
+		route.NeighbourId,
+		state,
+		route.Network,
+		strconv.FormatBool(route.Primary),
+		route.Gateway,
+		route.Bgp.NextHop,
+		csvAsPath(route.Bgp.AsPath),
+		route.Bgp.Origin,
+		strconv.Itoa(route.Bgp.LocalPref),
+		strconv.Itoa(route.Bgp.Med),
+		csvCommunities(route.Bgp.Communities),
+		csvCommunities(route.Bgp.LargeCommunities),
+		csvExtCommunities(route.Bgp.ExtCommunities),
+		route.Rpki,
+		strconv.Itoa(int(route.Age.Seconds())),
+	}
+}
+
+func csvNeighbourRow(neighbour api.Neighbour) []string {
+	return []string{
+		neighbour.Id,
+		neighbour.Address,
+		strconv.Itoa(neighbour.Asn),
+		csvText(neighbour.AsName),
+		neighbour.State,
+		csvText(neighbour.Description),
+		strconv.Itoa(neighbour.RoutesReceived),
+		strconv.Itoa(neighbour.RoutesFiltered),
+		strconv.Itoa(neighbour.RoutesExported),
+		strconv.Itoa(neighbour.RoutesPreferred),
+		strconv.Itoa(neighbour.ImportLimit),
+		strconv.FormatFloat(neighbour.ImportLimitUsage, 'f', 2, 64),
+		strconv.Itoa(int(neighbour.Uptime.Seconds())),
+		csvText(neighbour.LastError),
+	}
+}
+
+func csvRouteserverRow(routeserver api.Routeserver) []string {
+	return []string{
+		strconv.Itoa(routeserver.Id),
+		routeserver.Name,
+	}
+}
+
+func makeRoutesExportTable(routes []exportRoute) *exportTable {
+	return &exportTable{
+		Columns: exportRouteColumns,
+		Len:     len(routes),
+		Record: func(i int) interface{} {
+			return routes[i]
+		},
+		Row: func(i int) []string {
+			return csvRouteRow(routes[i].Route, routes[i].State)
+		},
+	}
+}
+
+func makeLookupRoutesExportTable(routes []api.LookupRoute) *exportTable {
+	columns := append([]string{}, exportRouteserverColumns...)
+	columns = append(columns, exportRouteColumns...)
+	columns = append(columns, "neighbour_asn", "neighbour_description")
+
+	return &exportTable{
+		Columns: columns,
+		Len:     len(routes),
+		Record: func(i int) interface{} {
+			return routes[i]
+		},
+		Row: func(i int) []string {
+			route := routes[i]
+			row := csvRouteserverRow(route.Routeserver)
+			row = append(row, csvRouteRow(api.Route{
+				NeighbourId: route.NeighbourId,
+				Network:     route.Network,
+				Gateway:     route.Gateway,
+				Bgp:         route.Bgp,
+				Age:         route.Age,
+				Primary:     route.Primary,
+				Rpki:        route.Rpki,
+			}, route.State)...)
+			return append(row,
+				strconv.Itoa(route.Neighbour.Asn),
+				csvText(route.Neighbour.Description))
+		},
+	}
+}
+
+func makeNeighboursExportTable(neighbours []api.Neighbour) *exportTable {
+	return &exportTable{
+		Columns: exportNeighbourColumns,
+		Len:     len(neighbours),
+		Record: func(i int) interface{} {
+			return neighbours[i]
+		},
+		Row: func(i int) []string {
+			return csvNeighbourRow(neighbours[i])
+		},
+	}
+}
+
+func makeLookupNeighboursExportTable(neighbours []api.LookupNeighbour) *exportTable {
+	columns := append([]string{}, exportRouteserverColumns...)
+	columns = append(columns, exportNeighbourColumns...)
+
+	return &exportTable{
+		Columns: columns,
+		Len:     len(neighbours),
+		Record: func(i int) interface{} {
+			return neighbours[i]
+		},
+		Row: func(i int) []string {
+			row := csvRouteserverRow(neighbours[i].Routeserver)
+			return append(row, csvNeighbourRow(neighbours[i].Neighbour)...)
+		},
+	}
+}
+
+// Get the rows of a response, if it can be exported
+func makeExportTable(result api.Response) (*exportTable, bool) {
+	switch r := result.(type) {
+	case api.RoutesResponse:
+		routes := make([]exportRoute, 0,
+			len(r.Imported)+len(r.Filtered)+len(r.NotExported))
+		for _, route := range r.Imported {
+			routes = append(routes, exportRoute{route, "imported"})
+		}
+		for _, route := range r.Filtered {
+			routes = append(routes, exportRoute{route, "filtered"})
+		}
+		for _, route := range r.NotExported {
+			routes = append(routes, exportRoute{route, "not_exported"})
+		}
+		return makeRoutesExportTable(routes), true
+
+	case api.RoutesLookupResponse:
+		return makeLookupRoutesExportTable(r.Routes), true
+
+	case api.RoutesLookupResponseGlobal:
+		return makeLookupRoutesExportTable(r.Routes), true
+
+	case api.NeighboursResponse:
+		return makeNeighboursExportTable(r.Neighbours), true
+
+	case api.NeighboursLookupResponseGlobal:
+		return makeLookupNeighboursExportTable(r.Neighbours), true
+	}
+
+	return nil, false
+}
+
+// Stream the rows of an export
+func writeExport(
+	res http.ResponseWriter,
+	req *http.Request,
+	format string,
+	table *exportTable,
+) {
+	res.Header().Set("Content-Type", exportContentTypes[format])
+	clearWriteDeadline(res)
+
+	var writer io.Writer = res
+	flush := func() {}
+	if flusher, ok := res.(http.Flusher); ok {
+		flush = flusher.Flush
+	}
+
+	// Check if compression is supported
+	if strings.Contains(req.Header.Get("Accept-Encoding"), "gzip") {
+		res.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(res)
+		defer gz.Close()
+		writer = gz
+
+		flushResponse := flush
+		flush = func() {
+			gz.Flush()
+			flushResponse()
+		}
+	}
+
+	switch format {
+	case EXPORT_FORMAT_CSV:
+		w := csv.NewWriter(writer)
+		w.Write(table.Columns)
+		for i := 0; i < table.Len; i++ {
+			w.Write(table.Row(i))
+			if (i+1)%EXPORT_FLUSH_ROWS == 0 {
+				w.Flush()
+				if w.Error() != nil {
+					return // client is gone
+				}
+				flush()
+			}
+		}
+		w.Flush()
+
+	case EXPORT_FORMAT_NDJSON:
+		encoder := json.NewEncoder(writer)
+		for i := 0; i < table.Len; i++ {
+			if err := encoder.Encode(table.Record(i)); err != nil {
+				return // client is gone
+			}
+			if (i+1)%EXPORT_FLUSH_ROWS == 0 {
+				flush()
+			}
+		}
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecix/alice-lg/backend/api"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestValidateExportFormat(t *testing.T) {
+	expected := []struct {
+		url      string
+		accept   string
+		format   string
+		explicit bool
+		valid    bool
+	}{
+		{"/routes", "", EXPORT_FORMAT_JSON, false, true},
+		{"/routes?format=csv", "", EXPORT_FORMAT_CSV, true, true},
+		{"/routes?format=ndjson", "text/csv", EXPORT_FORMAT_NDJSON, true, true},
+		{"/routes?format=xml", "", "", true, false},
+		{"/routes", "text/csv; charset=utf-8", EXPORT_FORMAT_CSV, false, true},
+		{"/routes", "text/html, application/x-ndjson", EXPORT_FORMAT_NDJSON, false, true},
+		{"/routes", "text/html, */*", EXPORT_FORMAT_JSON, false, true},
+		{"/routes", "text/csv;q=0, application/json", EXPORT_FORMAT_JSON, false, true},
+		{"/routes", "text/csv;q=0, application/x-ndjson", EXPORT_FORMAT_NDJSON, false, true},
+		{"/routes", "text/csv;q=0.5, application/x-ndjson;q=0.8", EXPORT_FORMAT_NDJSON, false, true},
+		{"/routes", "text/csv, application/json", EXPORT_FORMAT_CSV, false, true},
+	}
+
+	for _, e := range expected {
+		req := httptest.NewRequest("GET", e.url, nil)
+		req.Header.Set("Accept", e.accept)
+		format, explicit, err := validateExportFormat(req)
+		if (err == nil) != e.valid {
+			t.Error("Unexpected error for", e.url, e.accept, ":", err)
+			continue
+		}
+		if format != e.format || explicit != e.explicit {
+			t.Error("Expected", e.format, e.explicit, "for", e.url, e.accept,
+				"got:", format, explicit)
+		}
+	}
+}
+
+func makeExportTestRoutes() api.RoutesResponse {
+	return api.RoutesResponse{
+		Imported: []api.Route{
+			api.Route{
+				NeighbourId: "ID1_AS2342",
+				Network:     "10.23.0.0/16",
+				Primary:     true,
+				Bgp: api.BgpInfo{
+					AsPath:      []int{2342, 23},
+					Communities: []api.Community{{2342, 1}, {2342, 2}},
+				},
+				Age: 90 * time.Second,
+			},
+		},
+		Filtered: []api.Route{
+			api.Route{
+				NeighbourId: "ID1_AS2342",
+				Network:     "192.168.0.0/16",
+			},
+		},
+	}
+}
+
+func TestWriteExportCsv(t *testing.T) {
+	table, ok := makeExportTable(makeExportTestRoutes())
+	if !ok {
+		t.Fatal("Expected routes to be exportable")
+	}
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/routes?format=csv", nil)
+	writeExport(res, req, EXPORT_FORMAT_CSV, table)
+
+	if !strings.HasPrefix(res.Header().Get("Content-Type"), "text/csv") {
+		t.Error("Unexpected content type:", res.Header().Get("Content-Type"))
+	}
+
+	rows, err := csv.NewReader(res.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 {
+		t.Fatal("Expected header and 2 rows, got:", rows)
+	}
+	if strings.Join(rows[0], ",") != strings.Join(exportRouteColumns, ",") {
+		t.Error("Unexpected header:", rows[0])
+	}
+
+	row := map[string]string{}
+	for i, column := range rows[0] {
+		row[column] = rows[1][i]
+	}
+	if row["state"] != "imported" || row["as_path"] != "2342 23" ||
+		row["communities"] != "2342:1 2342:2" || row["age"] != "90" {
+		t.Error("Unexpected row:", row)
+	}
+	if rows[2][1] != "filtered" {
+		t.Error("Expected filtered route:", rows[2])
+	}
+}
+
+// A client disconnecting after the first write
+type closedResponseWriter struct {
+	*httptest.ResponseRecorder
+	writes  int
+	flushes int
+}
+
+func (self *closedResponseWriter) Flush() {
+	self.flushes += 1
+}
+
+func (self *closedResponseWriter) Write(data []byte) (int, error) {
+	self.writes += 1
+	if self.writes > 1 {
+		return 0, fmt.Errorf("broken pipe")
+	}
+	return self.ResponseRecorder.Write(data)
+}
+
+func TestWriteExportCsvClientGone(t *testing.T) {
+	routes := api.RoutesResponse{}
+	for i := 0; i < 3*EXPORT_FLUSH_ROWS; i++ {
+		routes.Imported = append(routes.Imported, api.Route{
+			NeighbourId: "ID1_AS2342",
+			Network:     fmt.Sprintf("10.%d.%d.0/24", i/256, i%256),
+		})
+	}
+	table, _ := makeExportTable(routes)
+
+	res := &closedResponseWriter{ResponseRecorder: httptest.NewRecorder()}
+	req := httptest.NewRequest("GET", "/routes?format=csv", nil)
+	writeExport(res, req, EXPORT_FORMAT_CSV, table)
+	if res.flushes != 0 {
+		t.Error("Expected export to stop, got flushes:", res.flushes)
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	expected := []struct {
+		total, limit, offset int
+		start, end           int
+	}{
+		{10, 5, 0, 0, 5},
+		{10, 5, 8, 8, 10},
+		{10, 5, 20, 10, 10},
+		{10, math.MaxInt32, 5, 5, 10},
+		{10, math.MaxInt32, math.MaxInt64 - 1, 10, 10},
+	}
+	for _, e := range expected {
+		start, end := paginate(e.total, e.limit, e.offset)
+		if start != e.start || end != e.end {
+			t.Error("Unexpected page for", e, "got:", start, end)
+		}
+	}
+}
+
+func TestWriteExportNdjson(t *testing.T) {
+	table, _ := makeExportTable(api.NeighboursLookupResponseGlobal{
+		Neighbours: []api.LookupNeighbour{
+			api.LookupNeighbour{
+				Neighbour:   api.Neighbour{Id: "ID1_AS2342", Asn: 2342},
+				Routeserver: api.Routeserver{Id: 1, Name: "rs1"},
+			},
+			api.LookupNeighbour{
+				Neighbour:   api.Neighbour{Id: "ID2_AS23", Asn: 23},
+				Routeserver: api.Routeserver{Id: 1, Name: "rs1"},
+			},
+		},
+	})
+
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/lookup/neighbours", nil)
+	writeExport(res, req, EXPORT_FORMAT_NDJSON, table)
+
+	lines := strings.Split(strings.TrimSpace(res.Body.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatal("Expected 2 lines, got:", lines)
+	}
+
+	neighbour := api.LookupNeighbour{}
+	if err := json.Unmarshal([]byte(lines[1]), &neighbour); err != nil {
+		t.Fatal(err)
+	}
+	if neighbour.Id != "ID2_AS23" || neighbour.Routeserver.Name != "rs1" {
+		t.Error("Unexpected neighbour:", neighbour)
+	}
+}
+
+func TestEndpointExportFormat(t *testing.T) {
+	handle := makeEndpoint(func(req *http.Request, params httprouter.Params) (api.Response, error) {
+		return api.ConfigResponse{}, nil
+	})
+
+	// Not exportable
+	res := httptest.NewRecorder()
+	handle(res, httptest.NewRequest("GET", "/api/config?format=csv", nil), nil)
+	if res.Code != http.StatusBadRequest {
+		t.Error("Expected 400, got:", res.Code)
+	}
+
+	// Negotiated formats fall back to json
+	res = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/config", nil)
+	req.Header.Set("Accept", "text/csv")
+	handle(res, req, nil)
+	if res.Code != http.StatusOK || res.Header().Get("Content-Type") != "application/json" {
+		t.Error("Expected json response, got:", res.Code, res.Header())
+	}
+}
+
+func TestCsvText(t *testing.T) {
+	if csvText("=HYPERLINK(\"http://example.com\")") != "'=HYPERLINK(\"http://example.com\")" {
+		t.Error("Expected formula to be escaped")
+	}
+	if csvText("Example Networks") != "Example Networks" {
+		t.Error("Expected text to be unchanged")
+	}
+}
@@ -0,0 +1,238 @@
+package main
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ecix/alice-lg/backend/api"
+	"github.com/ecix/alice-lg/backend/sources"
+)
+
+// Export visibility
+//
+// The route server reports the routes withheld from a
+// target neighbour (noexport). A route is exported if it
+// is the best path and not withheld from the target.
+
+// Number of concurrent noexport requests for the matrix
+const EXPORT_MATRIX_CONCURRENCY = 8
+
+const NOEXPORTS_CACHE_MAX_ENTRIES = 1000
+
+type noexportsCacheKey struct {
+	sourceId    int
+	neighbourId string
+}
+
+// The routes not exported to a neighbour are kept until
+// the upstream cache expires: A single matrix request
+// would otherwise query the source for every neighbour.
+type NoexportsCache struct {
+	responses  map[noexportsCacheKey]api.RoutesResponse
+	maxEntries int
+
+	lock sync.Mutex
+}
+
+func NewNoexportsCache(maxEntries int) *NoexportsCache {
+	return &NoexportsCache{
+		responses:  make(map[noexportsCacheKey]api.RoutesResponse),
+		maxEntries: maxEntries,
+	}
+}
+
+// Get the routes not exported to a neighbour from the
+// cache, query the source if they are missing or expired.
+func (self *NoexportsCache) RoutesNotExported(
+	ctx context.Context,
+	source sources.Source,
+	sourceId int,
+	neighbourId string,
+) (api.RoutesResponse, error) {
+	if self == nil {
+		return source.RoutesNotExported(ctx, neighbourId)
+	}
+
+	key := noexportsCacheKey{sourceId, neighbourId}
+	now := time.Now()
+
+	self.lock.Lock()
+	response, ok := self.responses[key]
+	self.lock.Unlock()
+	if ok && response.Api.Ttl.After(now) {
+		return response, nil
+	}
+
+	response, err := source.RoutesNotExported(ctx, neighbourId)
+	if err != nil || !response.Api.Ttl.After(now) {
+		return response, err
+	}
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if len(self.responses) >= self.maxEntries {
+		for k, cached := range self.responses {
+			if !cached.Api.Ttl.After(now) {
+				delete(self.responses, k)
+			}
+		}
+	}
+	if len(self.responses) >= self.maxEntries {
+		for k, _ := range self.responses {
+			delete(self.responses, k)
+			break
+		}
+	}
+	self.responses[key] = response
+
+	return response, nil
+}
+
+type noexportKey struct {
+	neighbourId string
+	prefix      string
+}
+
+// Index the routes not exported to a target
+func makeNoexportIndex(routes []api.Route) map[noexportKey]api.Route {
+	index := make(map[noexportKey]api.Route)
+	for _, route := range routes {
+		index[noexportKey{route.NeighbourId, route.Network}] = route
+	}
+	return index
+}
+
+// Check the export of announced routes to a target
+func exportVisibility(
+	routes []api.Route,
+	noexport []api.Route,
+	config NoexportsConfig,
+) []api.ExportVisibility {
+	index := makeNoexportIndex(noexport)
+
+	results := []api.ExportVisibility{}
+	for _, route := range routes {
+		visibility := api.ExportVisibility{
+			Prefix:      route.Network,
+			NeighbourId: route.NeighbourId,
+			Primary:     route.Primary,
+		}
+		if len(route.Bgp.AsPath) > 0 {
+			visibility.Asn = route.Bgp.AsPath[0]
+		}
+
+		withheld, ok := index[noexportKey{route.NeighbourId, route.Network}]
+		if ok {
+			visibility.Reason = routeNoexportReasonDetail(withheld, config)
+		}
+		visibility.Exported = route.Primary && !ok
+
+		results = append(results, visibility)
+	}
+
+	return results
+}
+
+// Count the routes of a neighbour withheld from a target
+func countNoexports(
+	neighbourId string,
+	noexport []api.Route,
+	config NoexportsConfig,
+) (int, map[string]int) {
+	count := 0
+	reasons := make(map[string]int)
+
+	for _, route := range noexport {
+		if route.NeighbourId != neighbourId {
+			continue
+		}
+		count += 1
+
+		id, ok := largeCommunityReason(route, config.Asn, config.NoexportId)
+		if !ok {
+			reasons[REJECT_REASON_UNKNOWN] += 1
+			continue
+		}
+		reasons[strconv.Itoa(id)] += 1
+	}
+
+	return count, reasons
+}
+
+// Check which targets do not receive the routes of a neighbour.
+// Only established sessions are considered.
+func exportMatrix(
+	ctx context.Context,
+	source sources.Source,
+	sourceId int,
+	neighbourId string,
+	targets []api.Neighbour,
+	config NoexportsConfig,
+) []api.ExportMatrixEntry {
+	results := []api.ExportMatrixEntry{}
+	lock := &sync.Mutex{}
+	wg := &sync.WaitGroup{}
+	slots := make(chan bool, EXPORT_MATRIX_CONCURRENCY)
+
+	for _, target := range targets {
+		if target.Id == neighbourId || !isStateUp(target.State) {
+			continue
+		}
+
+		// The details are not of interest here
+		target.Details = nil
+
+		wg.Add(1)
+		go func(target api.Neighbour) {
+			defer wg.Done()
+			slots <- true
+			defer func() { <-slots }()
+
+			entry := api.ExportMatrixEntry{
+				Target:  target,
+				Reasons: map[string]int{},
+			}
+
+			routes, err := AliceNoexportsCache.RoutesNotExported(
+				ctx, source, sourceId, target.Id)
+			if err != nil {
+				entry.Error = err.Error()
+			} else {
+				entry.RoutesNotExported, entry.Reasons = countNoexports(
+					neighbourId, routes.NotExported, config)
+			}
+
+			lock.Lock()
+			results = append(results, entry)
+			lock.Unlock()
+		}(target)
+	}
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Target.Asn != results[j].Target.Asn {
+			return results[i].Target.Asn < results[j].Target.Asn
+		}
+		return results[i].Target.Id < results[j].Target.Id
+	})
+
+	return results
+}
+
+// Get the imported routes of a prefix on a route server
+func (self *RoutesStore) ImportedRoutesAt(sourceId int, prefix string) []api.Route {
+	self.rwlock.RLock()
+	routes := self.routesMap[sourceId]
+	self.rwlock.RUnlock()
+
+	results := []api.Route{}
+	for _, route := range routes.Imported {
+		if route.Network == prefix {
+			results = append(results, route)
+		}
+	}
+	return results
+}
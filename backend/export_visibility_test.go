@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+var testNoexportsConfig = NoexportsConfig{
+	Asn:        9033,
+	NoexportId: 65667,
+	Reasons: map[int]string{
+		1: "The target peer policy is Fairly-open and the sender ASN is an exception",
+	},
+}
+
+// A source with routes not exported to neighbours
+type noexportTestSource struct {
+	notExported map[string][]api.Route
+	ttl         time.Time
+	calls       int32
+}
+
+func (self *noexportTestSource) Status(ctx context.Context) (api.StatusResponse, error) {
+	return api.StatusResponse{}, nil
+}
+
+func (self *noexportTestSource) Neighbours(ctx context.Context) (api.NeighboursResponse, error) {
+	return api.NeighboursResponse{}, nil
+}
+
+func (self *noexportTestSource) Routes(ctx context.Context, neighbourId string) (api.RoutesResponse, error) {
+	return api.RoutesResponse{}, nil
+}
+
+func (self *noexportTestSource) RoutesNotExported(ctx context.Context, neighbourId string) (api.RoutesResponse, error) {
+	atomic.AddInt32(&self.calls, 1)
+	routes, ok := self.notExported[neighbourId]
+	if !ok {
+		return api.RoutesResponse{}, fmt.Errorf("Neighbour not found: %s", neighbourId)
+	}
+	return api.RoutesResponse{
+		Api:         api.ApiStatus{Ttl: self.ttl},
+		NotExported: routes,
+	}, nil
+}
+
+func (self *noexportTestSource) AllRoutes(ctx context.Context) (api.RoutesResponse, error) {
+	return api.RoutesResponse{}, nil
+}
+
+func TestExportVisibility(t *testing.T) {
+	routes := []api.Route{
+		{
+			NeighbourId: "ID1_AS2342",
+			Network:     "10.23.0.0/16",
+			Primary:     true,
+			Bgp:         api.BgpInfo{AsPath: []int{2342, 64500}},
+		},
+		{NeighbourId: "ID1_AS2342", Network: "10.42.0.0/16", Primary: true},
+		{NeighbourId: "ID1_AS2342", Network: "10.66.0.0/16", Primary: false},
+	}
+	noexport := []api.Route{
+		{
+			NeighbourId: "ID1_AS2342",
+			Network:     "10.42.0.0/16",
+			Bgp: api.BgpInfo{
+				LargeCommunities: []api.Community{{9033, 65667, 1}},
+			},
+		},
+	}
+
+	results := exportVisibility(routes, noexport, testNoexportsConfig)
+	if len(results) != 3 {
+		t.Fatal("Expected 3 results, got:", results)
+	}
+	if !results[0].Exported || results[0].Reason != nil || results[0].Asn != 2342 {
+		t.Error("Expected route to be exported:", results[0])
+	}
+	if results[1].Exported || results[1].Reason == nil || results[1].Reason.Id != 1 {
+		t.Error("Expected route not to be exported with a reason:", results[1])
+	}
+	if results[2].Exported {
+		t.Error("Only best paths are exported:", results[2])
+	}
+}
+
+func TestExportMatrix(t *testing.T) {
+	source := &noexportTestSource{
+		notExported: map[string][]api.Route{
+			"ID2_AS4224": []api.Route{
+				{
+					NeighbourId: "ID1_AS2342",
+					Network:     "10.42.0.0/16",
+					Bgp: api.BgpInfo{
+						LargeCommunities: []api.Community{{9033, 65667, 1}},
+					},
+				},
+				{NeighbourId: "ID1_AS2342", Network: "10.23.0.0/16"},
+				{NeighbourId: "ID4_AS1111", Network: "10.11.0.0/16"},
+			},
+			"ID3_AS3333": []api.Route{},
+		},
+	}
+	targets := []api.Neighbour{
+		{Id: "ID1_AS2342", Asn: 2342, State: "up"},
+		{Id: "ID3_AS3333", Asn: 3333, State: "up"},
+		{Id: "ID2_AS4224", Asn: 4224, State: "up"},
+		{Id: "ID5_AS5555", Asn: 5555, State: "start"},
+		{Id: "ID6_AS6666", Asn: 6666, State: "up"},
+	}
+
+	entries := exportMatrix(context.Background(), source, 0, "ID1_AS2342", targets, testNoexportsConfig)
+
+	// The neighbour itself and sessions not established are skipped
+	if len(entries) != 3 {
+		t.Fatal("Expected 3 targets, got:", entries)
+	}
+	if entries[0].Target.Asn != 3333 || entries[0].RoutesNotExported != 0 {
+		t.Error("Unexpected entry:", entries[0])
+	}
+	if entries[1].Target.Asn != 4224 || entries[1].RoutesNotExported != 2 ||
+		entries[1].Reasons["1"] != 1 || entries[1].Reasons[REJECT_REASON_UNKNOWN] != 1 {
+		t.Error("Unexpected entry:", entries[1])
+	}
+	if entries[2].Error == "" {
+		t.Error("Expected an error for target without data:", entries[2])
+	}
+}
+
+func TestNoexportsCache(t *testing.T) {
+	source := &noexportTestSource{
+		notExported: map[string][]api.Route{
+			"ID2_AS4224": []api.Route{{NeighbourId: "ID1_AS2342", Network: "10.42.0.0/16"}},
+			"ID3_AS3333": []api.Route{},
+		},
+		ttl: time.Now().Add(time.Minute),
+	}
+	targets := []api.Neighbour{
+		{Id: "ID2_AS4224", Asn: 4224, State: "up"},
+		{Id: "ID3_AS3333", Asn: 3333, State: "up"},
+		{Id: "ID6_AS6666", Asn: 6666, State: "up"},
+	}
+
+	AliceNoexportsCache = NewNoexportsCache(NOEXPORTS_CACHE_MAX_ENTRIES)
+	defer func() {
+		AliceNoexportsCache = nil
+	}()
+
+	exportMatrix(context.Background(), source, 0, "ID1_AS2342", targets, testNoexportsConfig)
+	if source.calls != 3 {
+		t.Error("Expected 3 calls, got:", source.calls)
+	}
+
+	// Only the failed request is repeated
+	entries := exportMatrix(context.Background(), source, 0, "ID1_AS2342", targets, testNoexportsConfig)
+	if source.calls != 4 {
+		t.Error("Expected cached responses, got calls:", source.calls)
+	}
+	if entries[1].RoutesNotExported != 1 {
+		t.Error("Unexpected entry:", entries[1])
+	}
+
+	// Responses of other sources are not shared
+	exportMatrix(context.Background(), source, 1, "ID1_AS2342", targets, testNoexportsConfig)
+	if source.calls != 7 {
+		t.Error("Expected 7 calls, got:", source.calls)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// File reloader
+//
+// Datasets loaded from local files (RPKI VRPs, IRR dumps,
+// ASN metadata) are reloaded periodically, if any of the
+// files was modified. If loading fails, the previous data
+// is kept and the error is reported in the status.
+
+// Load the files and replace the data of the dataset
+type fileLoader func() error
+
+type FileReloader struct {
+	name     string
+	files    []string
+	interval time.Duration
+	load     fileLoader
+
+	modTimes map[string]time.Time
+	status   StoreStatus
+
+	rwlock *sync.RWMutex
+}
+
+func NewFileReloader(
+	name string,
+	files []string,
+	interval time.Duration,
+	load fileLoader,
+) *FileReloader {
+	reloader := &FileReloader{
+		name:     name,
+		files:    files,
+		interval: interval,
+		load:     load,
+		modTimes: make(map[string]time.Time),
+		status: StoreStatus{
+			State: STATE_INIT,
+		},
+
+		rwlock: &sync.RWMutex{},
+	}
+	return reloader
+}
+
+// Load the files before returning, so the stores can
+// annotate their first refresh, then reload periodically.
+func (self *FileReloader) Start(ctx context.Context) {
+	self.update()
+	go self.init(ctx)
+}
+
+func (self *FileReloader) init(ctx context.Context) {
+	for {
+		if !waitInterval(ctx, self.interval) {
+			return
+		}
+		self.update()
+	}
+}
+
+// Reload the dataset if any of the files was modified
+func (self *FileReloader) update() {
+	modTimes := make(map[string]time.Time)
+	for _, filename := range self.files {
+		info, err := os.Stat(filename)
+		if err != nil {
+			self.setError(err)
+			return
+		}
+		modTimes[filename] = info.ModTime()
+	}
+
+	self.rwlock.RLock()
+	modified := len(self.modTimes) != len(modTimes)
+	for filename, modTime := range modTimes {
+		if !self.modTimes[filename].Equal(modTime) {
+			modified = true
+		}
+	}
+	self.rwlock.RUnlock()
+
+	if !modified {
+		return // nothing changed
+	}
+
+	if err := self.load(); err != nil {
+		self.setError(err)
+		return
+	}
+
+	self.rwlock.Lock()
+	self.modTimes = modTimes
+	self.status = StoreStatus{
+		LastRefresh: time.Now(),
+		State:       STATE_READY,
+	}
+	self.rwlock.Unlock()
+}
+
+// Keep the previous data, but report the error
+func (self *FileReloader) setError(err error) {
+	log.Println("Error while loading", self.name+":", err)
+
+	self.rwlock.Lock()
+	self.status = StoreStatus{
+		State:       STATE_ERROR,
+		LastError:   err,
+		LastRefresh: time.Now(),
+	}
+	self.rwlock.Unlock()
+}
+
+func (self *FileReloader) Status() StoreStatus {
+	self.rwlock.RLock()
+	defer self.rwlock.RUnlock()
+	return self.status
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFileReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alice-reloader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "data.txt")
+
+	loads := 0
+	var loadErr error
+	reloader := NewFileReloader("test data", []string{filename}, time.Minute,
+		func() error {
+			loads += 1
+			return loadErr
+		})
+
+	// Missing file
+	reloader.update()
+	if status := reloader.Status(); status.State != STATE_ERROR || loads != 0 {
+		t.Error("Expected error state, got:", status, loads)
+	}
+
+	ioutil.WriteFile(filename, []byte("data"), 0600)
+	reloader.update()
+	status := reloader.Status()
+	if status.State != STATE_READY || status.LastRefresh.IsZero() || loads != 1 {
+		t.Error("Expected data to be loaded, got:", status, loads)
+	}
+
+	// Unmodified files are not loaded again
+	reloader.update()
+	if loads != 1 {
+		t.Error("Expected no reload, got loads:", loads)
+	}
+
+	// Failed loads are retried
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(filename, later, later)
+	loadErr = fmt.Errorf("broken data")
+	reloader.update()
+	reloader.update()
+	if status := reloader.Status(); status.State != STATE_ERROR || loads != 3 {
+		t.Error("Expected load to be retried, got:", status, loads)
+	}
+}
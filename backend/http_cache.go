@@ -0,0 +1,295 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+// HTTP caching
+//
+// Responses fetched from a routeserver carry validators
+// derived from the cache ttl of the upstream response.
+// Responses served from the stores are validated by the
+// time of the last store refresh; their encoded payload is
+// kept until the stores are refreshed again.
+
+const RESPONSE_CACHE_MAX_ENTRIES = 1000
+
+// Validators and freshness of a response
+type cacheValidators struct {
+	ETag         string
+	LastModified time.Time
+	Expires      time.Time // zero: always revalidate
+}
+
+// An encoded response of a store backed endpoint
+type cachedResponse struct {
+	Validators cacheValidators
+	Payload    []byte
+	Gzipped    bool
+}
+
+type ResponseCache struct {
+	responses  map[string]*cachedResponse
+	version    time.Time
+	maxEntries int
+
+	lock sync.Mutex
+}
+
+func NewResponseCache(maxEntries int) *ResponseCache {
+	return &ResponseCache{
+		responses:  make(map[string]*cachedResponse),
+		maxEntries: maxEntries,
+	}
+}
+
+// Get a response encoded for the current version of the stores
+func (self *ResponseCache) Get(key string, version time.Time) (*cachedResponse, bool) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if !self.version.Equal(version) {
+		return nil, false
+	}
+	response, ok := self.responses[key]
+	return response, ok
+}
+
+// Add a response, all responses of a previous
+// version of the stores are dropped.
+func (self *ResponseCache) Set(key string, version time.Time, response *cachedResponse) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	if version.Before(self.version) {
+		return // stale
+	}
+	if !self.version.Equal(version) {
+		self.responses = make(map[string]*cachedResponse)
+		self.version = version
+	}
+
+	if len(self.responses) >= self.maxEntries {
+		for k, _ := range self.responses {
+			delete(self.responses, k)
+			break
+		}
+	}
+	self.responses[key] = response
+}
+
+// Number of cached responses
+func (self *ResponseCache) Len() int {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return len(self.responses)
+}
+
+// Time of the last successful refresh of any source
+func (self *RoutesStore) RefreshedAt() time.Time {
+	self.rwlock.RLock()
+	defer self.rwlock.RUnlock()
+	return latestRefresh(self.refreshMap)
+}
+
+func (self *NeighboursStore) RefreshedAt() time.Time {
+	self.rwlock.RLock()
+	defer self.rwlock.RUnlock()
+	return latestRefresh(self.refreshMap)
+}
+
+func latestRefresh(refreshMap map[int]time.Time) time.Time {
+	refreshedAt := time.Time{}
+	for _, refresh := range refreshMap {
+		if refresh.After(refreshedAt) {
+			refreshedAt = refresh
+		}
+	}
+	return refreshedAt
+}
+
+// The version of the data in the stores: Every
+// refresh of a source results in a new version.
+func storesRefreshedAt() time.Time {
+	refreshedAt := time.Time{}
+	if AliceRoutesStore != nil {
+		refreshedAt = AliceRoutesStore.RefreshedAt()
+	}
+	if AliceNeighboursStore != nil {
+		refresh := AliceNeighboursStore.RefreshedAt()
+		if refresh.After(refreshedAt) {
+			refreshedAt = refresh
+		}
+	}
+	return refreshedAt
+}
+
+// The version of the data used to annotate responses of
+// the routeservers: RPKI, IRR and ASN metadata are reloaded
+// independently of the stores.
+func annotationsRefreshedAt() time.Time {
+	refreshes := []time.Time{}
+	if AliceRpkiValidator != nil {
+		refreshes = append(refreshes, AliceRpkiValidator.Stats().UpdatedAt)
+	}
+	if AliceIrrDatabase != nil {
+		refreshes = append(refreshes, AliceIrrDatabase.Stats().UpdatedAt)
+	}
+	if AliceAsnMetadata != nil {
+		refreshes = append(refreshes, AliceAsnMetadata.Stats().UpdatedAt)
+	}
+	return latestTime(refreshes...)
+}
+
+func latestTime(times ...time.Time) time.Time {
+	latest := time.Time{}
+	for _, t := range times {
+		if t.After(latest) {
+			latest = t
+		}
+	}
+	return latest
+}
+
+func acceptsGzip(req *http.Request) bool {
+	return strings.Contains(req.Header.Get("Accept-Encoding"), "gzip")
+}
+
+// Identify the representation of a response: The
+// redaction depends on the access tier of the client.
+func responseCacheKey(req *http.Request, format string) string {
+	return fmt.Sprintf("%s?%s|%s|%s|%t",
+		req.URL.Path, req.URL.RawQuery,
+		requestAccess(req).Tier.Name, format, acceptsGzip(req))
+}
+
+func makeETag(values ...interface{}) string {
+	hash := fnv.New64a()
+	fmt.Fprint(hash, values...)
+	return fmt.Sprintf(`W/"%x"`, hash.Sum64())
+}
+
+// Responses of store backed endpoints are
+// valid until the next refresh.
+func makeStoreValidators(key string, version time.Time) cacheValidators {
+	return cacheValidators{
+		ETag:         makeETag(key, "|", version.UnixNano()),
+		LastModified: version,
+	}
+}
+
+// Get the api status of a routeserver response
+func responseApiStatus(result api.Response) (api.ApiStatus, bool) {
+	value := reflect.Indirect(reflect.ValueOf(result))
+	if value.Kind() != reflect.Struct {
+		return api.ApiStatus{}, false
+	}
+	field := value.FieldByName("Api")
+	if !field.IsValid() {
+		return api.ApiStatus{}, false
+	}
+	status, ok := field.Interface().(api.ApiStatus)
+	return status, ok
+}
+
+// Responses of routeservers are fresh until the upstream
+// cache expires. Responses are annotated per request with
+// RPKI, IRR and ASN metadata and the neighbours of the
+// stores, so the versions of these are included.
+func makeUpstreamValidators(key string, result api.Response) (cacheValidators, bool) {
+	status, ok := responseApiStatus(result)
+	if !ok || status.Ttl.IsZero() {
+		return cacheValidators{}, false
+	}
+
+	cachedAt := status.CacheStatus.CachedAt
+	storesRefresh := storesRefreshedAt()
+	annotationsRefresh := annotationsRefreshedAt()
+
+	return cacheValidators{
+		ETag: makeETag(key, "|", status.Ttl.UnixNano(),
+			"|", cachedAt.UnixNano(),
+			"|", storesRefresh.UnixNano(),
+			"|", annotationsRefresh.UnixNano()),
+		// Modified with any of the versions of the ETag
+		LastModified: latestTime(cachedAt, storesRefresh, annotationsRefresh),
+		Expires:      status.Ttl,
+	}, true
+}
+
+func writeCacheHeaders(res http.ResponseWriter, validators cacheValidators) {
+	header := res.Header()
+	header.Set("ETag", validators.ETag)
+	if !validators.LastModified.IsZero() {
+		header.Set("Last-Modified",
+			validators.LastModified.UTC().Format(http.TimeFormat))
+	}
+
+	// Responses depend on the api key
+	scope := "public"
+	vary := "Accept, Accept-Encoding"
+	if AliceAccessKeys != nil {
+		scope = "private"
+		vary += ", Authorization, " + API_KEY_HEADER
+	}
+	header.Set("Vary", vary)
+
+	if validators.Expires.IsZero() {
+		header.Set("Cache-Control", scope+", no-cache")
+		return
+	}
+	maxAge := int(time.Until(validators.Expires).Seconds())
+	if maxAge < 0 {
+		maxAge = 0
+	}
+	header.Set("Cache-Control", fmt.Sprintf("%s, max-age=%d", scope, maxAge))
+}
+
+// Check the conditional request headers, the
+// If-None-Match header takes precedence.
+func notModified(req *http.Request, validators cacheValidators) bool {
+	if req.Header.Get("If-None-Match") != "" {
+		return etagMatches(req, validators.ETag)
+	}
+
+	since, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	if err != nil || validators.LastModified.IsZero() {
+		return false
+	}
+	return !validators.LastModified.Truncate(time.Second).After(since)
+}
+
+// Encode the result as json, compress if requested
+func encodeJsonResponse(result interface{}, gzipped bool) ([]byte, error) {
+	payload, err := json.Marshal(result)
+	if err != nil || !gzipped {
+		return payload, err
+	}
+
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	gz.Write(payload)
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeJsonPayload(res http.ResponseWriter, payload []byte, gzipped bool) {
+	res.Header().Set("Content-Type", "application/json")
+	if gzipped {
+		res.Header().Set("Content-Encoding", "gzip")
+	}
+	res.Write(payload)
+}
@@ -0,0 +1,191 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecix/alice-lg/backend/api"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func requestCached(handle httprouter.Handle, url string, header http.Header) *httptest.ResponseRecorder {
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", url, nil)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	handle(res, req, nil)
+	return res
+}
+
+func TestResponseCache(t *testing.T) {
+	cache := NewResponseCache(2)
+	version := time.Unix(1500000000, 0)
+
+	cache.Set("a", version, &cachedResponse{Payload: []byte("a")})
+	if _, ok := cache.Get("a", version); !ok {
+		t.Error("Expected cached response")
+	}
+	if _, ok := cache.Get("a", version.Add(time.Minute)); ok {
+		t.Error("Expected response of previous version to be invalid")
+	}
+
+	cache.Set("b", version, &cachedResponse{})
+	cache.Set("c", version, &cachedResponse{})
+	if len(cache.responses) != 2 {
+		t.Error("Expected at most 2 responses, got:", len(cache.responses))
+	}
+
+	// A new version drops all responses
+	cache.Set("d", version.Add(time.Minute), &cachedResponse{})
+	if len(cache.responses) != 1 {
+		t.Error("Expected responses to be dropped, got:", len(cache.responses))
+	}
+
+	cache.Set("e", version, &cachedResponse{})
+	if _, ok := cache.Get("e", version); ok {
+		t.Error("Expected stale response to be ignored")
+	}
+}
+
+func TestEndpointUpstreamValidators(t *testing.T) {
+	ttl := time.Now().Add(5 * time.Minute)
+	handle := makeEndpoint(func(req *http.Request, params httprouter.Params) (api.Response, error) {
+		return api.NeighboursResponse{
+			Api: api.ApiStatus{
+				Ttl: ttl,
+				CacheStatus: api.CacheStatus{
+					CachedAt: time.Unix(1500000000, 0),
+				},
+			},
+		}, nil
+	})
+
+	res := requestCached(handle, "/api/routeservers/0/neighbours", nil)
+	etag := res.Header().Get("ETag")
+	if res.Code != http.StatusOK || !strings.HasPrefix(etag, `W/"`) {
+		t.Fatal("Expected weak ETag, got:", res.Code, etag)
+	}
+	cacheControl := res.Header().Get("Cache-Control")
+	if cacheControl != "public, max-age=299" && cacheControl != "public, max-age=300" {
+		t.Error("Expected max-age matching the ttl, got:", cacheControl)
+	}
+	if res.Header().Get("Last-Modified") != "Fri, 14 Jul 2017 02:40:00 GMT" {
+		t.Error("Unexpected Last-Modified:", res.Header().Get("Last-Modified"))
+	}
+
+	res = requestCached(handle, "/api/routeservers/0/neighbours",
+		http.Header{"If-None-Match": []string{etag}})
+	if res.Code != http.StatusNotModified || res.Body.Len() != 0 {
+		t.Error("Expected 304, got:", res.Code)
+	}
+
+	// Reloading the annotations changes the ETag
+	AliceAsnMetadata = NewAsnMetadata(AsnMetadataConfig{})
+	AliceAsnMetadata.reloader.status.LastRefresh = time.Unix(1500000060, 0)
+	defer func() {
+		AliceAsnMetadata = nil
+	}()
+	res = requestCached(handle, "/api/routeservers/0/neighbours",
+		http.Header{"If-None-Match": []string{etag}})
+	if res.Code != http.StatusOK || res.Header().Get("ETag") == etag {
+		t.Error("Expected ETag to depend on the ASN metadata, got:", res.Code)
+	}
+	if res.Header().Get("Last-Modified") != "Fri, 14 Jul 2017 02:41:00 GMT" {
+		t.Error("Expected reload as Last-Modified, got:",
+			res.Header().Get("Last-Modified"))
+	}
+	etag = res.Header().Get("ETag")
+
+	// The representation depends on the encoding
+	res = requestCached(handle, "/api/routeservers/0/neighbours",
+		http.Header{
+			"If-None-Match":   []string{etag},
+			"Accept-Encoding": []string{"gzip"},
+		})
+	if res.Code != http.StatusOK || res.Header().Get("Content-Encoding") != "gzip" {
+		t.Error("Expected gzipped response, got:", res.Code)
+	}
+
+	res = requestCached(handle, "/api/routeservers/0/neighbours",
+		http.Header{"If-Modified-Since": []string{"Fri, 14 Jul 2017 02:40:00 GMT"}})
+	if res.Code != http.StatusOK {
+		t.Error("Expected modified response after reload, got:", res.Code)
+	}
+	res = requestCached(handle, "/api/routeservers/0/neighbours",
+		http.Header{"If-Modified-Since": []string{"Fri, 14 Jul 2017 02:41:00 GMT"}})
+	if res.Code != http.StatusNotModified {
+		t.Error("Expected 304, got:", res.Code)
+	}
+
+	// Responses without api status are not cached
+	handle = makeEndpoint(func(req *http.Request, params httprouter.Params) (api.Response, error) {
+		return api.ConfigResponse{}, nil
+	})
+	res = requestCached(handle, "/api/config", nil)
+	if res.Header().Get("ETag") != "" || res.Header().Get("Cache-Control") != "" {
+		t.Error("Unexpected cache headers:", res.Header())
+	}
+}
+
+func TestStoreEndpointCache(t *testing.T) {
+	AliceRoutesStore = makeRoutesExportTestStore()
+	AliceResponseCache = NewResponseCache(RESPONSE_CACHE_MAX_ENTRIES)
+	defer func() {
+		AliceRoutesStore = nil
+		AliceResponseCache = nil
+		AliceConfig = nil
+	}()
+
+	calls := 0
+	handle := makeStoreEndpoint(func(req *http.Request, params httprouter.Params) (api.Response, error) {
+		calls++
+		return &api.RoutesLookupResponseGlobal{Routes: []api.LookupRoute{}}, nil
+	})
+
+	url := "/api/lookup/prefix?q=10.23.0.0"
+	res := requestCached(handle, url, http.Header{"Accept-Encoding": []string{"gzip"}})
+	if res.Code != http.StatusOK || res.Header().Get("Cache-Control") != "public, no-cache" {
+		t.Fatal("Expected revalidated response, got:", res.Code, res.Header())
+	}
+	if res.Header().Get("Last-Modified") != "Fri, 14 Jul 2017 02:40:00 GMT" {
+		t.Error("Expected store refresh as Last-Modified, got:",
+			res.Header().Get("Last-Modified"))
+	}
+	payload := res.Body.Bytes()
+	etag := res.Header().Get("ETag")
+
+	// Served from the cache
+	res = requestCached(handle, url, http.Header{"Accept-Encoding": []string{"gzip"}})
+	if calls != 1 || res.Body.String() != string(payload) {
+		t.Error("Expected cached response, handler calls:", calls)
+	}
+	if res.Header().Get("Content-Encoding") != "gzip" || res.Header().Get("ETag") != etag {
+		t.Error("Unexpected headers:", res.Header())
+	}
+
+	res = requestCached(handle, url, http.Header{"If-None-Match": []string{etag}})
+	if res.Code != http.StatusOK {
+		t.Error("Expected ETag to depend on the encoding, got:", res.Code)
+	}
+
+	// The query is part of the key
+	requestCached(handle, url+"&limit=10", nil)
+	if calls != 3 {
+		t.Error("Expected handler to be called, calls:", calls)
+	}
+
+	// Refresh of the store
+	AliceRoutesStore.refreshMap[1] = time.Unix(1500000060, 0)
+	res = requestCached(handle, url, http.Header{
+		"Accept-Encoding": []string{"gzip"},
+		"If-None-Match":   []string{etag},
+	})
+	if res.Code != http.StatusOK || calls != 4 {
+		t.Error("Expected new response after refresh, got:", res.Code, calls)
+	}
+}
@@ -0,0 +1,375 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+// IRR Database
+//
+// Route, route6 and as-set objects are loaded from
+// local RPSL dump files (e.g. ripe.db.route.gz).
+// Routes are checked whether the origin is a member of
+// the neighbour's AS-SET and whether a route object
+// for the prefix and origin is registered.
+
+type RpslObject map[string][]string
+
+// Map prefixes to registered origins
+type IrrRoutesIndex map[string]map[int]bool
+
+// Map AS-SET names to members (ASNs and sets)
+type IrrAsSetsIndex map[string][]string
+
+type IrrDatabase struct {
+	config IrrConfig
+
+	routes   IrrRoutesIndex
+	asSets   IrrAsSetsIndex
+	reloader *FileReloader
+
+	// Expanded AS-SETs are cached until the next reload,
+	// every reload starts a new generation.
+	expanded   map[string]map[int]bool
+	generation int
+
+	rwlock *sync.RWMutex
+}
+
+func NewIrrDatabase(config IrrConfig) *IrrDatabase {
+	database := &IrrDatabase{
+		config:   config,
+		routes:   make(IrrRoutesIndex),
+		asSets:   make(IrrAsSetsIndex),
+		expanded: make(map[string]map[int]bool),
+
+		rwlock: &sync.RWMutex{},
+	}
+	database.reloader = NewFileReloader("IRR data",
+		config.Files, config.RefreshInterval, database.load)
+	return database
+}
+
+func (self *IrrDatabase) Start(ctx context.Context) {
+	log.Println("Starting IRR database using:", self.config.Files)
+
+	// Load the dumps before the stores start to fetch routes.
+	self.reloader.Start(ctx)
+
+	// Initial logging
+	self.Stats().Log()
+}
+
+// Load all dump files
+func (self *IrrDatabase) load() error {
+	routes := make(IrrRoutesIndex)
+	asSets := make(IrrAsSetsIndex)
+	for _, filename := range self.config.Files {
+		err := loadRpslFile(filename, routes, asSets)
+		if err != nil {
+			return err
+		}
+	}
+
+	self.rwlock.Lock()
+	self.routes = routes
+	self.asSets = asSets
+	self.expanded = make(map[string]map[int]bool)
+	self.generation += 1
+	self.rwlock.Unlock()
+
+	return nil
+}
+
+// Get the AS-SET for a neighbour: Use the configured
+// AS-SET, fall back to the neighbour ASN.
+func (self *IrrDatabase) AsSetForNeighbour(asn int) string {
+	asSet, ok := self.config.AsSets[asn]
+	if !ok {
+		return "AS" + strconv.Itoa(asn)
+	}
+	return strings.ToUpper(asSet)
+}
+
+// Recursively resolve all ASNs in an AS-SET.
+// A plain ASN expands to itself.
+func (self *IrrDatabase) ExpandAsSet(name string) map[int]bool {
+	name = strings.ToUpper(name)
+
+	self.rwlock.RLock()
+	members, ok := self.expanded[name]
+	self.rwlock.RUnlock()
+	if ok {
+		return members
+	}
+
+	members = make(map[int]bool)
+	visited := make(map[string]bool)
+
+	self.rwlock.RLock()
+	generation := self.generation
+	self.expandAsSet(name, members, visited)
+	self.rwlock.RUnlock()
+
+	// The objects may have been reloaded meanwhile
+	self.rwlock.Lock()
+	if self.generation == generation {
+		self.expanded[name] = members
+	}
+	self.rwlock.Unlock()
+
+	return members
+}
+
+func (self *IrrDatabase) expandAsSet(
+	name string,
+	members map[int]bool,
+	visited map[string]bool,
+) {
+	if visited[name] {
+		return // loops are quite common
+	}
+	visited[name] = true
+
+	if asn, err := parseRpslAsn(name); err == nil {
+		members[asn] = true
+		return
+	}
+
+	for _, member := range self.asSets[name] {
+		self.expandAsSet(member, members, visited)
+	}
+}
+
+// Check if a route object for the prefix
+// and origin is registered
+func (self *IrrDatabase) HasRouteObject(prefix string, origin int) bool {
+	_, network, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return false
+	}
+
+	self.rwlock.RLock()
+	defer self.rwlock.RUnlock()
+
+	return self.routes[network.String()][origin]
+}
+
+// Check a route against the AS-SET of the neighbour
+func (self *IrrDatabase) Check(route api.Route, neighbourAsn int) api.IrrCheck {
+	origin := 0
+	if len(route.Bgp.AsPath) > 0 {
+		origin = route.Bgp.AsPath[len(route.Bgp.AsPath)-1]
+	}
+
+	asSet := self.AsSetForNeighbour(neighbourAsn)
+	members := self.ExpandAsSet(asSet)
+
+	return api.IrrCheck{
+		AsSet:         asSet,
+		OriginInAsSet: members[origin],
+		RouteObject:   self.HasRouteObject(route.Network, origin),
+	}
+}
+
+// Check a list of routes of a route server.
+// The neighbour ASN is taken from the neighbours store
+// with the first ASN in the path as fallback.
+func (self *IrrDatabase) AnnotateRoutes(sourceId int, routes []api.Route) {
+	for i, route := range routes {
+		asn := routeNeighbourAsn(sourceId, route)
+		check := self.Check(route, asn)
+		routes[i].Irr = &check
+	}
+}
+
+// Build some stats for monitoring
+func (self *IrrDatabase) Stats() IrrDatabaseStats {
+	status := self.reloader.Status()
+
+	self.rwlock.RLock()
+	defer self.rwlock.RUnlock()
+
+	lastError := ""
+	if status.LastError != nil {
+		lastError = status.LastError.Error()
+	}
+
+	return IrrDatabaseStats{
+		Files:     self.config.Files,
+		State:     stateToString(status.State),
+		Routes:    len(self.routes),
+		AsSets:    len(self.asSets),
+		UpdatedAt: status.LastRefresh,
+		LastError: lastError,
+	}
+}
+
+// Parse an ASN in RPSL notation: AS123
+func parseRpslAsn(value string) (int, error) {
+	digits := strings.TrimPrefix(value, "AS")
+	if digits == value || digits == "" ||
+		strings.TrimLeft(digits, "0123456789") != "" {
+		return 0, fmt.Errorf("Not an ASN: %s", value)
+	}
+	return strconv.Atoi(digits)
+}
+
+// Open a dump file, gzipped dumps are supported
+func openRpslFile(filename string) (io.ReadCloser, error) {
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if !strings.HasSuffix(filename, ".gz") {
+		return file, nil
+	}
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{reader, file}, nil
+}
+
+// Load route, route6 and as-set objects from a
+// dump file into the indices
+func loadRpslFile(
+	filename string,
+	routes IrrRoutesIndex,
+	asSets IrrAsSetsIndex,
+) error {
+	file, err := openRpslFile(filename)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return parseRpsl(file, func(object RpslObject) {
+		indexRpslObject(object, routes, asSets)
+	})
+}
+
+// Add object to the routes or as-sets index
+func indexRpslObject(
+	object RpslObject,
+	routes IrrRoutesIndex,
+	asSets IrrAsSetsIndex,
+) {
+	prefixes := make([]string, 0, len(object["route"])+len(object["route6"]))
+	prefixes = append(prefixes, object["route"]...)
+	prefixes = append(prefixes, object["route6"]...)
+	if len(prefixes) > 0 {
+		_, network, err := net.ParseCIDR(prefixes[0])
+		if err != nil {
+			return
+		}
+		origins := object["origin"]
+		if len(origins) == 0 {
+			return
+		}
+		origin, err := parseRpslAsn(strings.ToUpper(origins[0]))
+		if err != nil {
+			return
+		}
+
+		key := network.String()
+		if routes[key] == nil {
+			routes[key] = make(map[int]bool)
+		}
+		routes[key][origin] = true
+		return
+	}
+
+	names := object["as-set"]
+	if len(names) > 0 {
+		name := strings.ToUpper(names[0])
+		members := make([]string, 0,
+			len(object["members"])+len(object["mp-members"]))
+		members = append(members, object["members"]...)
+		members = append(members, object["mp-members"]...)
+		for _, value := range members {
+			fields := strings.FieldsFunc(value, func(c rune) bool {
+				return c == ',' || c == ' ' || c == '\t'
+			})
+			for _, member := range fields {
+				asSets[name] = append(asSets[name], strings.ToUpper(member))
+			}
+		}
+	}
+}
+
+// Parse RPSL objects: Objects are separated by empty lines,
+// attributes may span multiple lines.
+func parseRpsl(reader io.Reader, handle func(RpslObject)) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	object := make(RpslObject)
+	key := ""
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		// End of object
+		if strings.TrimSpace(line) == "" {
+			if len(object) > 0 {
+				handle(object)
+			}
+			object = make(RpslObject)
+			key = ""
+			continue
+		}
+
+		// Comments
+		if line[0] == '%' || line[0] == '#' {
+			continue
+		}
+
+		// Strip trailing comments
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+
+		// Continuation of the previous attribute
+		if line[0] == ' ' || line[0] == '\t' || line[0] == '+' {
+			if key == "" {
+				continue
+			}
+			values := object[key]
+			values[len(values)-1] += " " + strings.TrimSpace(line[1:])
+			continue
+		}
+
+		i := strings.Index(line, ":")
+		if i < 0 {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(line[:i]))
+		value := strings.TrimSpace(line[i+1:])
+		object[key] = append(object[key], value)
+	}
+
+	// Last object without trailing newline
+	if len(object) > 0 {
+		handle(object)
+	}
+
+	return scanner.Err()
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+const RPSL_DUMP = `
+% This is a comment
+
+route:          193.200.230.0/24
+descr:          Some route
+origin:         AS201785
+mnt-by:         MNT-FOO
+source:         RIPE
+
+route6:         2001:db8::/32
+origin:         as31078 # trailing comment
+source:         RIPE
+
+as-set:         AS-NETSIGN
+descr:          Customers
+members:        AS31078, AS-CUSTOMERS
+source:         RIPE
+
+as-set:         AS-CUSTOMERS
+members:        AS201785,
+                AS65001
++               AS-NETSIGN
+source:         RIPE`
+
+func makeIrrDatabase(t *testing.T) *IrrDatabase {
+	database := NewIrrDatabase(IrrConfig{
+		AsSets: map[int]string{
+			31078: "as-netsign",
+		},
+	})
+
+	err := parseRpsl(strings.NewReader(RPSL_DUMP), func(object RpslObject) {
+		indexRpslObject(object, database.routes, database.asSets)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return database
+}
+
+func TestParseRpsl(t *testing.T) {
+	objects := []RpslObject{}
+	err := parseRpsl(strings.NewReader(RPSL_DUMP), func(object RpslObject) {
+		objects = append(objects, object)
+	})
+	if err != nil {
+		t.Error(err)
+	}
+
+	if len(objects) != 4 {
+		t.Error("Expected 4 objects, got:", len(objects))
+	}
+
+	members := objects[3]["members"]
+	if len(members) != 1 || members[0] != "AS201785, AS65001 AS-NETSIGN" {
+		t.Error("Unexpected members:", members)
+	}
+
+	if objects[1]["origin"][0] != "as31078" {
+		t.Error("Unexpected origin:", objects[1]["origin"])
+	}
+}
+
+func TestIrrExpandAsSet(t *testing.T) {
+	database := makeIrrDatabase(t)
+
+	members := database.ExpandAsSet("AS-NETSIGN")
+	for _, asn := range []int{31078, 201785, 65001} {
+		if !members[asn] {
+			t.Error("Expected AS", asn, "to be member of AS-NETSIGN")
+		}
+	}
+	if len(members) != 3 {
+		t.Error("Expected 3 members, got:", len(members))
+	}
+
+	members = database.ExpandAsSet("AS23")
+	if len(members) != 1 || !members[23] {
+		t.Error("Expected plain ASN to expand to itself:", members)
+	}
+}
+
+func TestIrrCheck(t *testing.T) {
+	database := makeIrrDatabase(t)
+
+	route := api.Route{
+		Network: "193.200.230.0/24",
+		Bgp: api.BgpInfo{
+			AsPath: []int{31078, 201785},
+		},
+	}
+
+	check := database.Check(route, 31078)
+	if check.AsSet != "AS-NETSIGN" {
+		t.Error("Expected configured AS-SET, got:", check.AsSet)
+	}
+	if !check.OriginInAsSet || !check.RouteObject {
+		t.Error("Expected route to be compliant:", check)
+	}
+
+	// Neighbour without configured AS-SET
+	check = database.Check(route, 25074)
+	if check.AsSet != "AS25074" {
+		t.Error("Expected ASN as AS-SET, got:", check.AsSet)
+	}
+	if check.OriginInAsSet {
+		t.Error("Expected origin not to be in AS25074")
+	}
+
+	// No route object for IPv6 prefix
+	route = api.Route{
+		Network: "2001:db8:23::/48",
+		Bgp: api.BgpInfo{
+			AsPath: []int{31078},
+		},
+	}
+	check = database.Check(route, 31078)
+	if check.RouteObject {
+		t.Error("Expected no route object for more specific prefix")
+	}
+	if !database.HasRouteObject("2001:db8::/32", 31078) {
+		t.Error("Expected route6 object to be present")
+	}
+}
+
+func TestParseRpslAsn(t *testing.T) {
+	expected := []struct {
+		value string
+		asn   int
+		valid bool
+	}{
+		{"AS31078", 31078, true},
+		{"AS-123", 0, false},
+		{"AS+123", 0, false},
+		{"AS", 0, false},
+		{"AS-CUSTOMERS", 0, false},
+		{"31078", 0, false},
+	}
+	for _, e := range expected {
+		asn, err := parseRpslAsn(e.value)
+		if (err == nil) != e.valid || asn != e.asn {
+			t.Error("Unexpected result for", e.value, "-", asn, err)
+		}
+	}
+}
+
+func TestIndexRpslObjectKeepsAttributes(t *testing.T) {
+	members := make([]string, 1, 4)
+	members[0] = "AS23"
+	object := RpslObject{
+		"as-set":     []string{"AS-SET"},
+		"members":    members,
+		"mp-members": []string{"AS42"},
+	}
+
+	asSets := make(IrrAsSetsIndex)
+	indexRpslObject(object, make(IrrRoutesIndex), asSets)
+	if len(asSets["AS-SET"]) != 2 {
+		t.Error("Unexpected members:", asSets["AS-SET"])
+	}
+	if members[:2][1] != "" {
+		t.Error("Expected attribute not to be modified:", members[:2])
+	}
+}
@@ -0,0 +1,165 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// Lifecycle of the services and http servers
+//
+// Background services run until the context of the lifecycle
+// is done. On shutdown the context is cancelled, the servers
+// are drained and the shutdown hooks are run, e.g. to flush
+// the store snapshot.
+
+type lifecycleServer struct {
+	server *http.Server
+	serve  func() error
+}
+
+type Lifecycle struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	servers []lifecycleServer
+	hooks   []func(context.Context) error
+
+	shutdownTimeout time.Duration
+}
+
+func NewLifecycle(shutdownTimeout time.Duration) *Lifecycle {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Lifecycle{
+		ctx:             ctx,
+		cancel:          cancel,
+		servers:         []lifecycleServer{},
+		hooks:           []func(context.Context) error{},
+		shutdownTimeout: shutdownTimeout,
+	}
+}
+
+// Services stop when the context is done
+func (self *Lifecycle) Context() context.Context {
+	return self.ctx
+}
+
+// Add a server, serve is called when the lifecycle
+// is run, e.g. server.ListenAndServe.
+func (self *Lifecycle) AddServer(server *http.Server, serve func() error) {
+	self.servers = append(self.servers, lifecycleServer{server, serve})
+}
+
+// Hooks are run after the servers are drained
+func (self *Lifecycle) OnShutdown(hook func(context.Context) error) {
+	self.hooks = append(self.hooks, hook)
+}
+
+// Run the servers until one of the signals is received
+// or a server fails, then shut down gracefully.
+func (self *Lifecycle) Run(signals ...os.Signal) error {
+	errs := make(chan error, len(self.servers))
+	for _, s := range self.servers {
+		go func(s lifecycleServer) {
+			err := s.serve()
+			if err != http.ErrServerClosed {
+				errs <- err
+			}
+		}(s)
+	}
+
+	received := make(chan os.Signal, 1)
+	if len(signals) > 0 {
+		signal.Notify(received, signals...)
+		defer signal.Stop(received)
+	}
+
+	var err error
+	select {
+	case sig := <-received:
+		log.Println("Received", sig, "- shutting down")
+	case err = <-errs:
+		log.Println("Server failed:", err, "- shutting down")
+	case <-self.ctx.Done():
+		return nil // shut down elsewhere
+	}
+
+	shutdownErr := self.Shutdown()
+	if err == nil {
+		err = shutdownErr
+	}
+	return err
+}
+
+// Stop the services, drain the servers and run
+// the hooks within the shutdown timeout.
+func (self *Lifecycle) Shutdown() error {
+	ctx, cancel := context.WithTimeout(
+		context.Background(), self.shutdownTimeout)
+	defer cancel()
+
+	// Stop background services and streams
+	self.cancel()
+
+	var err error
+	for _, s := range self.servers {
+		if shutdownErr := s.server.Shutdown(ctx); shutdownErr != nil {
+			log.Println("Could not drain connections:", shutdownErr)
+			s.server.Close()
+			err = shutdownErr
+		}
+	}
+
+	for _, hook := range self.hooks {
+		if hookErr := hook(ctx); hookErr != nil {
+			log.Println("Shutdown hook failed:", hookErr)
+			err = hookErr
+		}
+	}
+
+	return err
+}
+
+// Channel closed on shutdown, nil (never closed)
+// if there is no lifecycle.
+func lifecycleDone() <-chan struct{} {
+	if AliceLifecycle == nil {
+		return nil
+	}
+	return AliceLifecycle.Context().Done()
+}
+
+// Wait for the interval, returns false
+// if the context is done before.
+func waitInterval(ctx context.Context, interval time.Duration) bool {
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// Make a http server with the timeouts of the config
+func makeHttpServer(config ServerConfig, addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: config.ReadHeaderTimeout,
+		ReadTimeout:       config.ReadTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+		MaxHeaderBytes:    config.MaxHeaderBytes,
+	}
+}
+
+// Streamed responses are not limited by the write timeout
+func clearWriteDeadline(res http.ResponseWriter) {
+	http.NewResponseController(res).SetWriteDeadline(time.Time{})
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLifecycleShutdown(t *testing.T) {
+	lifecycle := NewLifecycle(5 * time.Second)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	started := make(chan bool)
+	finished := make(chan bool)
+	server := &http.Server{
+		Handler: http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+			started <- true
+			time.Sleep(100 * time.Millisecond)
+			res.Write([]byte("ok"))
+		}),
+	}
+	lifecycle.AddServer(server, func() error {
+		return server.Serve(listener)
+	})
+
+	flushed := false
+	lifecycle.OnShutdown(func(ctx context.Context) error {
+		flushed = true
+		return nil
+	})
+
+	run := make(chan error)
+	go func() {
+		run <- lifecycle.Run()
+	}()
+
+	// The request in flight is drained
+	go func() {
+		res, err := http.Get("http://" + listener.Addr().String())
+		if err == nil && res.StatusCode == http.StatusOK {
+			finished <- true
+		}
+		close(finished)
+	}()
+	<-started
+
+	if err := lifecycle.Shutdown(); err != nil {
+		t.Error("Unexpected error:", err)
+	}
+	if !<-finished {
+		t.Error("Expected request to be completed")
+	}
+	if !flushed {
+		t.Error("Expected shutdown hook to run")
+	}
+	if lifecycle.Context().Err() == nil {
+		t.Error("Expected context to be cancelled")
+	}
+	if err := <-run; err != nil {
+		t.Error("Unexpected error:", err)
+	}
+}
+
+func TestLifecycleServerFailed(t *testing.T) {
+	lifecycle := NewLifecycle(time.Second)
+	lifecycle.AddServer(&http.Server{}, func() error {
+		return errors.New("address already in use")
+	})
+
+	hooks := 0
+	lifecycle.OnShutdown(func(ctx context.Context) error {
+		hooks++
+		return nil
+	})
+
+	if err := lifecycle.Run(); err == nil {
+		t.Error("Expected server error")
+	}
+	if hooks != 1 {
+		t.Error("Expected shutdown hook to run once, got:", hooks)
+	}
+}
+
+func TestWaitInterval(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if !waitInterval(ctx, time.Millisecond) {
+		t.Error("Expected interval to pass")
+	}
+
+	cancel()
+	if waitInterval(ctx, time.Minute) {
+		t.Error("Expected wait to be cancelled")
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Listeners
+//
+// The api and ui are served on plain http, https and a
+// UNIX socket for local reverse proxies. The metrics and
+// admin endpoints may be moved to an internal listener.
+
+const TLS_RELOAD_INTERVAL = time.Minute
+
+// Serve the certificate of the files, the files
+// are reloaded when they are modified.
+type CertificateReloader struct {
+	certFile string
+	keyFile  string
+
+	cert     *tls.Certificate
+	modTimes []time.Time
+
+	rwlock *sync.RWMutex
+}
+
+func NewCertificateReloader(certFile, keyFile string) (*CertificateReloader, error) {
+	reloader := &CertificateReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		modTimes: []time.Time{},
+
+		rwlock: &sync.RWMutex{},
+	}
+
+	if err := reloader.update(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+func (self *CertificateReloader) Start(ctx context.Context) {
+	log.Println("Watching TLS certificate:", self.certFile)
+	go self.init(ctx)
+}
+
+func (self *CertificateReloader) init(ctx context.Context) {
+	for {
+		if !waitInterval(ctx, TLS_RELOAD_INTERVAL) {
+			return
+		}
+		if err := self.update(); err != nil {
+			log.Println("Could not reload TLS certificate:", err)
+		}
+	}
+}
+
+// Reload the certificate if a file was modified. The
+// current certificate is kept if the files are invalid.
+func (self *CertificateReloader) update() error {
+	modTimes := []time.Time{}
+	for _, filename := range []string{self.certFile, self.keyFile} {
+		info, err := os.Stat(filename)
+		if err != nil {
+			return err
+		}
+		modTimes = append(modTimes, info.ModTime())
+	}
+
+	self.rwlock.RLock()
+	modified := len(self.modTimes) != len(modTimes)
+	for i := 0; !modified && i < len(modTimes); i++ {
+		modified = !self.modTimes[i].Equal(modTimes[i])
+	}
+	self.rwlock.RUnlock()
+	if !modified {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(self.certFile, self.keyFile)
+	if err != nil {
+		return err
+	}
+
+	self.rwlock.Lock()
+	self.cert = &cert
+	self.modTimes = modTimes
+	self.rwlock.Unlock()
+
+	log.Println("Loaded TLS certificate:", self.certFile)
+	return nil
+}
+
+func (self *CertificateReloader) GetCertificate(
+	_hello *tls.ClientHelloInfo,
+) (*tls.Certificate, error) {
+	self.rwlock.RLock()
+	defer self.rwlock.RUnlock()
+	return self.cert, nil
+}
+
+// Redirect to the https listener, the port is
+// omitted if it is the default.
+func redirectHttpsHandler(listenHttps string) http.Handler {
+	_, port, _ := net.SplitHostPort(listenHttps)
+
+	return http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		host, _, err := net.SplitHostPort(req.Host)
+		if err != nil {
+			host = req.Host
+		}
+		if port != "" && port != "443" {
+			host = net.JoinHostPort(host, port)
+		}
+
+		target := "https://" + host + req.URL.RequestURI()
+		http.Redirect(res, req, target, http.StatusMovedPermanently)
+	})
+}
+
+// Listen on a UNIX socket, a stale
+// socket of a previous run is removed.
+func listenUnix(filename string) (net.Listener, error) {
+	info, err := os.Stat(filename)
+	if err == nil && info.Mode()&os.ModeSocket != 0 {
+		if err := os.Remove(filename); err != nil {
+			return nil, err
+		}
+	}
+	return net.Listen("unix", filename)
+}
+
+// Add the servers for the configured listeners to the lifecycle.
+// The internal router is only served by the internal listener.
+func setupListeners(
+	lifecycle *Lifecycle,
+	config ServerConfig,
+	router *httprouter.Router,
+	internal *httprouter.Router,
+) error {
+	if config.Listen != "" {
+		var handler http.Handler = router
+		if config.RedirectHttps {
+			handler = redirectHttpsHandler(config.ListenHttps)
+		}
+		server := makeHttpServer(config, config.Listen, handler)
+		lifecycle.AddServer(server, server.ListenAndServe)
+		log.Println("Listening on http:", config.Listen)
+	}
+
+	if config.ListenHttps != "" {
+		reloader, err := NewCertificateReloader(config.TlsCert, config.TlsKey)
+		if err != nil {
+			return err
+		}
+		reloader.Start(lifecycle.Context())
+
+		server := makeHttpServer(config, config.ListenHttps, router)
+		server.TLSConfig = &tls.Config{
+			MinVersion:     tls.VersionTLS12,
+			GetCertificate: reloader.GetCertificate,
+			NextProtos:     []string{"h2", "http/1.1"},
+		}
+		if !config.Http2 {
+			server.TLSConfig.NextProtos = []string{"http/1.1"}
+			server.TLSNextProto = make(
+				map[string]func(*http.Server, *tls.Conn, http.Handler))
+		}
+		lifecycle.AddServer(server, func() error {
+			return server.ListenAndServeTLS("", "")
+		})
+		log.Println("Listening on https:", config.ListenHttps)
+	}
+
+	if config.ListenUnix != "" {
+		listener, err := listenUnix(config.ListenUnix)
+		if err != nil {
+			return err
+		}
+		server := makeHttpServer(config, "", router)
+		lifecycle.AddServer(server, func() error {
+			return server.Serve(listener)
+		})
+		log.Println("Listening on unix socket:", config.ListenUnix)
+	}
+
+	if config.ListenInternal != "" {
+		server := makeHttpServer(config, config.ListenInternal, internal)
+		lifecycle.AddServer(server, server.ListenAndServe)
+		log.Println("Listening on internal:", config.ListenInternal)
+	}
+
+	return nil
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-ini/ini"
+)
+
+// Write a self signed certificate and key
+func writeTestCertificate(t *testing.T, certFile, keyFile, name string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ioutil.WriteFile(certFile, pem.EncodeToMemory(
+		&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(keyFile, pem.EncodeToMemory(
+		&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600)
+}
+
+func TestCertificateReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alice-tls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	if _, err := NewCertificateReloader(certFile, keyFile); err == nil {
+		t.Error("Expected error for missing certificate")
+	}
+
+	writeTestCertificate(t, certFile, keyFile, "rs1.example.net")
+	reloader, err := NewCertificateReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	commonName := func() string {
+		cert, _ := reloader.GetCertificate(nil)
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return leaf.Subject.CommonName
+	}
+	if commonName() != "rs1.example.net" {
+		t.Error("Unexpected certificate:", commonName())
+	}
+
+	// A broken key keeps the current certificate
+	later := time.Now().Add(time.Minute)
+	ioutil.WriteFile(keyFile, []byte("broken"), 0600)
+	os.Chtimes(keyFile, later, later)
+	if err := reloader.update(); err == nil {
+		t.Error("Expected error for broken key")
+	}
+	if commonName() != "rs1.example.net" {
+		t.Error("Expected certificate to be kept")
+	}
+
+	writeTestCertificate(t, certFile, keyFile, "rs2.example.net")
+	later = later.Add(time.Minute)
+	os.Chtimes(certFile, later, later)
+	os.Chtimes(keyFile, later, later)
+	if err := reloader.update(); err != nil {
+		t.Fatal(err)
+	}
+	if commonName() != "rs2.example.net" {
+		t.Error("Expected certificate to be reloaded, got:", commonName())
+	}
+}
+
+func TestRedirectHttpsHandler(t *testing.T) {
+	expected := []struct {
+		listen   string
+		location string
+	}{
+		{":443", "https://lg.example.net/api/routeservers?q=1"},
+		{"0.0.0.0:8443", "https://lg.example.net:8443/api/routeservers?q=1"},
+	}
+
+	for _, e := range expected {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://lg.example.net:8080/api/routeservers?q=1", nil)
+		redirectHttpsHandler(e.listen).ServeHTTP(res, req)
+		if res.Code != http.StatusMovedPermanently {
+			t.Error("Expected redirect, got:", res.Code)
+		}
+		if location := res.Header().Get("Location"); location != e.location {
+			t.Error("Expected location", e.location, "got:", location)
+		}
+	}
+}
+
+func TestListenUnix(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alice-socket")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "alice.sock")
+
+	// Leave a stale socket
+	stale, err := net.Listen("unix", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	stale.Close()
+
+	listener, err := listenUnix(filename)
+	if err != nil {
+		t.Fatal("Expected stale socket to be replaced:", err)
+	}
+	listener.Close()
+
+	// Other files are not removed
+	ioutil.WriteFile(filename, []byte("data"), 0600)
+	if _, err := listenUnix(filename); err == nil {
+		t.Error("Expected error for existing file")
+	}
+}
+
+func TestServerConfigListeners(t *testing.T) {
+	expected := []struct {
+		config string
+		valid  bool
+	}{
+		{"[server]\nlisten_unix = /run/alice.sock", true},
+		{"[server]\nlisten_http = :80\nlisten_https = :443", false},
+		{"[server]\nlisten_http = :80\nredirect_https = true", false},
+		{"[server]\nenable_prefix_lookup = true", false},
+		{"[server]\nlisten_http = :80\nlisten_https = :443\n" +
+			"tls_cert = cert.pem\ntls_key = key.pem\nredirect_https = true", true},
+	}
+
+	for _, e := range expected {
+		parsed, err := ini.Load([]byte(e.config))
+		if err != nil {
+			t.Fatal(err)
+		}
+		config, err := getServerConfig(parsed)
+		if (err == nil) != e.valid {
+			t.Error("Unexpected result for", e.config, "-", err)
+		}
+		if !config.Http2 {
+			t.Error("Expected http2 to be enabled by default")
+		}
+	}
+}
+
+func TestClientIpUnixSocket(t *testing.T) {
+	limits, err := NewRateLimits(RateLimitConfig{
+		Rate:           1,
+		Burst:          1,
+		ExpensiveRate:  1,
+		ExpensiveBurst: 1,
+		ProxyHeader:    "X-Forwarded-For",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("GET", "/api/routeservers", nil)
+	req.RemoteAddr = "@"
+	req.Header.Set("X-Forwarded-For", "192.0.2.23")
+	if ip := limits.ClientIp(req); ip != "192.0.2.23" {
+		t.Error("Expected forwarded address, got:", ip)
+	}
+}
@@ -1,9 +1,11 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"log"
-	"net/http"
+	"os"
+	"syscall"
 
 	"github.com/julienschmidt/httprouter"
 )
@@ -11,6 +13,17 @@ import (
 var AliceConfig *Config
 var AliceRoutesStore *RoutesStore
 var AliceNeighboursStore *NeighboursStore
+var AliceRpkiValidator *RpkiValidator
+var AliceIrrDatabase *IrrDatabase
+var AliceAsnMetadata *AsnMetadata
+var AliceBogonDetector *BogonDetector
+var AliceRedactor *Redactor
+var AliceRateLimits *RateLimits
+var AliceAccessKeys *AccessKeys
+var AliceEvents *EventBus
+var AliceResponseCache *ResponseCache
+var AliceNoexportsCache *NoexportsCache
+var AliceLifecycle *Lifecycle
 
 func main() {
 	var err error
@@ -34,19 +47,92 @@ func main() {
 
 	log.Println("Using configuration:", AliceConfig.File)
 
-	// Setup local routes store
+	// Background services stop on shutdown
+	AliceLifecycle = NewLifecycle(AliceConfig.Server.ShutdownTimeout)
+	ctx := AliceLifecycle.Context()
+
+	// Setup RPKI origin validation
+	if AliceConfig.Rpki.Enabled == true {
+		AliceRpkiValidator = NewRpkiValidator(AliceConfig.Rpki)
+		AliceRpkiValidator.Start(ctx)
+	}
+
+	// Setup IRR prefix and origin checks
+	if AliceConfig.Irr.Enabled == true {
+		AliceIrrDatabase = NewIrrDatabase(AliceConfig.Irr)
+		AliceIrrDatabase.Start(ctx)
+	}
+
+	// Setup AS names and organisations
+	if AliceConfig.AsnMetadata.Enabled == true {
+		AliceAsnMetadata = NewAsnMetadata(AliceConfig.AsnMetadata)
+		AliceAsnMetadata.Start(ctx)
+	}
+
+	// Setup bogon detection
+	if AliceConfig.Bogons.Enabled == true {
+		AliceBogonDetector, err = NewBogonDetector(AliceConfig.Bogons)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Setup redaction of api responses
+	if AliceConfig.Redaction.Enabled == true {
+		AliceRedactor, err = NewRedactor(AliceConfig.Redaction)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	// Setup api keys
+	if AliceConfig.Access.Enabled == true {
+		AliceAccessKeys = NewAccessKeys(AliceConfig.Access)
+	}
+
+	// Setup rate limiting
+	if AliceConfig.RateLimit.Enabled == true {
+		AliceRateLimits, err = NewRateLimits(
+			AliceConfig.RateLimit, AliceConfig.Access.Tiers)
+		if err != nil {
+			log.Fatal(err)
+		}
+		AliceRateLimits.Start(ctx)
+	}
+
+	// Setup events and notifications
+	AliceEvents = NewEventBus(AliceConfig.Events)
+	for _, config := range AliceConfig.Webhooks {
+		NewWebhook(config).Start(ctx, AliceEvents)
+	}
+
+	// Setup local routes and neighbours stores
 	AliceRoutesStore = NewRoutesStore(AliceConfig)
+	AliceNeighboursStore = NewNeighboursStore(AliceConfig)
+
+	snapshotFile := AliceConfig.Server.SnapshotFile
+	if AliceConfig.Server.EnablePrefixLookup == true && snapshotFile != "" {
+		if err := restoreStoreSnapshot(snapshotFile); err != nil {
+			log.Println("Could not restore store snapshot:", err)
+		}
+		AliceLifecycle.OnShutdown(func(_ctx context.Context) error {
+			return writeStoreSnapshot(snapshotFile)
+		})
+	}
 
 	if AliceConfig.Server.EnablePrefixLookup == true {
-		AliceRoutesStore.Start()
+		AliceRoutesStore.Start(ctx)
+		AliceNeighboursStore.Start(ctx)
 	}
 
-	// Setup local neighbours store
-	AliceNeighboursStore = NewNeighboursStore(AliceConfig)
+	// Cache encoded responses of the lookups
 	if AliceConfig.Server.EnablePrefixLookup == true {
-		AliceNeighboursStore.Start()
+		AliceResponseCache = NewResponseCache(RESPONSE_CACHE_MAX_ENTRIES)
 	}
 
+	// Keep the routes not exported to neighbours
+	AliceNoexportsCache = NewNoexportsCache(NOEXPORTS_CACHE_MAX_ENTRIES)
+
 	// Setup request routing
 	router := httprouter.New()
 
@@ -61,6 +147,34 @@ func main() {
 		log.Fatal(err)
 	}
 
-	// Start http server
-	log.Fatal(http.ListenAndServe(AliceConfig.Server.Listen, router))
+	// Admin endpoints and metrics may be
+	// served on a separate listener
+	internalRouter := router
+	if AliceConfig.Server.ListenInternal != "" {
+		internalRouter = httprouter.New()
+	}
+
+	err = metricsRegisterEndpoints(
+		internalRouter, AliceConfig.Server.ListenInternal == "")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = apiRegisterAdminEndpoints(internalRouter)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// Start http servers and wait for shutdown
+	err = setupListeners(
+		AliceLifecycle, AliceConfig.Server, router, internalRouter)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = AliceLifecycle.Run(syscall.SIGTERM, os.Interrupt)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Println("Shutdown complete")
 }
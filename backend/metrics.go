@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Metrics
+//
+// The state of the stores in the Prometheus text
+// format. The metrics are only served on the internal
+// listener.
+
+// Without an internal listener, the metrics are
+// served as admin endpoint on the public router.
+func metricsRegisterEndpoints(router *httprouter.Router, public bool) error {
+	if !public {
+		router.GET("/metrics", apiMetrics)
+		return nil
+	}
+	if AliceConfig.Access.Enabled == true {
+		router.GET("/metrics",
+			guard(RATE_LIMIT_DEFAULT, requireAdmin(apiMetrics)))
+	}
+	return nil
+}
+
+func apiMetrics(res http.ResponseWriter, _req *http.Request, _params httprouter.Params) {
+	res.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	writeMetrics(res)
+}
+
+func writeMetricHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+func metricTimestamp(t time.Time) float64 {
+	if t.IsZero() {
+		return 0
+	}
+	return float64(t.UnixNano()) / 1e9
+}
+
+func writeMetrics(w io.Writer) {
+	if AliceRoutesStore != nil {
+		stats := AliceRoutesStore.Stats()
+		sort.Slice(stats.RouteServers, func(i, j int) bool {
+			return stats.RouteServers[i].Name < stats.RouteServers[j].Name
+		})
+
+		writeMetricHeader(w, "alice_routes_store_routes", "gauge",
+			"Routes in the store")
+		for _, rs := range stats.RouteServers {
+			fmt.Fprintf(w, "alice_routes_store_routes{routeserver=%q,state=\"imported\"} %d\n",
+				rs.Name, rs.Routes.Imported)
+			fmt.Fprintf(w, "alice_routes_store_routes{routeserver=%q,state=\"filtered\"} %d\n",
+				rs.Name, rs.Routes.Filtered)
+		}
+
+		writeMetricHeader(w, "alice_routes_store_refreshed_timestamp_seconds", "gauge",
+			"Time of the last refresh of the routes store")
+		for _, rs := range stats.RouteServers {
+			fmt.Fprintf(w, "alice_routes_store_refreshed_timestamp_seconds{routeserver=%q,state=%q} %f\n",
+				rs.Name, rs.State, metricTimestamp(rs.UpdatedAt))
+		}
+	}
+
+	if AliceNeighboursStore != nil {
+		stats := AliceNeighboursStore.Stats()
+		sort.Slice(stats.RouteServers, func(i, j int) bool {
+			return stats.RouteServers[i].Name < stats.RouteServers[j].Name
+		})
+
+		writeMetricHeader(w, "alice_neighbours_store_neighbours", "gauge",
+			"Neighbours in the store")
+		for _, rs := range stats.RouteServers {
+			fmt.Fprintf(w, "alice_neighbours_store_neighbours{routeserver=%q} %d\n",
+				rs.Name, rs.Neighbours)
+		}
+
+		writeMetricHeader(w, "alice_neighbours_store_refreshed_timestamp_seconds", "gauge",
+			"Time of the last refresh of the neighbours store")
+		for _, rs := range stats.RouteServers {
+			fmt.Fprintf(w, "alice_neighbours_store_refreshed_timestamp_seconds{routeserver=%q,state=%q} %f\n",
+				rs.Name, rs.State, metricTimestamp(rs.UpdatedAt))
+		}
+
+		writeMetricHeader(w, "alice_neighbours_flapping", "gauge",
+			"Neighbours with frequent state changes")
+		fmt.Fprintf(w, "alice_neighbours_flapping %d\n", len(stats.FlappingNeighbours))
+	}
+
+	if AliceEvents != nil {
+		writeMetricHeader(w, "alice_events_dropped_total", "counter",
+			"Events dropped for slow subscribers")
+		fmt.Fprintf(w, "alice_events_dropped_total %d\n", AliceEvents.Dropped())
+	}
+
+	if AliceResponseCache != nil {
+		writeMetricHeader(w, "alice_response_cache_entries", "gauge",
+			"Encoded responses in the cache")
+		fmt.Fprintf(w, "alice_response_cache_entries %d\n", AliceResponseCache.Len())
+	}
+}
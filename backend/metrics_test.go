@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestWriteMetrics(t *testing.T) {
+	AliceRoutesStore = makeRoutesExportTestStore()
+	AliceResponseCache = NewResponseCache(RESPONSE_CACHE_MAX_ENTRIES)
+	defer func() {
+		AliceRoutesStore = nil
+		AliceResponseCache = nil
+		AliceConfig = nil
+	}()
+
+	buf := &bytes.Buffer{}
+	writeMetrics(buf)
+	metrics := buf.String()
+
+	expected := []string{
+		"# TYPE alice_routes_store_routes gauge\n",
+		`alice_routes_store_routes{routeserver="rs0.example.net",state="imported"} 2` + "\n",
+		`alice_routes_store_routes{routeserver="rs0.example.net",state="filtered"} 1` + "\n",
+		"alice_response_cache_entries 0\n",
+	}
+	for _, e := range expected {
+		if !strings.Contains(metrics, e) {
+			t.Error("Expected metric:", e, "in:", metrics)
+		}
+	}
+
+	if strings.Contains(metrics, "alice_neighbours_store") {
+		t.Error("Unexpected neighbours store metrics")
+	}
+}
+
+func TestMetricsPublicEndpoint(t *testing.T) {
+	AliceConfig = &Config{}
+	defer func() {
+		AliceConfig = nil
+		AliceAccessKeys = nil
+	}()
+
+	// Without api keys, the metrics are not public
+	router := httprouter.New()
+	metricsRegisterEndpoints(router, true)
+	if handle, _, _ := router.Lookup("GET", "/metrics"); handle != nil {
+		t.Error("Expected metrics not to be registered without access control")
+	}
+
+	AliceConfig.Access = makeAccessConfig()
+	AliceAccessKeys = NewAccessKeys(AliceConfig.Access)
+	router = httprouter.New()
+	metricsRegisterEndpoints(router, true)
+
+	expected := []struct {
+		key    string
+		status int
+	}{
+		{"", http.StatusUnauthorized},
+		{"partner-key", http.StatusForbidden},
+		{"staff-key", http.StatusOK},
+	}
+	for _, e := range expected {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		if e.key != "" {
+			req.Header.Set(API_KEY_HEADER, e.key)
+		}
+		router.ServeHTTP(res, req)
+		if res.Code != e.status {
+			t.Error("Expected status", e.status, "for key", e.key, "got:", res.Code)
+		}
+	}
+}
@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+// MRT TABLE_DUMP_V2 (RFC 6396)
+//
+// The routes are written as RIB entries grouped by prefix,
+// preceded by a peer index table with the neighbours.
+// Extended communities are not included.
+
+const (
+	MRT_TYPE_TABLE_DUMP_V2 = 13
+
+	MRT_SUBTYPE_PEER_INDEX_TABLE = 1
+	MRT_SUBTYPE_RIB_IPV4_UNICAST = 2
+	MRT_SUBTYPE_RIB_IPV6_UNICAST = 4
+)
+
+const (
+	BGP_ATTR_FLAG_OPTIONAL   = 0x80
+	BGP_ATTR_FLAG_TRANSITIVE = 0x40
+	BGP_ATTR_FLAG_EXTENDED   = 0x10
+
+	BGP_ATTR_ORIGIN          = 1
+	BGP_ATTR_AS_PATH         = 2
+	BGP_ATTR_NEXT_HOP        = 3
+	BGP_ATTR_MED             = 4
+	BGP_ATTR_LOCAL_PREF      = 5
+	BGP_ATTR_COMMUNITIES     = 8
+	BGP_ATTR_MP_REACH_NLRI   = 14
+	BGP_ATTR_LARGE_COMMUNITY = 32
+
+	BGP_AS_SEQUENCE = 2
+)
+
+// Peers are referenced by a 2 byte index
+const MRT_MAX_PEERS = 0xffff
+
+// A peer of the peer index table
+type MrtPeer struct {
+	Address net.IP
+	Asn     int
+}
+
+type MrtWriter struct {
+	w         io.Writer
+	timestamp time.Time
+	sequence  uint32
+
+	peers     []MrtPeer
+	peerIndex map[string]int // by neighbour id
+}
+
+func NewMrtWriter(w io.Writer, timestamp time.Time) *MrtWriter {
+	return &MrtWriter{
+		w:         w,
+		timestamp: timestamp,
+		peers:     []MrtPeer{},
+		peerIndex: make(map[string]int),
+	}
+}
+
+// Register the neighbour of a route as peer
+func (self *MrtWriter) AddPeer(neighbourId string, peer MrtPeer) {
+	if _, ok := self.peerIndex[neighbourId]; ok {
+		return
+	}
+	self.peerIndex[neighbourId] = len(self.peers)
+	self.peers = append(self.peers, peer)
+}
+
+func (self *MrtWriter) writeRecord(subtype uint16, data []byte) error {
+	header := make([]byte, 12)
+	binary.BigEndian.PutUint32(header[0:], uint32(self.timestamp.Unix()))
+	binary.BigEndian.PutUint16(header[4:], MRT_TYPE_TABLE_DUMP_V2)
+	binary.BigEndian.PutUint16(header[6:], subtype)
+	binary.BigEndian.PutUint32(header[8:], uint32(len(data)))
+
+	if _, err := self.w.Write(header); err != nil {
+		return err
+	}
+	_, err := self.w.Write(data)
+	return err
+}
+
+// Write the peer index table, this must
+// precede the RIB entries.
+func (self *MrtWriter) WritePeerIndexTable(viewName string) error {
+	if len(self.peers) > MRT_MAX_PEERS {
+		return fmt.Errorf("Too many peers for the peer index table: %d",
+			len(self.peers))
+	}
+
+	buf := &bytes.Buffer{}
+
+	buf.Write(net.IPv4zero.To4()) // collector bgp id
+	binary.Write(buf, binary.BigEndian, uint16(len(viewName)))
+	buf.WriteString(viewName)
+	binary.Write(buf, binary.BigEndian, uint16(len(self.peers)))
+
+	for _, peer := range self.peers {
+		// All ASNs are written as 4 byte ASNs
+		peerType := byte(0x02)
+		address := peer.Address.To4()
+		bgpId := address
+		if address == nil {
+			peerType |= 0x01
+			address = peer.Address.To16()
+			bgpId = net.IPv4zero.To4()
+		}
+		if address == nil {
+			address = net.IPv4zero.To4()
+			bgpId = address
+			peerType = 0x02
+		}
+
+		buf.WriteByte(peerType)
+		buf.Write(bgpId)
+		buf.Write(address)
+		binary.Write(buf, binary.BigEndian, uint32(peer.Asn))
+	}
+
+	return self.writeRecord(MRT_SUBTYPE_PEER_INDEX_TABLE, buf.Bytes())
+}
+
+func mrtAttribute(buf *bytes.Buffer, flags byte, code byte, value []byte) {
+	if len(value) > 255 {
+		flags |= BGP_ATTR_FLAG_EXTENDED
+	}
+	buf.WriteByte(flags)
+	buf.WriteByte(code)
+	if flags&BGP_ATTR_FLAG_EXTENDED != 0 {
+		binary.Write(buf, binary.BigEndian, uint16(len(value)))
+	} else {
+		buf.WriteByte(byte(len(value)))
+	}
+	buf.Write(value)
+}
+
+func mrtOrigin(origin string) byte {
+	switch strings.ToUpper(origin) {
+	case "IGP":
+		return 0
+	case "EGP":
+		return 1
+	}
+	return 2 // incomplete
+}
+
+// Encode the path attributes of a route
+func mrtAttributes(route api.Route, ipv6 bool) []byte {
+	buf := &bytes.Buffer{}
+	bgp := route.Bgp
+
+	mrtAttribute(buf, BGP_ATTR_FLAG_TRANSITIVE, BGP_ATTR_ORIGIN,
+		[]byte{mrtOrigin(bgp.Origin)})
+
+	// AS path: Segments have at most 255 ASNs
+	path := &bytes.Buffer{}
+	for i := 0; i < len(bgp.AsPath); i += 255 {
+		end := i + 255
+		if end > len(bgp.AsPath) {
+			end = len(bgp.AsPath)
+		}
+		path.WriteByte(BGP_AS_SEQUENCE)
+		path.WriteByte(byte(end - i))
+		for _, asn := range bgp.AsPath[i:end] {
+			binary.Write(path, binary.BigEndian, uint32(asn))
+		}
+	}
+	mrtAttribute(buf, BGP_ATTR_FLAG_TRANSITIVE, BGP_ATTR_AS_PATH, path.Bytes())
+
+	nextHop := net.ParseIP(bgp.NextHop)
+	if nextHop == nil {
+		nextHop = net.ParseIP(route.Gateway)
+	}
+	if ipv6 {
+		// Only the next hop is included (RFC 6396, 4.3.4)
+		if nextHop != nil && nextHop.To4() == nil {
+			value := append([]byte{16}, nextHop.To16()...)
+			mrtAttribute(buf, BGP_ATTR_FLAG_OPTIONAL, BGP_ATTR_MP_REACH_NLRI, value)
+		}
+	} else if nextHop != nil && nextHop.To4() != nil {
+		mrtAttribute(buf, BGP_ATTR_FLAG_TRANSITIVE, BGP_ATTR_NEXT_HOP, nextHop.To4())
+	}
+
+	value := make([]byte, 4)
+	binary.BigEndian.PutUint32(value, uint32(bgp.Med))
+	mrtAttribute(buf, BGP_ATTR_FLAG_OPTIONAL, BGP_ATTR_MED, value)
+
+	value = make([]byte, 4)
+	binary.BigEndian.PutUint32(value, uint32(bgp.LocalPref))
+	mrtAttribute(buf, BGP_ATTR_FLAG_TRANSITIVE, BGP_ATTR_LOCAL_PREF, value)
+
+	if len(bgp.Communities) > 0 {
+		value := &bytes.Buffer{}
+		for _, community := range bgp.Communities {
+			if len(community) != 2 {
+				continue
+			}
+			binary.Write(value, binary.BigEndian, uint16(community[0]))
+			binary.Write(value, binary.BigEndian, uint16(community[1]))
+		}
+		mrtAttribute(buf, BGP_ATTR_FLAG_OPTIONAL|BGP_ATTR_FLAG_TRANSITIVE,
+			BGP_ATTR_COMMUNITIES, value.Bytes())
+	}
+
+	if len(bgp.LargeCommunities) > 0 {
+		value := &bytes.Buffer{}
+		for _, community := range bgp.LargeCommunities {
+			if len(community) != 3 {
+				continue
+			}
+			for _, part := range community {
+				binary.Write(value, binary.BigEndian, uint32(part))
+			}
+		}
+		mrtAttribute(buf, BGP_ATTR_FLAG_OPTIONAL|BGP_ATTR_FLAG_TRANSITIVE,
+			BGP_ATTR_LARGE_COMMUNITY, value.Bytes())
+	}
+
+	return buf.Bytes()
+}
+
+// Write a RIB entry with all routes of a prefix. Routes
+// of neighbours not in the peer index table are skipped.
+func (self *MrtWriter) WriteRib(prefix string, routes []api.Route) error {
+	_, network, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return nil // not a prefix, skip
+	}
+
+	subtype := uint16(MRT_SUBTYPE_RIB_IPV4_UNICAST)
+	address := network.IP.To4()
+	ipv6 := address == nil
+	if ipv6 {
+		subtype = MRT_SUBTYPE_RIB_IPV6_UNICAST
+		address = network.IP.To16()
+	}
+	length, _ := network.Mask.Size()
+
+	entries := &bytes.Buffer{}
+	count := 0
+	for _, route := range routes {
+		peer, ok := self.peerIndex[route.NeighbourId]
+		if !ok {
+			continue
+		}
+		originated := self.timestamp.Add(-route.Age)
+		attributes := mrtAttributes(route, ipv6)
+
+		binary.Write(entries, binary.BigEndian, uint16(peer))
+		binary.Write(entries, binary.BigEndian, uint32(originated.Unix()))
+		binary.Write(entries, binary.BigEndian, uint16(len(attributes)))
+		entries.Write(attributes)
+		count++
+	}
+	if count == 0 {
+		return nil
+	}
+
+	buf := &bytes.Buffer{}
+	binary.Write(buf, binary.BigEndian, self.sequence)
+	buf.WriteByte(byte(length))
+	buf.Write(address[:(length+7)/8])
+	binary.Write(buf, binary.BigEndian, uint16(count))
+	buf.Write(entries.Bytes())
+
+	self.sequence++
+	return self.writeRecord(subtype, buf.Bytes())
+}
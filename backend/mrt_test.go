@@ -0,0 +1,176 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+type mrtTestRecord struct {
+	Timestamp uint32
+	Type      uint16
+	Subtype   uint16
+	Data      []byte
+}
+
+func readMrtTestRecords(t *testing.T, data []byte) []mrtTestRecord {
+	records := []mrtTestRecord{}
+	for len(data) > 0 {
+		if len(data) < 12 {
+			t.Fatal("Truncated header")
+		}
+		length := binary.BigEndian.Uint32(data[8:])
+		if len(data) < 12+int(length) {
+			t.Fatal("Truncated record")
+		}
+		records = append(records, mrtTestRecord{
+			Timestamp: binary.BigEndian.Uint32(data[0:]),
+			Type:      binary.BigEndian.Uint16(data[4:]),
+			Subtype:   binary.BigEndian.Uint16(data[6:]),
+			Data:      data[12 : 12+length],
+		})
+		data = data[12+length:]
+	}
+	return records
+}
+
+// Get the path attributes of the first RIB entry
+func readMrtTestAttributes(t *testing.T, data []byte) map[byte][]byte {
+	attributes := make(map[byte][]byte)
+	for len(data) > 0 {
+		flags, code := data[0], data[1]
+		length, offset := int(data[2]), 3
+		if flags&BGP_ATTR_FLAG_EXTENDED != 0 {
+			length, offset = int(binary.BigEndian.Uint16(data[2:])), 4
+		}
+		attributes[code] = data[offset : offset+length]
+		data = data[offset+length:]
+	}
+	return attributes
+}
+
+func TestMrtWriter(t *testing.T) {
+	timestamp := time.Unix(1500000000, 0)
+	buf := &bytes.Buffer{}
+	mrt := NewMrtWriter(buf, timestamp)
+
+	mrt.AddPeer("ID1_AS2342", MrtPeer{net.ParseIP("10.0.0.1"), 2342})
+	mrt.AddPeer("ID2_AS4200000000", MrtPeer{net.ParseIP("2001:db8::2"), 4200000000})
+	mrt.AddPeer("ID1_AS2342", MrtPeer{net.ParseIP("10.0.0.3"), 1})
+
+	if err := mrt.WritePeerIndexTable("rs1"); err != nil {
+		t.Fatal(err)
+	}
+
+	route := api.Route{
+		NeighbourId: "ID1_AS2342",
+		Network:     "10.23.0.0/16",
+		Age:         time.Minute,
+		Bgp: api.BgpInfo{
+			Origin:           "IGP",
+			AsPath:           []int{2342, 23},
+			NextHop:          "10.0.0.1",
+			Communities:      []api.Community{{2342, 1}},
+			LargeCommunities: []api.Community{{2342, 1, 2}},
+		},
+	}
+	unknown := route
+	unknown.NeighbourId = "ID3_AS1"
+
+	mrt.WriteRib("10.23.0.0/16", []api.Route{route, unknown})
+	mrt.WriteRib("2001:db8:23::/48", []api.Route{api.Route{
+		NeighbourId: "ID2_AS4200000000",
+		Bgp: api.BgpInfo{
+			AsPath:  []int{4200000000},
+			NextHop: "2001:db8::2",
+		},
+	}})
+
+	records := readMrtTestRecords(t, buf.Bytes())
+	if len(records) != 3 {
+		t.Fatal("Expected 3 records, got:", len(records))
+	}
+	for _, record := range records {
+		if record.Type != MRT_TYPE_TABLE_DUMP_V2 || record.Timestamp != 1500000000 {
+			t.Error("Unexpected record header:", record)
+		}
+	}
+
+	// Peer index table: collector id, view name, peers
+	peers := records[0].Data
+	if records[0].Subtype != MRT_SUBTYPE_PEER_INDEX_TABLE {
+		t.Error("Expected peer index table first")
+	}
+	if string(peers[6:9]) != "rs1" || binary.BigEndian.Uint16(peers[9:]) != 2 {
+		t.Error("Unexpected peer index table:", peers)
+	}
+	// Second peer: IPv6 with 4 byte ASN
+	second := peers[11+1+4+4+4:]
+	if second[0] != 0x03 || binary.BigEndian.Uint32(second[1+4+16:]) != 4200000000 {
+		t.Error("Unexpected IPv6 peer:", second)
+	}
+
+	// RIB: sequence, prefix, entries
+	rib := records[1].Data
+	if records[1].Subtype != MRT_SUBTYPE_RIB_IPV4_UNICAST {
+		t.Error("Expected IPv4 RIB entry")
+	}
+	if rib[4] != 16 || !bytes.Equal(rib[5:7], []byte{10, 23}) {
+		t.Error("Unexpected prefix:", rib[4:7])
+	}
+	if count := binary.BigEndian.Uint16(rib[7:]); count != 1 {
+		t.Error("Expected routes of unknown peers to be skipped, got:", count)
+	}
+	entry := rib[9:]
+	if originated := binary.BigEndian.Uint32(entry[2:]); originated != 1500000000-60 {
+		t.Error("Unexpected originated time:", originated)
+	}
+	attrLen := binary.BigEndian.Uint16(entry[6:])
+	attributes := readMrtTestAttributes(t, entry[8:8+attrLen])
+
+	asPath := attributes[BGP_ATTR_AS_PATH]
+	if asPath[0] != BGP_AS_SEQUENCE || asPath[1] != 2 ||
+		binary.BigEndian.Uint32(asPath[6:]) != 23 {
+		t.Error("Unexpected as path:", asPath)
+	}
+	if !bytes.Equal(attributes[BGP_ATTR_NEXT_HOP], []byte{10, 0, 0, 1}) {
+		t.Error("Unexpected next hop:", attributes[BGP_ATTR_NEXT_HOP])
+	}
+	if len(attributes[BGP_ATTR_COMMUNITIES]) != 4 ||
+		len(attributes[BGP_ATTR_LARGE_COMMUNITY]) != 12 {
+		t.Error("Unexpected communities:", attributes)
+	}
+
+	// IPv6 next hop in MP_REACH_NLRI
+	if records[2].Subtype != MRT_SUBTYPE_RIB_IPV6_UNICAST {
+		t.Error("Expected IPv6 RIB entry")
+	}
+	rib = records[2].Data
+	if binary.BigEndian.Uint32(rib[0:]) != 1 || rib[4] != 48 {
+		t.Error("Unexpected sequence or prefix length:", rib[:5])
+	}
+	entry = rib[5+6+2:]
+	attrLen = binary.BigEndian.Uint16(entry[6:])
+	attributes = readMrtTestAttributes(t, entry[8:8+attrLen])
+	if reach := attributes[BGP_ATTR_MP_REACH_NLRI]; len(reach) != 17 || reach[0] != 16 {
+		t.Error("Unexpected MP_REACH_NLRI:", reach)
+	}
+	if _, ok := attributes[BGP_ATTR_NEXT_HOP]; ok {
+		t.Error("Unexpected NEXT_HOP for IPv6 route")
+	}
+}
+
+func TestMrtWriterTooManyPeers(t *testing.T) {
+	mrt := NewMrtWriter(&bytes.Buffer{}, time.Now())
+	for i := 0; i <= MRT_MAX_PEERS; i++ {
+		mrt.AddPeer(fmt.Sprintf("ID%d", i), MrtPeer{net.IPv4zero, i})
+	}
+	if err := mrt.WritePeerIndexTable("rs1"); err == nil {
+		t.Error("Expected error for more peers than can be indexed")
+	}
+}
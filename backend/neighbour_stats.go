@@ -0,0 +1,92 @@
+package main
+
+import (
+	"net"
+	"strconv"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+// Neighbour routes statistics
+//
+// Break down the routes of a single neighbour by reject
+// reason, prefix length and origin. RPKI and IRR summaries
+// are only included if the routes were annotated.
+
+const REJECT_REASON_UNKNOWN = "unknown"
+
+// Get the reject reason id of a filtered route
+func routeRejectReason(route api.Route, rejection RejectionsConfig) string {
+	id, ok := largeCommunityReason(route, rejection.Asn, rejection.RejectId)
+	if !ok {
+		return REJECT_REASON_UNKNOWN
+	}
+	return strconv.Itoa(id)
+}
+
+func makeNeighbourRoutesStats(
+	routes api.RoutesResponse,
+	rejection RejectionsConfig,
+) api.NeighbourRoutesStats {
+	stats := api.NeighbourRoutesStats{
+		Imported:    len(routes.Imported),
+		Filtered:    len(routes.Filtered),
+		NotExported: len(routes.NotExported),
+
+		FilteredByReason:  make(map[string]int),
+		PrefixLengthsIpv4: make(map[int]int),
+		PrefixLengthsIpv6: make(map[int]int),
+		OriginAsns:        make(map[int]int),
+	}
+
+	rpki := make(map[string]int)
+	irr := api.IrrSummary{}
+
+	for _, route := range routes.Filtered {
+		stats.FilteredByReason[routeRejectReason(route, rejection)] += 1
+	}
+
+	received := []api.Route{}
+	received = append(received, routes.Imported...)
+	received = append(received, routes.Filtered...)
+
+	for _, route := range received {
+		ip, network, err := net.ParseCIDR(route.Network)
+		if err == nil {
+			length, _ := network.Mask.Size()
+			if ip.To4() != nil {
+				stats.PrefixLengthsIpv4[length] += 1
+			} else {
+				stats.PrefixLengthsIpv6[length] += 1
+			}
+		}
+
+		if len(route.Bgp.AsPath) > 0 {
+			origin := route.Bgp.AsPath[len(route.Bgp.AsPath)-1]
+			stats.OriginAsns[origin] += 1
+		}
+
+		if route.Rpki != "" {
+			rpki[route.Rpki] += 1
+		}
+
+		if route.Irr != nil {
+			irr.Checked += 1
+			if route.Irr.OriginInAsSet {
+				irr.OriginInAsSet += 1
+			}
+			if route.Irr.RouteObject {
+				irr.RouteObject += 1
+			}
+		}
+	}
+
+	if len(rpki) > 0 {
+		stats.Rpki = rpki
+	}
+	if irr.Checked > 0 {
+		stats.Irr = &irr
+	}
+
+	return stats
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+func TestNeighbourRoutesStats(t *testing.T) {
+	rejection := RejectionsConfig{
+		Asn:      9033,
+		RejectId: 65666,
+	}
+
+	routes := api.RoutesResponse{
+		Imported: []api.Route{
+			{
+				Network: "10.23.0.0/16",
+				Bgp:     api.BgpInfo{AsPath: []int{2342, 4224}},
+				Rpki:    api.RPKI_VALID,
+				Irr:     &api.IrrCheck{OriginInAsSet: true, RouteObject: true},
+			},
+			{
+				Network: "2001:db8::/32",
+				Bgp:     api.BgpInfo{AsPath: []int{2342}},
+				Rpki:    api.RPKI_NOT_FOUND,
+				Irr:     &api.IrrCheck{OriginInAsSet: true},
+			},
+		},
+		Filtered: []api.Route{
+			{
+				Network: "10.42.23.0/24",
+				Bgp: api.BgpInfo{
+					AsPath:           []int{2342, 4224},
+					LargeCommunities: []api.Community{{9033, 65666, 9}},
+				},
+				Rpki: api.RPKI_INVALID,
+			},
+			{
+				Network: "10.42.24.0/24",
+				Bgp:     api.BgpInfo{AsPath: []int{2342}},
+			},
+		},
+	}
+
+	stats := makeNeighbourRoutesStats(routes, rejection)
+
+	if stats.Imported != 2 || stats.Filtered != 2 {
+		t.Error("Unexpected route counts:", stats)
+	}
+	if stats.FilteredByReason["9"] != 1 ||
+		stats.FilteredByReason[REJECT_REASON_UNKNOWN] != 1 {
+		t.Error("Unexpected reject reasons:", stats.FilteredByReason)
+	}
+	if stats.PrefixLengthsIpv4[24] != 2 || stats.PrefixLengthsIpv4[16] != 1 {
+		t.Error("Unexpected IPv4 prefix lengths:", stats.PrefixLengthsIpv4)
+	}
+	if stats.PrefixLengthsIpv6[32] != 1 {
+		t.Error("Unexpected IPv6 prefix lengths:", stats.PrefixLengthsIpv6)
+	}
+	if stats.OriginAsns[4224] != 2 || stats.OriginAsns[2342] != 2 {
+		t.Error("Unexpected origin ASNs:", stats.OriginAsns)
+	}
+	if stats.Rpki[api.RPKI_INVALID] != 1 || stats.Rpki[api.RPKI_VALID] != 1 {
+		t.Error("Unexpected RPKI summary:", stats.Rpki)
+	}
+	if stats.Irr == nil || stats.Irr.Checked != 2 || stats.Irr.RouteObject != 1 {
+		t.Error("Unexpected IRR summary:", stats.Irr)
+	}
+
+	// The input must not be modified
+	if len(routes.Imported) != 2 {
+		t.Error("Imported routes were modified")
+	}
+}
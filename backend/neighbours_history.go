@@ -0,0 +1,119 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+// Neighbour state history
+//
+// The neighbours store records state transitions of every
+// neighbour. Flapping is detected using a penalty, which
+// decays exponentially like in BGP route flap dampening.
+
+const NEIGHBOUR_HISTORY_SIZE = 100
+
+const NEIGHBOUR_FLAP_PENALTY = 1000.0
+const NEIGHBOUR_FLAP_HALF_LIFE = 15 * time.Minute
+const NEIGHBOUR_FLAP_THRESHOLD = 1500.0 // two flaps within one half life
+
+type NeighbourHistory struct {
+	Events    []api.NeighbourStateChange
+	FlapCount int
+
+	state  string
+	seenAt time.Time
+
+	flapScore   float64
+	flapScoreAt time.Time
+}
+
+// Map neighbour ids to their history
+type NeighboursHistoryIndex map[string]*NeighbourHistory
+
+func isStateUp(state string) bool {
+	return strings.EqualFold(state, "up")
+}
+
+// Record the current state of a neighbour.
+//
+// The store is polled, so a session may have been reset
+// between two updates without us seeing a different state.
+// This is detected by the uptime being shorter than the
+// time since the last update and recorded as a transition
+// from up to up.
+func (self *NeighbourHistory) Record(neighbour api.Neighbour, now time.Time) {
+	// First observation
+	if self.seenAt.IsZero() {
+		self.state = neighbour.State
+		self.seenAt = now
+		return
+	}
+
+	elapsed := now.Sub(self.seenAt)
+	changed := !strings.EqualFold(self.state, neighbour.State)
+	restarted := !changed &&
+		isStateUp(neighbour.State) &&
+		neighbour.Uptime < elapsed
+
+	if changed || restarted {
+		// Estimate the time of the transition from the uptime
+		timestamp := now
+		if neighbour.Uptime > 0 && neighbour.Uptime < elapsed {
+			timestamp = now.Add(-neighbour.Uptime)
+		}
+
+		self.Events = append(self.Events, api.NeighbourStateChange{
+			Timestamp:     timestamp,
+			PreviousState: self.state,
+			State:         neighbour.State,
+		})
+
+		// Keep the log bounded
+		if len(self.Events) > NEIGHBOUR_HISTORY_SIZE {
+			self.Events = self.Events[len(self.Events)-NEIGHBOUR_HISTORY_SIZE:]
+		}
+
+		// Leaving the established state is a flap
+		if isStateUp(self.state) {
+			self.FlapCount += 1
+			self.flapScore = self.FlapScore(now) + NEIGHBOUR_FLAP_PENALTY
+			self.flapScoreAt = now
+		}
+	}
+
+	self.state = neighbour.State
+	self.seenAt = now
+}
+
+// Get the decayed flap score
+func (self *NeighbourHistory) FlapScore(now time.Time) float64 {
+	if self.flapScore == 0 {
+		return 0
+	}
+	halfLifes := float64(now.Sub(self.flapScoreAt)) / float64(NEIGHBOUR_FLAP_HALF_LIFE)
+	return self.flapScore * math.Pow(0.5, halfLifes)
+}
+
+// Check if the neighbour is currently flapping
+func (self *NeighbourHistory) IsFlapping(now time.Time) bool {
+	return self.FlapScore(now) >= NEIGHBOUR_FLAP_THRESHOLD
+}
+
+// Make history response
+func (self *NeighbourHistory) Response(neighbourId string, now time.Time) api.NeighbourHistoryResponse {
+	events := make([]api.NeighbourStateChange, len(self.Events))
+	copy(events, self.Events)
+
+	return api.NeighbourHistoryResponse{
+		NeighbourId: neighbourId,
+		State:       self.state,
+		Events:      events,
+		FlapCount:   self.FlapCount,
+		FlapScore:   self.FlapScore(now),
+		Flapping:    self.IsFlapping(now),
+	}
+}
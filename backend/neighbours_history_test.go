@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+func TestNeighbourHistoryRecord(t *testing.T) {
+	history := &NeighbourHistory{}
+	t0 := time.Date(2017, 5, 22, 8, 0, 0, 0, time.UTC)
+
+	neighbour := api.Neighbour{State: "up", Uptime: 24 * time.Hour}
+
+	// Initial state is not an event
+	history.Record(neighbour, t0)
+	if len(history.Events) != 0 {
+		t.Error("Expected no events after first observation")
+	}
+
+	// Unchanged
+	neighbour.Uptime += 5 * time.Minute
+	history.Record(neighbour, t0.Add(5*time.Minute))
+	if len(history.Events) != 0 {
+		t.Error("Expected no events for unchanged state")
+	}
+
+	// Session goes down
+	neighbour = api.Neighbour{State: "start", Uptime: 2 * time.Minute}
+	history.Record(neighbour, t0.Add(10*time.Minute))
+	if len(history.Events) != 1 {
+		t.Fatal("Expected a state change event")
+	}
+	event := history.Events[0]
+	if event.PreviousState != "up" || event.State != "start" {
+		t.Error("Unexpected event:", event)
+	}
+	if !event.Timestamp.Equal(t0.Add(8 * time.Minute)) {
+		t.Error("Expected timestamp to be estimated from uptime:", event.Timestamp)
+	}
+	if history.FlapCount != 1 {
+		t.Error("Expected 1 flap, got:", history.FlapCount)
+	}
+
+	// Back up: Not a flap
+	neighbour = api.Neighbour{State: "up", Uptime: 1 * time.Minute}
+	history.Record(neighbour, t0.Add(15*time.Minute))
+	if len(history.Events) != 2 || history.FlapCount != 1 {
+		t.Error("Expected 2 events and 1 flap:", history.Events, history.FlapCount)
+	}
+
+	// Session was reset between two updates
+	neighbour = api.Neighbour{State: "up", Uptime: 1 * time.Minute}
+	history.Record(neighbour, t0.Add(20*time.Minute))
+	if len(history.Events) != 3 || history.FlapCount != 2 {
+		t.Error("Expected reset to be recorded as flap:", history.Events)
+	}
+
+	if !history.IsFlapping(t0.Add(20 * time.Minute)) {
+		t.Error("Expected neighbour to be flapping")
+	}
+	if history.IsFlapping(t0.Add(2 * time.Hour)) {
+		t.Error("Expected flap score to decay")
+	}
+}
+
+func TestNeighbourHistorySize(t *testing.T) {
+	history := &NeighbourHistory{}
+	t0 := time.Date(2017, 5, 22, 8, 0, 0, 0, time.UTC)
+
+	states := []string{"up", "down"}
+	for i := 0; i < NEIGHBOUR_HISTORY_SIZE+23; i++ {
+		neighbour := api.Neighbour{State: states[i%2]}
+		history.Record(neighbour, t0.Add(time.Duration(i)*time.Minute))
+	}
+
+	if len(history.Events) != NEIGHBOUR_HISTORY_SIZE {
+		t.Error("Expected history to be bounded, got:", len(history.Events))
+	}
+}
@@ -1,7 +1,11 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
@@ -10,10 +14,73 @@ import (
 
 type NeighboursIndex map[string]api.Neighbour
 
+// Structured neighbours query, unset fields
+// are not used for filtering
+type NeighboursQuery struct {
+	Asn         int
+	Address     string
+	Network     *net.IPNet
+	State       string
+	Description string
+	Group       string
+
+	MinRoutesReceived int
+	MaxRoutesReceived int // -1: no limit
+
+	// Percentage of the import limit in use,
+	// neighbours without a limit never match.
+	MinImportLimitUsage int // 0: any
+}
+
+// Check if a neighbour matches the query
+func (query NeighboursQuery) Match(neighbour api.Neighbour) bool {
+	if query.Asn != 0 && neighbour.Asn != query.Asn {
+		return false
+	}
+
+	if query.Network != nil {
+		ip := net.ParseIP(neighbour.Address)
+		if ip == nil || !query.Network.Contains(ip) {
+			return false
+		}
+	} else if query.Address != "" {
+		ip := net.ParseIP(neighbour.Address)
+		if ip == nil || !ip.Equal(net.ParseIP(query.Address)) {
+			return false
+		}
+	}
+
+	if query.State != "" && !strings.EqualFold(neighbour.State, query.State) {
+		return false
+	}
+
+	if query.Description != "" && !ContainsCi(neighbour.Description, query.Description) {
+		return false
+	}
+
+	if neighbour.RoutesReceived < query.MinRoutesReceived {
+		return false
+	}
+	if query.MaxRoutesReceived >= 0 && neighbour.RoutesReceived > query.MaxRoutesReceived {
+		return false
+	}
+
+	if query.MinImportLimitUsage > 0 {
+		if neighbour.ImportLimit == 0 ||
+			neighbour.ImportLimitUsage < float64(query.MinImportLimitUsage) {
+			return false
+		}
+	}
+
+	return true
+}
+
 type NeighboursStore struct {
 	neighboursMap map[int]NeighboursIndex
+	historyMap    map[int]NeighboursHistoryIndex
 	configMap     map[int]SourceConfig
 	statusMap     map[int]StoreStatus
+	refreshMap    map[int]time.Time // last successful refresh
 
 	rwlock *sync.RWMutex
 }
@@ -22,6 +89,7 @@ func NewNeighboursStore(config *Config) *NeighboursStore {
 
 	// Build source mapping
 	neighboursMap := make(map[int]NeighboursIndex)
+	historyMap := make(map[int]NeighboursHistoryIndex)
 	configMap := make(map[int]SourceConfig)
 	statusMap := make(map[int]StoreStatus)
 
@@ -33,38 +101,43 @@ func NewNeighboursStore(config *Config) *NeighboursStore {
 		}
 
 		neighboursMap[sourceId] = make(NeighboursIndex)
+		historyMap[sourceId] = make(NeighboursHistoryIndex)
 	}
 
 	store := &NeighboursStore{
 		neighboursMap: neighboursMap,
+		historyMap:    historyMap,
 		statusMap:     statusMap,
 		configMap:     configMap,
+		refreshMap:    make(map[int]time.Time),
 
 		rwlock: &sync.RWMutex{},
 	}
 	return store
 }
 
-func (self *NeighboursStore) Start() {
+func (self *NeighboursStore) Start(ctx context.Context) {
 	log.Println("Starting local neighbours store")
-	go self.init()
+	go self.init(ctx)
 }
 
-func (self *NeighboursStore) init() {
+func (self *NeighboursStore) init(ctx context.Context) {
 	// Perform initial update
-	self.update()
+	self.update(ctx)
 
 	// Initial logging
 	self.Stats().Log()
 
 	// Periodically update store
 	for {
-		time.Sleep(5 * time.Minute)
-		self.update()
+		if !waitInterval(ctx, 5*time.Minute) {
+			return
+		}
+		self.update(ctx)
 	}
 }
 
-func (self *NeighboursStore) update() {
+func (self *NeighboursStore) update(ctx context.Context) {
 	for sourceId, _ := range self.neighboursMap {
 		// Get current state
 		if self.statusMap[sourceId].State == STATE_UPDATING {
@@ -80,7 +153,7 @@ func (self *NeighboursStore) update() {
 
 		source := self.configMap[sourceId].getInstance()
 
-		neighboursRes, err := source.Neighbours()
+		neighboursRes, err := source.Neighbours(ctx)
 		neighbours := neighboursRes.Neighbours
 		if err != nil {
 			// That's sad.
@@ -94,6 +167,9 @@ func (self *NeighboursStore) update() {
 			continue
 		}
 
+		// Add AS names and organisations
+		annotateNeighbours(neighbours)
+
 		// Update data
 		// Make neighbours index
 		index := make(NeighboursIndex)
@@ -102,14 +178,97 @@ func (self *NeighboursStore) update() {
 		}
 
 		self.rwlock.Lock()
+		previous := self.neighboursMap[sourceId]
 		self.neighboursMap[sourceId] = index
+		refresh := time.Now()
+		self.recordHistory(sourceId, index, refresh)
+		self.refreshMap[sourceId] = refresh
 		// Update state
 		self.statusMap[sourceId] = StoreStatus{
-			LastRefresh: time.Now(),
+			LastRefresh: refresh,
 			State:       STATE_READY,
 		}
 		self.rwlock.Unlock()
+
+		// Notify about changes
+		if AliceEvents != nil {
+			AliceEvents.PublishNeighbourChanges(
+				self.configMap[sourceId], previous, index)
+			AliceEvents.PublishNeighboursRefreshed(
+				self.configMap[sourceId], previous, index)
+		}
+	}
+}
+
+// Record state transitions, this must be called
+// with the write lock held.
+func (self *NeighboursStore) recordHistory(
+	sourceId int,
+	neighbours NeighboursIndex,
+	now time.Time,
+) {
+	history := self.historyMap[sourceId]
+	updated := make(NeighboursHistoryIndex)
+
+	// Neighbours which are gone are forgotten
+	for id, neighbour := range neighbours {
+		neighbourHistory, ok := history[id]
+		if !ok {
+			neighbourHistory = &NeighbourHistory{}
+		}
+		neighbourHistory.Record(neighbour, now)
+		updated[id] = neighbourHistory
 	}
+
+	self.historyMap[sourceId] = updated
+}
+
+// Get the state history of a neighbour
+func (self *NeighboursStore) GetNeighbourHistoryAt(
+	sourceId int,
+	id string,
+) (api.NeighbourHistoryResponse, bool) {
+	self.rwlock.RLock()
+	defer self.rwlock.RUnlock()
+
+	history, ok := self.historyMap[sourceId][id]
+	if !ok {
+		return api.NeighbourHistoryResponse{}, false
+	}
+
+	return history.Response(id, time.Now()), true
+}
+
+// List all currently flapping neighbours
+func (self *NeighboursStore) FlappingNeighbours() []FlappingNeighbourStats {
+	now := time.Now()
+	flapping := []FlappingNeighbourStats{}
+
+	self.rwlock.RLock()
+	for sourceId, history := range self.historyMap {
+		for id, neighbourHistory := range history {
+			if !neighbourHistory.IsFlapping(now) {
+				continue
+			}
+			neighbour := self.neighboursMap[sourceId][id]
+			flapping = append(flapping, FlappingNeighbourStats{
+				RouteserverId: sourceId,
+				NeighbourId:   id,
+				Asn:           neighbour.Asn,
+				Description:   neighbour.Description,
+				State:         neighbour.State,
+				FlapCount:     neighbourHistory.FlapCount,
+				FlapScore:     neighbourHistory.FlapScore(now),
+			})
+		}
+	}
+	self.rwlock.RUnlock()
+
+	sort.Slice(flapping, func(i, j int) bool {
+		return flapping[i].FlapScore > flapping[j].FlapScore
+	})
+
+	return flapping
 }
 
 func (self *NeighboursStore) GetNeighbourAt(
@@ -123,6 +282,24 @@ func (self *NeighboursStore) GetNeighbourAt(
 	return neighbours[id]
 }
 
+// Check if a neighbour exists. The result is only
+// known if the neighbours of the source were fetched.
+func (self *NeighboursStore) HasNeighbourAt(
+	sourceId int,
+	id string,
+) (bool, bool) {
+	self.rwlock.RLock()
+	neighbours := self.neighboursMap[sourceId]
+	self.rwlock.RUnlock()
+
+	if len(neighbours) == 0 {
+		return false, false
+	}
+	_, ok := neighbours[id]
+	return ok, true
+}
+
+// Find neighbours by description, AS name or organisation
 func (self *NeighboursStore) LookupNeighboursAt(
 	sourceId int,
 	query string,
@@ -134,7 +311,9 @@ func (self *NeighboursStore) LookupNeighboursAt(
 	self.rwlock.RUnlock()
 
 	for _, neighbour := range neighbours {
-		if !ContainsCi(neighbour.Description, query) {
+		if !ContainsCi(neighbour.Description, query) &&
+			!ContainsCi(neighbour.Organisation, query) &&
+			!ContainsCi(neighbour.AsName, query) {
 			continue
 		}
 
@@ -157,6 +336,53 @@ func (self *NeighboursStore) LookupNeighbours(
 	return results
 }
 
+// Find neighbours matching a structured query
+// on all route servers
+func (self *NeighboursStore) FilterNeighbours(
+	query NeighboursQuery,
+) []api.LookupNeighbour {
+	results := []api.LookupNeighbour{}
+
+	self.rwlock.RLock()
+	defer self.rwlock.RUnlock()
+
+	for sourceId, neighbours := range self.neighboursMap {
+		source := self.configMap[sourceId]
+		if query.Group != "" && source.Group != query.Group {
+			continue
+		}
+
+		rs := api.Routeserver{
+			Id:    source.Id,
+			Name:  source.Name,
+			Group: source.Group,
+		}
+
+		for _, neighbour := range neighbours {
+			if !query.Match(neighbour) {
+				continue
+			}
+			results = append(results, api.LookupNeighbour{
+				Neighbour:   neighbour,
+				Routeserver: rs,
+			})
+		}
+	}
+
+	// Keep the order stable for pagination
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Routeserver.Id != results[j].Routeserver.Id {
+			return results[i].Routeserver.Id < results[j].Routeserver.Id
+		}
+		if results[i].Asn != results[j].Asn {
+			return results[i].Asn < results[j].Asn
+		}
+		return results[i].Id < results[j].Id
+	})
+
+	return results
+}
+
 // Build some stats for monitoring
 func (self *NeighboursStore) Stats() NeighboursStoreStats {
 	totalNeighbours := 0
@@ -177,8 +403,9 @@ func (self *NeighboursStore) Stats() NeighboursStoreStats {
 	self.rwlock.RUnlock()
 
 	storeStats := NeighboursStoreStats{
-		TotalNeighbours: totalNeighbours,
-		RouteServers:    rsStats,
+		TotalNeighbours:    totalNeighbours,
+		RouteServers:       rsStats,
+		FlappingNeighbours: self.FlappingNeighbours(),
 	}
 	return storeStats
 }
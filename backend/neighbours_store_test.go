@@ -2,6 +2,8 @@ package main
 
 import (
 	"github.com/ecix/alice-lg/backend/api"
+	"net"
+	"sync"
 	"testing"
 )
 
@@ -33,6 +35,20 @@ func makeNeighboursStore() *NeighboursStore {
 			Id:          "ID2233_AS4223",
 			Description: "PEER AS4223 192.9.42.23 Cloudfoo Inc.",
 		},
+		"ID2233_AS4224": api.Neighbour{
+			Id:             "ID2233_AS4224",
+			Asn:            4224,
+			Address:        "192.9.42.24",
+			State:          "up",
+			RoutesReceived: 23,
+			Description:    "PEER AS4224 192.9.42.24",
+			AsName:         "EXAMPLE-NET",
+			Organisation:   "Example Networks GmbH",
+
+			ImportLimit:      25,
+			RouteLimitUsed:   23,
+			ImportLimitUsage: 92.0,
+		},
 	}
 
 	// Create store
@@ -41,6 +57,11 @@ func makeNeighboursStore() *NeighboursStore {
 			1: rs1,
 			2: rs2,
 		},
+		configMap: map[int]SourceConfig{
+			1: SourceConfig{Id: 1, Name: "rs1", Group: "FRA"},
+			2: SourceConfig{Id: 2, Name: "rs2", Group: "DUS"},
+		},
+		rwlock: &sync.RWMutex{},
 	}
 
 	return store
@@ -103,3 +124,55 @@ func TestNeighbourLookup(t *testing.T) {
 		t.Error("Wrong peer in lookup response")
 	}
 }
+
+func TestNeighbourLookupOrganisation(t *testing.T) {
+	store := makeNeighboursStore()
+
+	neighbours := store.LookupNeighboursAt(2, "example networks")
+	if len(neighbours) != 1 {
+		t.Error("Lookup should match exact 1 peer by organisation.")
+	}
+
+	neighbours = store.LookupNeighboursAt(2, "example-net")
+	if len(neighbours) != 1 {
+		t.Error("Lookup should match exact 1 peer by AS name.")
+	}
+}
+
+func TestFilterNeighbours(t *testing.T) {
+	store := makeNeighboursStore()
+
+	_, network, _ := net.ParseCIDR("192.9.42.0/24")
+	expected := []struct {
+		query NeighboursQuery
+		count int
+	}{
+		{NeighboursQuery{MaxRoutesReceived: -1}, 6},
+		{NeighboursQuery{Asn: 4224, MaxRoutesReceived: -1}, 1},
+		{NeighboursQuery{Network: network, MaxRoutesReceived: -1}, 1},
+		{NeighboursQuery{Address: "192.9.42.24", MaxRoutesReceived: -1}, 1},
+		{NeighboursQuery{State: "UP", MaxRoutesReceived: -1}, 1},
+		{NeighboursQuery{Description: "peer 1", MaxRoutesReceived: -1}, 3},
+		{NeighboursQuery{Group: "FRA", MaxRoutesReceived: -1}, 3},
+		{NeighboursQuery{MinRoutesReceived: 20, MaxRoutesReceived: -1}, 1},
+		{NeighboursQuery{MaxRoutesReceived: 20}, 5},
+		{NeighboursQuery{MinImportLimitUsage: 90, MaxRoutesReceived: -1}, 1},
+		{NeighboursQuery{MinImportLimitUsage: 95, MaxRoutesReceived: -1}, 0},
+	}
+
+	for _, e := range expected {
+		results := store.FilterNeighbours(e.query)
+		if len(results) != e.count {
+			t.Error("Expected", e.count, "results for", e.query,
+				"got:", len(results))
+		}
+	}
+
+	results := store.FilterNeighbours(NeighboursQuery{
+		Asn:               4224,
+		MaxRoutesReceived: -1,
+	})
+	if results[0].Routeserver.Name != "rs2" {
+		t.Error("Expected neighbour on rs2, got:", results[0].Routeserver)
+	}
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Rate limiting
+//
+// Every client has a token bucket per budget. Expensive
+// endpoints (routes, lookups) query the route servers
+// or search all routes and have a separate, smaller budget.
+//
+// Behind a reverse proxy, the client address is taken
+// from the configured header, if the request is from a
+// trusted proxy. Clients with an api key are identified
+// by the key and have the budgets of their access tier.
+// IPv6 clients are identified by their /64 network.
+
+const (
+	RATE_LIMIT_DEFAULT = iota
+	RATE_LIMIT_EXPENSIVE
+)
+
+// New clients share a bucket if the limiter
+// holds this many buckets.
+const RATE_LIMIT_MAX_BUCKETS = 100000
+const RATE_LIMIT_OVERFLOW_KEY = "overflow"
+
+type tokenBucket struct {
+	tokens    float64
+	updatedAt time.Time
+}
+
+type RateLimiter struct {
+	rate  float64 // tokens per second
+	burst float64
+
+	buckets    map[string]*tokenBucket
+	maxBuckets int
+	lock       *sync.Mutex
+}
+
+func NewRateLimiter(rate float64, burst int) *RateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &RateLimiter{
+		rate:       rate,
+		burst:      float64(burst),
+		buckets:    make(map[string]*tokenBucket),
+		maxBuckets: RATE_LIMIT_MAX_BUCKETS,
+		lock:       &sync.Mutex{},
+	}
+}
+
+// Take a token from the bucket of the client. If the
+// bucket is empty, the time until the next token is returned.
+func (self *RateLimiter) Allow(key string, now time.Time) (bool, time.Duration) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+
+	bucket, ok := self.buckets[key]
+	if !ok && len(self.buckets) >= self.maxBuckets {
+		self.removeFull(now)
+		if len(self.buckets) >= self.maxBuckets {
+			key = RATE_LIMIT_OVERFLOW_KEY
+			bucket, ok = self.buckets[key]
+		}
+	}
+	if !ok {
+		bucket = &tokenBucket{
+			tokens:    self.burst,
+			updatedAt: now,
+		}
+		self.buckets[key] = bucket
+	}
+
+	// Refill
+	elapsed := now.Sub(bucket.updatedAt).Seconds()
+	if elapsed > 0 {
+		bucket.tokens = math.Min(self.burst, bucket.tokens+elapsed*self.rate)
+		bucket.updatedAt = now
+	}
+
+	if bucket.tokens >= 1 {
+		bucket.tokens -= 1
+		return true, 0
+	}
+
+	wait := (1 - bucket.tokens) / self.rate
+	return false, time.Duration(wait * float64(time.Second))
+}
+
+// Remove buckets, which are full again
+func (self *RateLimiter) cleanup(now time.Time) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.removeFull(now)
+}
+
+func (self *RateLimiter) removeFull(now time.Time) {
+	refill := time.Duration(self.burst / self.rate * float64(time.Second))
+	for key, bucket := range self.buckets {
+		if now.Sub(bucket.updatedAt) > refill {
+			delete(self.buckets, key)
+		}
+	}
+}
+
+type RateLimits struct {
+	limiters map[int]*RateLimiter
+
+	// Clients with an api key are limited by the
+	// budgets of the tier
+	tiers map[string]map[int]*RateLimiter
+
+	trustedProxies []*net.IPNet
+	proxyHeader    string
+}
+
+func NewRateLimits(
+	config RateLimitConfig,
+	tiers map[string]AccessTierConfig,
+) (*RateLimits, error) {
+	proxies, err := parseNetworks(config.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+
+	limits := &RateLimits{
+		limiters: map[int]*RateLimiter{
+			RATE_LIMIT_DEFAULT: NewRateLimiter(
+				config.Rate, config.Burst),
+			RATE_LIMIT_EXPENSIVE: NewRateLimiter(
+				config.ExpensiveRate, config.ExpensiveBurst),
+		},
+		tiers:          make(map[string]map[int]*RateLimiter),
+		trustedProxies: proxies,
+		proxyHeader:    config.ProxyHeader,
+	}
+
+	// Budgets without rate are unlimited
+	for name, tier := range tiers {
+		limiters := make(map[int]*RateLimiter)
+		if tier.Rate > 0 {
+			limiters[RATE_LIMIT_DEFAULT] = NewRateLimiter(
+				tier.Rate, tier.Burst)
+		}
+		if tier.ExpensiveRate > 0 {
+			limiters[RATE_LIMIT_EXPENSIVE] = NewRateLimiter(
+				tier.ExpensiveRate, tier.ExpensiveBurst)
+		}
+		limits.tiers[name] = limiters
+	}
+
+	return limits, nil
+}
+
+func (self *RateLimits) Start(ctx context.Context) {
+	log.Println("Starting rate limits")
+	go self.init(ctx)
+}
+
+// Periodically remove idle clients
+func (self *RateLimits) init(ctx context.Context) {
+	for {
+		if !waitInterval(ctx, time.Minute) {
+			return
+		}
+		now := time.Now()
+		for _, limiter := range self.limiters {
+			limiter.cleanup(now)
+		}
+		for _, limiters := range self.tiers {
+			for _, limiter := range limiters {
+				limiter.cleanup(now)
+			}
+		}
+	}
+}
+
+// Check the budget of the client, returns an error
+// with a retry hint if the client has to wait.
+func (self *RateLimits) Check(req *http.Request, access *Access, budget int) error {
+	client := clientNetwork(self.ClientIp(req))
+	limiters := self.limiters
+	if access.Key != "" {
+		client = access.Key
+		limiters = self.tiers[access.Tier.Name]
+	}
+
+	limiter, ok := limiters[budget]
+	if !ok {
+		return nil // unlimited
+	}
+
+	allowed, wait := limiter.Allow(client, time.Now())
+	if allowed {
+		return nil
+	}
+
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	return NewRateLimitedError(retryAfter)
+}
+
+func (self *RateLimits) isTrustedProxy(ip net.IP) bool {
+	for _, network := range self.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Get the address of the client
+func (self *RateLimits) ClientIp(req *http.Request) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+
+	// Requests on the UNIX socket are
+	// passed by a local reverse proxy.
+	remote := net.ParseIP(host)
+	local := remote == nil
+	if !local && !self.isTrustedProxy(remote) {
+		return host
+	}
+
+	header := req.Header.Get(self.proxyHeader)
+	if header == "" {
+		return host
+	}
+
+	// The header may contain a chain of proxies:
+	// The client is the last address not trusted.
+	addresses := strings.Split(header, ",")
+	for i := len(addresses) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(addresses[i]))
+		if ip == nil {
+			break // forged or broken header
+		}
+		if !self.isTrustedProxy(ip) || i == 0 {
+			return ip.String()
+		}
+	}
+
+	return host
+}
+
+// Clients with IPv6 usually have a /64 network,
+// the budget is shared by the addresses of the network.
+func clientNetwork(client string) string {
+	ip := net.ParseIP(client)
+	if ip == nil || ip.To4() != nil {
+		return client
+	}
+	return ip.Mask(net.CIDRMask(64, 128)).String() + "/64"
+}
+
+// Parse a list of networks, addresses are single host networks
+func parseNetworks(values []string) ([]*net.IPNet, error) {
+	networks := []*net.IPNet{}
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			if ip := net.ParseIP(value); ip != nil && ip.To4() != nil {
+				value += "/32"
+			} else {
+				value += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	limiter := NewRateLimiter(1, 2)
+	now := time.Now()
+
+	// Burst
+	for i := 0; i < 2; i++ {
+		if allowed, _ := limiter.Allow("10.0.0.1", now); !allowed {
+			t.Error("Expected request", i, "to be allowed")
+		}
+	}
+
+	allowed, wait := limiter.Allow("10.0.0.1", now)
+	if allowed {
+		t.Error("Expected request to be limited")
+	}
+	if wait != time.Second {
+		t.Error("Expected to wait 1s, got:", wait)
+	}
+
+	// Other clients have their own budget
+	if allowed, _ := limiter.Allow("10.0.0.2", now); !allowed {
+		t.Error("Expected other client to be allowed")
+	}
+
+	// Refill
+	now = now.Add(1500 * time.Millisecond)
+	if allowed, _ := limiter.Allow("10.0.0.1", now); !allowed {
+		t.Error("Expected request to be allowed after refill")
+	}
+
+	// Idle clients are removed
+	limiter.cleanup(now.Add(time.Minute))
+	if len(limiter.buckets) != 0 {
+		t.Error("Expected buckets to be removed:", limiter.buckets)
+	}
+}
+
+func TestRateLimiterMaxBuckets(t *testing.T) {
+	limiter := NewRateLimiter(1, 1)
+	limiter.maxBuckets = 2
+	now := time.Now()
+
+	limiter.Allow("10.0.0.1", now)
+	limiter.Allow("10.0.0.2", now)
+
+	// New clients share the overflow bucket
+	if allowed, _ := limiter.Allow("10.0.0.3", now); !allowed {
+		t.Error("Expected first new client to be allowed")
+	}
+	if allowed, _ := limiter.Allow("10.0.0.4", now); allowed {
+		t.Error("Expected new clients to share a bucket")
+	}
+	if len(limiter.buckets) != 3 {
+		t.Error("Unexpected buckets:", limiter.buckets)
+	}
+
+	// Full buckets are replaced
+	now = now.Add(time.Minute)
+	if allowed, _ := limiter.Allow("10.0.0.4", now); !allowed {
+		t.Error("Expected client to be allowed after refill")
+	}
+	if _, ok := limiter.buckets["10.0.0.4"]; !ok {
+		t.Error("Expected client to have a bucket:", limiter.buckets)
+	}
+}
+
+func TestClientNetwork(t *testing.T) {
+	expected := map[string]string{
+		"192.0.2.1":              "192.0.2.1",
+		"2001:db8:23:42::1":      "2001:db8:23:42::/64",
+		"2001:db8:23:42:ffff::2": "2001:db8:23:42::/64",
+		"2001:db8:23:43::1":      "2001:db8:23:43::/64",
+		"::ffff:192.0.2.1":       "::ffff:192.0.2.1",
+		"@":                      "@",
+	}
+	for client, network := range expected {
+		if result := clientNetwork(client); result != network {
+			t.Error("Expected", network, "for", client, "got:", result)
+		}
+	}
+}
+
+func TestRateLimitsClientIp(t *testing.T) {
+	limits, err := NewRateLimits(RateLimitConfig{
+		Rate:           1,
+		Burst:          1,
+		ExpensiveRate:  1,
+		ExpensiveBurst: 1,
+		TrustedProxies: []string{"127.0.0.1", "10.23.0.0/16"},
+		ProxyHeader:    "X-Forwarded-For",
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []struct {
+		remote string
+		header string
+		client string
+	}{
+		{"192.0.2.1:4242", "", "192.0.2.1"},
+		{"192.0.2.1:4242", "198.51.100.1", "192.0.2.1"}, // not trusted
+		{"127.0.0.1:4242", "198.51.100.1", "198.51.100.1"},
+		{"127.0.0.1:4242", "203.0.113.9, 198.51.100.1, 10.23.1.1", "198.51.100.1"},
+		{"127.0.0.1:4242", "10.23.1.2, 10.23.1.1", "10.23.1.2"},
+		{"127.0.0.1:4242", "garbage", "127.0.0.1"},
+		{"127.0.0.1:4242", "", "127.0.0.1"},
+	}
+
+	for _, e := range expected {
+		req := httptest.NewRequest("GET", "/api/status", nil)
+		req.RemoteAddr = e.remote
+		if e.header != "" {
+			req.Header.Set("X-Forwarded-For", e.header)
+		}
+		client := limits.ClientIp(req)
+		if client != e.client {
+			t.Error("Expected client", e.client, "for", e.header, "got:", client)
+		}
+	}
+}
+
+func TestGuard(t *testing.T) {
+	limits, err := NewRateLimits(RateLimitConfig{
+		Rate:           10,
+		Burst:          10,
+		ExpensiveRate:  0.5,
+		ExpensiveBurst: 1,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	AliceRateLimits = limits
+	defer func() { AliceRateLimits = nil }()
+
+	handle := guard(RATE_LIMIT_EXPENSIVE, func(res http.ResponseWriter,
+		req *http.Request, params httprouter.Params) {
+		res.WriteHeader(http.StatusOK)
+	})
+
+	request := func() *httptest.ResponseRecorder {
+		res := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "/api/lookup/prefix?q=10.0.0.0", nil)
+		handle(res, req, nil)
+		return res
+	}
+
+	if res := request(); res.Code != http.StatusOK {
+		t.Error("Expected first request to pass, got:", res.Code)
+	}
+
+	res := request()
+	if res.Code != http.StatusTooManyRequests {
+		t.Error("Expected 429, got:", res.Code)
+	}
+	if res.Header().Get("Retry-After") != "2" {
+		t.Error("Expected Retry-After 2, got:", res.Header().Get("Retry-After"))
+	}
+
+	// The default budget is not affected
+	if allowed, _ := limits.limiters[RATE_LIMIT_DEFAULT].Allow("192.0.2.1", time.Now()); !allowed {
+		t.Error("Expected default budget to be available")
+	}
+}
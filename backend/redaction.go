@@ -0,0 +1,358 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"path"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+// Redaction
+//
+// The details of neighbours and routes are the upstream
+// responses and may contain internal information like
+// filter names or timers. The redactor removes detail keys,
+// masks addresses and hides neighbours with their routes
+// from all api responses.
+//
+// Routes are hidden, if the neighbour id matches or the
+// neighbour ASN (the first ASN in the path) is hidden.
+
+const REDACTED_ADDRESS = "[redacted]"
+
+type Redactor struct {
+	allow map[string]bool
+	deny  map[string]bool
+
+	hideAsns       map[int]bool
+	hideNeighbours []string
+
+	maskAddresses bool
+}
+
+func NewRedactor(config RedactionConfig) (*Redactor, error) {
+	redactor := &Redactor{
+		allow:          make(map[string]bool),
+		deny:           make(map[string]bool),
+		hideAsns:       make(map[int]bool),
+		hideNeighbours: config.HideNeighbours,
+		maskAddresses:  config.MaskAddresses,
+	}
+
+	for _, key := range config.DetailsAllow {
+		redactor.allow[key] = true
+	}
+	for _, key := range config.DetailsDeny {
+		redactor.deny[key] = true
+	}
+	for _, asn := range config.HideAsns {
+		redactor.hideAsns[asn] = true
+	}
+
+	for _, pattern := range config.HideNeighbours {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("Invalid neighbour pattern: %s", pattern)
+		}
+	}
+
+	return redactor, nil
+}
+
+// Get a redactor keeping the details, hidden
+// neighbours and routes are still removed.
+func (self *Redactor) KeepDetails() *Redactor {
+	redactor := *self
+	redactor.allow = map[string]bool{}
+	redactor.deny = map[string]bool{}
+	redactor.maskAddresses = false
+	return &redactor
+}
+
+// Check if a neighbour id is hidden
+func (self *Redactor) HideNeighbourId(neighbourId string) bool {
+	for _, pattern := range self.hideNeighbours {
+		if match, _ := path.Match(pattern, neighbourId); match {
+			return true
+		}
+	}
+	return false
+}
+
+func (self *Redactor) HideNeighbour(neighbour api.Neighbour) bool {
+	return self.hideAsns[neighbour.Asn] || self.HideNeighbourId(neighbour.Id)
+}
+
+func (self *Redactor) HideRoute(route api.Route) bool {
+	if self.HideNeighbourId(route.NeighbourId) {
+		return true
+	}
+	return len(route.Bgp.AsPath) > 0 && self.hideAsns[route.Bgp.AsPath[0]]
+}
+
+func (self *Redactor) HideLookupRoute(route api.LookupRoute) bool {
+	if self.HideNeighbourId(route.NeighbourId) ||
+		self.hideAsns[route.Neighbour.Asn] {
+		return true
+	}
+	return len(route.Bgp.AsPath) > 0 && self.hideAsns[route.Bgp.AsPath[0]]
+}
+
+// Make a redacted copy of the details
+func (self *Redactor) RedactDetails(details map[string]interface{}) map[string]interface{} {
+	if details == nil {
+		return nil
+	}
+
+	redacted := make(map[string]interface{})
+	for key, value := range details {
+		if len(self.allow) > 0 && !self.allow[key] {
+			continue
+		}
+		if self.deny[key] {
+			continue
+		}
+		if self.maskAddresses {
+			value = maskAddresses(value)
+		}
+		redacted[key] = value
+	}
+
+	return redacted
+}
+
+// Replace IP addresses and networks in a value
+func maskAddresses(value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		if net.ParseIP(v) != nil {
+			return REDACTED_ADDRESS
+		}
+		if _, _, err := net.ParseCIDR(v); err == nil {
+			return REDACTED_ADDRESS
+		}
+		return v
+	case map[string]interface{}:
+		masked := make(map[string]interface{})
+		for key, value := range v {
+			masked[key] = maskAddresses(value)
+		}
+		return masked
+	case []interface{}:
+		masked := make([]interface{}, len(v))
+		for i, value := range v {
+			masked[i] = maskAddresses(value)
+		}
+		return masked
+	}
+	return value
+}
+
+func (self *Redactor) RedactNeighbour(neighbour api.Neighbour) api.Neighbour {
+	neighbour.Details = self.RedactDetails(neighbour.Details)
+	return neighbour
+}
+
+func (self *Redactor) RedactNeighbours(neighbours []api.Neighbour) []api.Neighbour {
+	results := make([]api.Neighbour, 0, len(neighbours))
+	for _, neighbour := range neighbours {
+		if self.HideNeighbour(neighbour) {
+			continue
+		}
+		results = append(results, self.RedactNeighbour(neighbour))
+	}
+	return results
+}
+
+func (self *Redactor) RedactLookupNeighbours(neighbours []api.LookupNeighbour) []api.LookupNeighbour {
+	results := make([]api.LookupNeighbour, 0, len(neighbours))
+	for _, neighbour := range neighbours {
+		if self.HideNeighbour(neighbour.Neighbour) {
+			continue
+		}
+		neighbour.Neighbour = self.RedactNeighbour(neighbour.Neighbour)
+		results = append(results, neighbour)
+	}
+	return results
+}
+
+func (self *Redactor) RedactRoutes(routes []api.Route) []api.Route {
+	if routes == nil {
+		return nil
+	}
+	results := make([]api.Route, 0, len(routes))
+	for _, route := range routes {
+		if self.HideRoute(route) {
+			continue
+		}
+		route.Details = self.RedactDetails(route.Details)
+		results = append(results, route)
+	}
+	return results
+}
+
+func (self *Redactor) redactLookupRoute(route api.LookupRoute) api.LookupRoute {
+	route.Neighbour = self.RedactNeighbour(route.Neighbour)
+	route.Details = self.RedactDetails(route.Details)
+	return route
+}
+
+func (self *Redactor) RedactLookupRoutes(routes []api.LookupRoute) []api.LookupRoute {
+	results := make([]api.LookupRoute, 0, len(routes))
+	for _, route := range routes {
+		if self.HideLookupRoute(route) {
+			continue
+		}
+		results = append(results, self.redactLookupRoute(route))
+	}
+	return results
+}
+
+// Redact an api response. Responses about a single
+// hidden neighbour fail as if the neighbour did not exist.
+func (self *Redactor) RedactResponse(response api.Response) (api.Response, error) {
+	notFound := func(neighbourId string) error {
+		return NewNotFoundError("Neighbour not found: %s", neighbourId)
+	}
+
+	switch r := response.(type) {
+	case api.NeighboursResponse:
+		r.Neighbours = self.RedactNeighbours(r.Neighbours)
+		return r, nil
+
+	case api.NeighbourResponse:
+		if self.HideNeighbour(r.Neighbour) {
+			return nil, notFound(r.Neighbour.Id)
+		}
+		r.Neighbour = self.RedactNeighbour(r.Neighbour)
+		return r, nil
+
+	case api.NeighbourHistoryResponse:
+		if self.HideNeighbourId(r.NeighbourId) {
+			return nil, notFound(r.NeighbourId)
+		}
+		return r, nil
+
+	case api.NeighboursLookupResponseGlobal:
+		neighbours := self.RedactLookupNeighbours(r.Neighbours)
+		r.TotalNeighbours -= len(r.Neighbours) - len(neighbours)
+		r.Neighbours = neighbours
+		return r, nil
+
+	case api.RoutesResponse:
+		r.Imported = self.RedactRoutes(r.Imported)
+		r.Filtered = self.RedactRoutes(r.Filtered)
+		r.NotExported = self.RedactRoutes(r.NotExported)
+		return r, nil
+
+	case api.RoutesLookupResponse:
+		r.Routes = self.RedactLookupRoutes(r.Routes)
+		return r, nil
+
+	case api.RoutesLookupResponseGlobal:
+		routes := self.RedactLookupRoutes(r.Routes)
+		r.TotalRoutes -= len(r.Routes) - len(routes)
+		r.Routes = routes
+		return r, nil
+
+	case api.RouteDetailResponse:
+		if r.Neighbour != nil {
+			if self.HideNeighbour(*r.Neighbour) {
+				return nil, notFound(r.Neighbour.Id)
+			}
+			neighbour := self.RedactNeighbour(*r.Neighbour)
+			r.Neighbour = &neighbour
+		}
+		paths := make([]api.RoutePath, 0, len(r.Paths))
+		for _, path := range r.Paths {
+			if self.HideLookupRoute(path.LookupRoute) {
+				continue
+			}
+			path.LookupRoute = self.redactLookupRoute(path.LookupRoute)
+			paths = append(paths, path)
+		}
+		r.Paths = paths
+		return r, nil
+
+	case api.ExportVisibilityResponse:
+		if self.HideNeighbour(r.Target) {
+			return nil, notFound(r.Target.Id)
+		}
+		r.Target = self.RedactNeighbour(r.Target)
+		routes := make([]api.ExportVisibility, 0, len(r.Routes))
+		for _, route := range r.Routes {
+			if self.HideNeighbourId(route.NeighbourId) ||
+				self.hideAsns[route.Asn] {
+				continue
+			}
+			routes = append(routes, route)
+		}
+		r.Routes = routes
+		return r, nil
+
+	case api.ExportMatrixResponse:
+		if self.HideNeighbour(r.Neighbour) {
+			return nil, notFound(r.Neighbour.Id)
+		}
+		r.Neighbour = self.RedactNeighbour(r.Neighbour)
+		targets := make([]api.ExportMatrixEntry, 0, len(r.Targets))
+		for _, target := range r.Targets {
+			if self.HideNeighbour(target.Target) {
+				continue
+			}
+			target.Target = self.RedactNeighbour(target.Target)
+			targets = append(targets, target)
+		}
+		r.Targets = targets
+		return r, nil
+
+	case api.IrrReportResponse:
+		if self.HideNeighbourId(r.NeighbourId) || self.hideAsns[r.Asn] {
+			return nil, notFound(r.NeighbourId)
+		}
+		r.OriginNotInAsSet = self.RedactRoutes(r.OriginNotInAsSet)
+		r.NoRouteObject = self.RedactRoutes(r.NoRouteObject)
+		return r, nil
+
+	case *AppStatus:
+		status := *r
+		flapping := make([]FlappingNeighbourStats, 0,
+			len(status.Neighbours.FlappingNeighbours))
+		for _, neighbour := range status.Neighbours.FlappingNeighbours {
+			if self.HideNeighbour(api.Neighbour{
+				Id:  neighbour.NeighbourId,
+				Asn: neighbour.Asn,
+			}) {
+				continue
+			}
+			flapping = append(flapping, neighbour)
+		}
+		status.Neighbours.FlappingNeighbours = flapping
+		return &status, nil
+
+	case api.RoutesComparisonResponse:
+		// Neighbours are compared by ASN, as the ids differ
+		// between the route servers: Only hide_asns applies.
+		comparisons := make([]api.RoutesComparison, 0, len(r.Comparisons))
+		for _, comparison := range r.Comparisons {
+			neighbours := []api.NeighbourRoutesDiff{}
+			for _, diff := range comparison.Neighbours {
+				if !self.hideAsns[diff.Asn] {
+					neighbours = append(neighbours, diff)
+				}
+			}
+			comparison.Neighbours = neighbours
+			comparison.Consistent = len(neighbours) == 0
+			comparisons = append(comparisons, comparison)
+		}
+		r.Comparisons = comparisons
+		return r, nil
+	}
+
+	return response, nil
+}
+
+// Check if an event may be published
+func (self *Redactor) HideEvent(event api.Event) bool {
+	return event.Neighbour != nil && self.HideNeighbour(*event.Neighbour)
+}
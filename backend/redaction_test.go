@@ -0,0 +1,158 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+func makeTestRedactor(t *testing.T) *Redactor {
+	redactor, err := NewRedactor(RedactionConfig{
+		Enabled:        true,
+		DetailsDeny:    []string{"input_filter", "hold_timer"},
+		HideAsns:       []int{64512},
+		HideNeighbours: []string{"ID9*"},
+		MaskAddresses:  true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return redactor
+}
+
+func TestRedactDetails(t *testing.T) {
+	redactor := makeTestRedactor(t)
+
+	details := map[string]interface{}{
+		"input_filter":   "AS2342_in",
+		"hold_timer":     "146/180",
+		"description":    "PEER AS2342",
+		"source_address": "194.9.117.253",
+		"routes": map[string]interface{}{
+			"imported": float64(23),
+			"network":  "2001:db8::/32",
+		},
+	}
+
+	redacted := redactor.RedactDetails(details)
+	if _, ok := redacted["input_filter"]; ok {
+		t.Error("Expected input_filter to be removed")
+	}
+	if _, ok := redacted["hold_timer"]; ok {
+		t.Error("Expected hold_timer to be removed")
+	}
+	if redacted["description"] != "PEER AS2342" {
+		t.Error("Expected description to be kept")
+	}
+	if redacted["source_address"] != REDACTED_ADDRESS {
+		t.Error("Expected address to be masked:", redacted["source_address"])
+	}
+	routes := redacted["routes"].(map[string]interface{})
+	if routes["network"] != REDACTED_ADDRESS || routes["imported"] != float64(23) {
+		t.Error("Unexpected nested details:", routes)
+	}
+
+	// The original is not modified
+	if details["source_address"] != "194.9.117.253" {
+		t.Error("The original details were modified")
+	}
+
+	// Allowlist
+	redactor, _ = NewRedactor(RedactionConfig{
+		DetailsAllow: []string{"description"},
+	})
+	redacted = redactor.RedactDetails(details)
+	if len(redacted) != 1 {
+		t.Error("Expected only allowed keys, got:", redacted)
+	}
+}
+
+func TestRedactResponse(t *testing.T) {
+	redactor := makeTestRedactor(t)
+
+	neighbours := api.NeighboursResponse{
+		Neighbours: api.Neighbours{
+			{Id: "ID1_AS2342", Asn: 2342},
+			{Id: "ID2_AS64512", Asn: 64512},
+			{Id: "ID99_AS4224", Asn: 4224},
+		},
+	}
+	result, err := redactor.RedactResponse(neighbours)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.(api.NeighboursResponse).Neighbours) != 1 {
+		t.Error("Expected hidden neighbours to be removed, got:", result)
+	}
+
+	// A single hidden neighbour is not found
+	_, err = redactor.RedactResponse(api.NeighbourResponse{
+		Neighbour: api.Neighbour{Id: "ID99_AS4224", Asn: 4224},
+	})
+	if err == nil {
+		t.Error("Expected hidden neighbour not to be found")
+	}
+
+	// Routes of hidden neighbours
+	routes := api.RoutesLookupResponseGlobal{
+		Routes: []api.LookupRoute{
+			{NeighbourId: "ID1_AS2342", Bgp: api.BgpInfo{AsPath: []int{2342}}},
+			{NeighbourId: "ID3_AS64512", Bgp: api.BgpInfo{AsPath: []int{64512}}},
+			{NeighbourId: "ID99_AS4224", Bgp: api.BgpInfo{AsPath: []int{4224}}},
+		},
+		TotalRoutes: 3,
+	}
+	result, _ = redactor.RedactResponse(routes)
+	lookup := result.(api.RoutesLookupResponseGlobal)
+	if len(lookup.Routes) != 1 || lookup.TotalRoutes != 1 {
+		t.Error("Expected routes of hidden neighbours to be removed, got:", lookup)
+	}
+
+	// Flapping neighbours in the app status
+	status := &AppStatus{
+		Neighbours: NeighboursStoreStats{
+			FlappingNeighbours: []FlappingNeighbourStats{
+				{NeighbourId: "ID1_AS2342", Asn: 2342},
+				{NeighbourId: "ID2_AS64512", Asn: 64512},
+				{NeighbourId: "ID99_AS4224", Asn: 4224},
+			},
+		},
+	}
+	result, _ = redactor.RedactResponse(status)
+	flapping := result.(*AppStatus).Neighbours.FlappingNeighbours
+	if len(flapping) != 1 || flapping[0].NeighbourId != "ID1_AS2342" {
+		t.Error("Expected hidden flapping neighbours to be removed, got:", flapping)
+	}
+	if len(status.Neighbours.FlappingNeighbours) != 3 {
+		t.Error("The original status was modified")
+	}
+
+	// Export visibility of routes announced by hidden neighbours
+	visibility := api.ExportVisibilityResponse{
+		Target: api.Neighbour{Id: "ID1_AS2342", Asn: 2342},
+		Routes: []api.ExportVisibility{
+			{NeighbourId: "ID1_AS2342", Asn: 2342},
+			{NeighbourId: "ID3_AS64512", Asn: 64512},
+			{NeighbourId: "ID99_AS4224", Asn: 4224},
+		},
+	}
+	result, _ = redactor.RedactResponse(visibility)
+	exports := result.(api.ExportVisibilityResponse).Routes
+	if len(exports) != 1 || exports[0].NeighbourId != "ID1_AS2342" {
+		t.Error("Expected routes of hidden neighbours to be removed, got:", exports)
+	}
+
+	// Events
+	if !redactor.HideEvent(api.Event{Neighbour: &api.Neighbour{Asn: 64512}}) {
+		t.Error("Expected event of hidden neighbour to be hidden")
+	}
+}
+
+func TestRedactorInvalidPattern(t *testing.T) {
+	_, err := NewRedactor(RedactionConfig{
+		HideNeighbours: []string{"ID[1"},
+	})
+	if err == nil {
+		t.Error("Expected invalid pattern to be rejected")
+	}
+}
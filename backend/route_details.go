@@ -0,0 +1,98 @@
+package main
+
+import (
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+// Route details
+//
+// Explain the candidate paths of a prefix on a route server:
+// Which path is the best, why a path was filtered and
+// why it is not exported to a neighbour.
+
+// Get the reason id from the large community <asn>:<id>:<reason>
+func largeCommunityReason(route api.Route, asn, id int) (int, bool) {
+	for _, community := range route.Bgp.LargeCommunities {
+		if len(community) != 3 {
+			continue
+		}
+		if community[0] == asn && community[1] == id {
+			return community[2], true
+		}
+	}
+	return 0, false
+}
+
+// Explain why a route was filtered
+func routeRejectReasonDetail(route api.Route, rejection RejectionsConfig) *api.RouteReason {
+	id, ok := largeCommunityReason(route, rejection.Asn, rejection.RejectId)
+	if !ok {
+		return nil
+	}
+	return &api.RouteReason{
+		Id:     id,
+		Reason: rejection.Reasons[id],
+	}
+}
+
+// Explain why a route is not exported
+func routeNoexportReasonDetail(route api.Route, noexport NoexportsConfig) *api.RouteReason {
+	id, ok := largeCommunityReason(route, noexport.Asn, noexport.NoexportId)
+	if !ok {
+		return nil
+	}
+	return &api.RouteReason{
+		Id:     id,
+		Reason: noexport.Reasons[id],
+	}
+}
+
+// Make the paths of a prefix with explanations
+func makeRoutePaths(
+	source SourceConfig,
+	prefix string,
+	state string,
+	routes []api.Route,
+	ui UiConfig,
+) []api.RoutePath {
+	paths := []api.RoutePath{}
+	for _, route := range routes {
+		if route.Network != prefix {
+			continue
+		}
+
+		lookup := routeToLookupRoute(source, state, route)
+		lookup.Details = route.Details
+
+		path := api.RoutePath{
+			LookupRoute: lookup,
+		}
+		switch state {
+		case "filtered":
+			path.RejectReason = routeRejectReasonDetail(route, ui.RoutesRejections)
+		case "not_exported":
+			path.NoexportReason = routeNoexportReasonDetail(route, ui.RoutesNoexports)
+		}
+
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+// Get all paths of a prefix on a route server
+func (self *RoutesStore) RoutePathsAt(
+	sourceId int,
+	prefix string,
+	ui UiConfig,
+) (api.ApiStatus, []api.RoutePath) {
+	self.rwlock.RLock()
+	source := self.configMap[sourceId]
+	routes := self.routesMap[sourceId]
+	self.rwlock.RUnlock()
+
+	paths := makeRoutePaths(source, prefix, "imported", routes.Imported, ui)
+	paths = append(paths,
+		makeRoutePaths(source, prefix, "filtered", routes.Filtered, ui)...)
+
+	return routes.Api, paths
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+func TestMakeRoutePaths(t *testing.T) {
+	AliceNeighboursStore = makeNeighboursStore()
+	defer func() { AliceNeighboursStore = nil }()
+
+	ui := UiConfig{
+		RoutesRejections: RejectionsConfig{
+			Asn:      9033,
+			RejectId: 65666,
+			Reasons: map[int]string{
+				9: "Prefix not found in IRRDB for Origin AS",
+			},
+		},
+		RoutesNoexports: NoexportsConfig{
+			Asn:        9033,
+			NoexportId: 65667,
+			Reasons: map[int]string{
+				1: "The target peer policy is Fairly-open and the sender ASN is an exception",
+			},
+		},
+	}
+	source := SourceConfig{Id: 1, Name: "rs1"}
+
+	routes := []api.Route{
+		{
+			NeighbourId: "ID163_AS31078",
+			Network:     "10.23.0.0/16",
+			Bgp: api.BgpInfo{
+				LargeCommunities: []api.Community{{9033, 65666, 9}},
+			},
+			Details: api.Details{"primary": false},
+		},
+		{
+			NeighbourId: "ID163_AS31078",
+			Network:     "10.23.42.0/24",
+		},
+	}
+
+	paths := makeRoutePaths(source, "10.23.0.0/16", "filtered", routes, ui)
+	if len(paths) != 1 {
+		t.Fatal("Expected one path, got:", paths)
+	}
+	if paths[0].RejectReason == nil || paths[0].RejectReason.Id != 9 ||
+		paths[0].RejectReason.Reason != ui.RoutesRejections.Reasons[9] {
+		t.Error("Unexpected reject reason:", paths[0].RejectReason)
+	}
+	if paths[0].Details == nil {
+		t.Error("Expected the upstream details to be included")
+	}
+
+	// Without a reason community
+	paths = makeRoutePaths(source, "10.23.42.0/24", "not_exported", routes, ui)
+	if len(paths) != 1 || paths[0].NoexportReason != nil {
+		t.Error("Expected a path without noexport reason, got:", paths)
+	}
+}
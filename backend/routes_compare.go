@@ -0,0 +1,211 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+// Route server comparison
+//
+// Redundant route servers should carry the same view.
+// Neighbours are matched by ASN, as the protocol ids of a
+// session usually differ between route servers. If a neighbour
+// announces a prefix over multiple sessions, the first route
+// (imported before filtered) is compared.
+
+type comparedRoute struct {
+	State string
+	Route api.Route
+}
+
+// Map neighbour ASNs to their routes by prefix
+type RoutesComparisonIndex map[int]map[string]comparedRoute
+
+// Get the ASN of the neighbour a route was received from
+func routeNeighbourAsn(sourceId int, route api.Route) int {
+	asn := 0
+	if AliceNeighboursStore != nil {
+		asn = AliceNeighboursStore.GetNeighbourAt(sourceId, route.NeighbourId).Asn
+	}
+	if asn == 0 && len(route.Bgp.AsPath) > 0 {
+		asn = route.Bgp.AsPath[0]
+	}
+	return asn
+}
+
+// Build the comparison index of a routes response
+func makeRoutesComparisonIndex(
+	routes api.RoutesResponse,
+	neighbourAsn func(api.Route) int,
+) RoutesComparisonIndex {
+	index := make(RoutesComparisonIndex)
+
+	add := func(state string, routes []api.Route) {
+		for _, route := range routes {
+			asn := neighbourAsn(route)
+			prefixes, ok := index[asn]
+			if !ok {
+				prefixes = make(map[string]comparedRoute)
+				index[asn] = prefixes
+			}
+			if _, ok := prefixes[route.Network]; ok {
+				continue
+			}
+			prefixes[route.Network] = comparedRoute{
+				State: state,
+				Route: route,
+			}
+		}
+	}
+
+	add("imported", routes.Imported)
+	add("filtered", routes.Filtered)
+
+	return index
+}
+
+// List the names of the differing bgp attributes
+func routeAttributesDiff(a, b api.BgpInfo) []string {
+	attributes := []string{}
+
+	if a.Origin != b.Origin {
+		attributes = append(attributes, "origin")
+	}
+	if !reflect.DeepEqual(a.AsPath, b.AsPath) {
+		attributes = append(attributes, "as_path")
+	}
+	if a.NextHop != b.NextHop {
+		attributes = append(attributes, "next_hop")
+	}
+	if a.LocalPref != b.LocalPref {
+		attributes = append(attributes, "local_pref")
+	}
+	if a.Med != b.Med {
+		attributes = append(attributes, "med")
+	}
+	if !reflect.DeepEqual(a.Communities, b.Communities) {
+		attributes = append(attributes, "communities")
+	}
+	if !reflect.DeepEqual(a.ExtCommunities, b.ExtCommunities) {
+		attributes = append(attributes, "ext_communities")
+	}
+	if !reflect.DeepEqual(a.LargeCommunities, b.LargeCommunities) {
+		attributes = append(attributes, "large_communities")
+	}
+
+	return attributes
+}
+
+// Compare the routes of two route servers and
+// get the differences per neighbour
+func compareRoutesIndex(a, b RoutesComparisonIndex) []api.NeighbourRoutesDiff {
+	asns := []int{}
+	for asn, _ := range a {
+		asns = append(asns, asn)
+	}
+	for asn, _ := range b {
+		if _, ok := a[asn]; !ok {
+			asns = append(asns, asn)
+		}
+	}
+	sort.Ints(asns)
+
+	results := []api.NeighbourRoutesDiff{}
+	for _, asn := range asns {
+		diff := api.NeighbourRoutesDiff{
+			Asn:              asn,
+			OnlyA:            []string{},
+			OnlyB:            []string{},
+			StateDiffers:     []api.RouteStateDiff{},
+			AttributesDiffer: []api.RouteAttributesDiff{},
+		}
+
+		routesA := a[asn]
+		routesB := b[asn]
+
+		for prefix, routeA := range routesA {
+			routeB, ok := routesB[prefix]
+			if !ok {
+				diff.OnlyA = append(diff.OnlyA, prefix)
+				continue
+			}
+
+			if routeA.State != routeB.State {
+				diff.StateDiffers = append(diff.StateDiffers, api.RouteStateDiff{
+					Prefix: prefix,
+					StateA: routeA.State,
+					StateB: routeB.State,
+				})
+			}
+
+			attributes := routeAttributesDiff(routeA.Route.Bgp, routeB.Route.Bgp)
+			if len(attributes) > 0 {
+				diff.AttributesDiffer = append(diff.AttributesDiffer, api.RouteAttributesDiff{
+					Prefix:     prefix,
+					Attributes: attributes,
+				})
+			}
+		}
+
+		for prefix, _ := range routesB {
+			if _, ok := routesA[prefix]; !ok {
+				diff.OnlyB = append(diff.OnlyB, prefix)
+			}
+		}
+
+		if len(diff.OnlyA) == 0 && len(diff.OnlyB) == 0 &&
+			len(diff.StateDiffers) == 0 && len(diff.AttributesDiffer) == 0 {
+			continue
+		}
+
+		// Keep the result stable
+		sort.Strings(diff.OnlyA)
+		sort.Strings(diff.OnlyB)
+		sort.Slice(diff.StateDiffers, func(i, j int) bool {
+			return diff.StateDiffers[i].Prefix < diff.StateDiffers[j].Prefix
+		})
+		sort.Slice(diff.AttributesDiffer, func(i, j int) bool {
+			return diff.AttributesDiffer[i].Prefix < diff.AttributesDiffer[j].Prefix
+		})
+
+		results = append(results, diff)
+	}
+
+	return results
+}
+
+// Compare the routes of two sources
+func (self *RoutesStore) CompareAt(a, b int) api.RoutesComparison {
+	self.rwlock.RLock()
+	sourceA := self.configMap[a]
+	sourceB := self.configMap[b]
+	routesA := self.routesMap[a]
+	routesB := self.routesMap[b]
+	self.rwlock.RUnlock()
+
+	indexA := makeRoutesComparisonIndex(routesA, func(route api.Route) int {
+		return routeNeighbourAsn(a, route)
+	})
+	indexB := makeRoutesComparisonIndex(routesB, func(route api.Route) int {
+		return routeNeighbourAsn(b, route)
+	})
+
+	neighbours := compareRoutesIndex(indexA, indexB)
+
+	return api.RoutesComparison{
+		A: api.Routeserver{
+			Id:    sourceA.Id,
+			Name:  sourceA.Name,
+			Group: sourceA.Group,
+		},
+		B: api.Routeserver{
+			Id:    sourceB.Id,
+			Name:  sourceB.Name,
+			Group: sourceB.Group,
+		},
+		Consistent: len(neighbours) == 0,
+		Neighbours: neighbours,
+	}
+}
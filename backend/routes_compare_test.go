@@ -0,0 +1,96 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+func TestCompareRoutes(t *testing.T) {
+	route := func(neighbourId, network string, asPath ...int) api.Route {
+		return api.Route{
+			NeighbourId: neighbourId,
+			Network:     network,
+			Bgp: api.BgpInfo{
+				AsPath: asPath,
+			},
+		}
+	}
+
+	rs1 := api.RoutesResponse{
+		Imported: []api.Route{
+			route("ID1_AS2342", "10.23.0.0/16", 2342),
+			route("ID1_AS2342", "10.42.0.0/16", 2342),
+			route("ID1_AS2342", "10.99.0.0/16", 2342),
+			route("ID2_AS4224", "10.24.0.0/16", 4224),
+		},
+		Filtered: []api.Route{
+			route("ID1_AS2342", "10.66.0.0/16", 2342),
+		},
+	}
+	rs2 := api.RoutesResponse{
+		Imported: []api.Route{
+			route("ID7_AS2342", "10.23.0.0/16", 2342),
+			route("ID7_AS2342", "10.42.0.0/16", 2342, 65001),
+			route("ID7_AS2342", "10.66.0.0/16", 2342),
+			route("ID7_AS2342", "10.77.0.0/16", 2342),
+			route("ID8_AS4224", "10.24.0.0/16", 4224),
+		},
+	}
+
+	neighbourAsn := func(route api.Route) int {
+		return route.Bgp.AsPath[0]
+	}
+	diffs := compareRoutesIndex(
+		makeRoutesComparisonIndex(rs1, neighbourAsn),
+		makeRoutesComparisonIndex(rs2, neighbourAsn))
+
+	// AS4224 is consistent
+	if len(diffs) != 1 {
+		t.Fatal("Expected differences for one neighbour, got:", diffs)
+	}
+
+	diff := diffs[0]
+	if diff.Asn != 2342 {
+		t.Error("Expected AS2342, got:", diff.Asn)
+	}
+	if len(diff.OnlyA) != 1 || diff.OnlyA[0] != "10.99.0.0/16" {
+		t.Error("Unexpected prefixes only on a:", diff.OnlyA)
+	}
+	if len(diff.OnlyB) != 1 || diff.OnlyB[0] != "10.77.0.0/16" {
+		t.Error("Unexpected prefixes only on b:", diff.OnlyB)
+	}
+	if len(diff.StateDiffers) != 1 ||
+		diff.StateDiffers[0].Prefix != "10.66.0.0/16" ||
+		diff.StateDiffers[0].StateA != "filtered" {
+		t.Error("Unexpected state differences:", diff.StateDiffers)
+	}
+	if len(diff.AttributesDiffer) != 1 ||
+		diff.AttributesDiffer[0].Prefix != "10.42.0.0/16" ||
+		diff.AttributesDiffer[0].Attributes[0] != "as_path" {
+		t.Error("Unexpected attribute differences:", diff.AttributesDiffer)
+	}
+}
+
+func TestApiCompareRouteserversNotReady(t *testing.T) {
+	AliceRoutesStore = makeRoutesExportTestStore()
+	defer func() {
+		AliceRoutesStore = nil
+		AliceConfig = nil
+	}()
+
+	// Source 1 was not refreshed yet
+	req := httptest.NewRequest("GET", "/api/routeservers/compare?a=0&b=1", nil)
+	_, err := apiCompareRouteservers(req, nil)
+	if status, _ := apiErrorResponse(err); status != http.StatusServiceUnavailable {
+		t.Error("Expected 503, got:", status, err)
+	}
+
+	AliceRoutesStore.refreshMap[1] = time.Now()
+	if _, err := apiCompareRouteservers(req, nil); err != nil {
+		t.Error("Unexpected error:", err)
+	}
+}
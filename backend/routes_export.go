@@ -0,0 +1,245 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/ecix/alice-lg/backend/api"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Bulk export of the routes store
+//
+// All routes of a route server are streamed as NDJSON,
+// CSV or MRT TABLE_DUMP_V2. The ETag is derived from the
+// time of the last refresh of the store and the access tier.
+
+const EXPORT_FORMAT_MRT = "mrt"
+
+// Get all routes of a source and the
+// time of the last successful refresh
+func (self *RoutesStore) RoutesAt(sourceId int) (api.RoutesResponse, time.Time) {
+	self.rwlock.RLock()
+	defer self.rwlock.RUnlock()
+	return self.routesMap[sourceId], self.refreshMap[sourceId]
+}
+
+// Check if the access tier may use the export
+func validateExportAccess(req *http.Request) error {
+	tiers := AliceConfig.Export.Tiers
+	if len(tiers) == 0 {
+		return nil
+	}
+
+	access := requestAccess(req)
+	for _, tier := range tiers {
+		if tier == access.Tier.Name {
+			return nil
+		}
+	}
+
+	if access.Key == "" {
+		return NewUnauthorizedError("Api key required")
+	}
+	return NewForbiddenError(
+		"Access tier %s may not use the export", access.Tier.Name)
+}
+
+// Get the routes in the requested state: The MRT
+// export defaults to the imported routes.
+func selectExportRoutes(routes api.RoutesResponse, state, format string) ([]exportRoute, error) {
+	if state == "" && format == EXPORT_FORMAT_MRT {
+		state = "imported"
+	}
+
+	states := []struct {
+		state  string
+		routes []api.Route
+	}{
+		{"imported", routes.Imported},
+		{"filtered", routes.Filtered},
+		{"not_exported", routes.NotExported},
+	}
+
+	selected := []exportRoute{}
+	found := state == ""
+	for _, s := range states {
+		if state != "" && state != s.state {
+			continue
+		}
+		found = true
+		for _, route := range s.routes {
+			selected = append(selected, exportRoute{route, s.state})
+		}
+	}
+	if !found {
+		return nil, NewInvalidParamError("Unknown route state: %s", state)
+	}
+
+	return selected, nil
+}
+
+// Check the If-None-Match header, using
+// the weak comparison
+func etagMatches(req *http.Request, etag string) bool {
+	etag = strings.TrimPrefix(etag, "W/")
+	for _, value := range strings.Split(req.Header.Get("If-None-Match"), ",") {
+		value = strings.TrimPrefix(strings.TrimSpace(value), "W/")
+		if value == etag || value == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// Handle routes export
+func apiRoutesExport(
+	res http.ResponseWriter,
+	req *http.Request,
+	params httprouter.Params,
+) {
+	rsId, err := validateSourceId(params.ByName("id"))
+	if err != nil {
+		writeErrorResponse(res, err)
+		return
+	}
+	if err := validateExportAccess(req); err != nil {
+		writeErrorResponse(res, err)
+		return
+	}
+
+	query := req.URL.Query()
+	format := query.Get("format")
+	switch format {
+	case "":
+		format = EXPORT_FORMAT_NDJSON
+	case EXPORT_FORMAT_NDJSON, EXPORT_FORMAT_CSV, EXPORT_FORMAT_MRT:
+	default:
+		writeErrorResponse(res, NewInvalidParamError("Unknown format: %s", format))
+		return
+	}
+	state := query.Get("state")
+
+	routes, refresh := AliceRoutesStore.RoutesAt(rsId)
+	if refresh.IsZero() {
+		writeErrorResponse(res, NewNotReadyError(
+			"Routes of source %d are not available yet", rsId))
+		return
+	}
+
+	// Validate the state before checking the validators,
+	// an invalid request is never answered with a 304.
+	selected, err := selectExportRoutes(routes, state, format)
+	if err != nil {
+		writeErrorResponse(res, err)
+		return
+	}
+
+	// The representation depends on the access tier
+	gzipped := acceptsGzip(req)
+	validators := makeStoreValidators(responseCacheKey(req, format), refresh)
+	writeCacheHeaders(res, validators)
+	if notModified(req, validators) {
+		res.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	// Hide routes and details
+	if redactor := requestRedactor(req); redactor != nil {
+		visible := make([]exportRoute, 0, len(selected))
+		for _, route := range selected {
+			if redactor.HideRoute(route.Route) {
+				continue
+			}
+			route.Details = redactor.RedactDetails(route.Details)
+			visible = append(visible, route)
+		}
+		selected = visible
+	}
+
+	filename := fmt.Sprintf("routes-%d-%s.%s",
+		rsId, refresh.UTC().Format("20060102T150405Z"), format)
+	res.Header().Set("Content-Disposition",
+		fmt.Sprintf(`attachment; filename="%s"`, filename))
+
+	if format != EXPORT_FORMAT_MRT {
+		writeExport(res, req, format, makeRoutesExportTable(selected))
+		return
+	}
+
+	res.Header().Set("Content-Type", "application/octet-stream")
+	clearWriteDeadline(res)
+
+	var writer io.Writer = res
+	if gzipped {
+		res.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(res)
+		defer gz.Close()
+		writer = gz
+	}
+
+	source := AliceConfig.Sources[rsId]
+	if err := writeMrtExport(writer, source, refresh, selected); err != nil {
+		log.Println("Error while writing MRT export of", source.Name+":", err)
+	}
+}
+
+// Write the routes as MRT table dump
+func writeMrtExport(
+	w io.Writer,
+	source SourceConfig,
+	timestamp time.Time,
+	routes []exportRoute,
+) error {
+	mrt := NewMrtWriter(w, timestamp)
+
+	// Group routes by prefix and collect the peers
+	prefixes := []string{}
+	paths := make(map[string][]api.Route)
+	for _, route := range routes {
+		if _, ok := paths[route.Network]; !ok {
+			prefixes = append(prefixes, route.Network)
+		}
+		paths[route.Network] = append(paths[route.Network], route.Route)
+		mrt.AddPeer(route.NeighbourId, exportMrtPeer(source.Id, route.Route))
+	}
+
+	if err := mrt.WritePeerIndexTable(source.Name); err != nil {
+		return err
+	}
+	for _, prefix := range prefixes {
+		if err := mrt.WriteRib(prefix, paths[prefix]); err != nil {
+			return err // client is gone
+		}
+	}
+	return nil
+}
+
+// Get the peer of a route from the neighbours store,
+// fall back to the gateway and the first ASN of the path
+func exportMrtPeer(sourceId int, route api.Route) MrtPeer {
+	if AliceNeighboursStore != nil {
+		neighbour := AliceNeighboursStore.GetNeighbourAt(sourceId, route.NeighbourId)
+		if neighbour.Id != "" {
+			return MrtPeer{
+				Address: net.ParseIP(neighbour.Address),
+				Asn:     neighbour.Asn,
+			}
+		}
+	}
+
+	peer := MrtPeer{
+		Address: net.ParseIP(route.Gateway),
+	}
+	if len(route.Bgp.AsPath) > 0 {
+		peer.Asn = route.Bgp.AsPath[0]
+	}
+	return peer
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ecix/alice-lg/backend/api"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func makeRoutesExportTestStore() *RoutesStore {
+	config := &Config{
+		Sources: []SourceConfig{
+			SourceConfig{Id: 0, Name: "rs0.example.net"},
+			SourceConfig{Id: 1, Name: "rs1.example.net"},
+		},
+	}
+	AliceConfig = config
+
+	store := NewRoutesStore(config)
+	store.routesMap[0] = api.RoutesResponse{
+		Imported: []api.Route{
+			api.Route{NeighbourId: "ID1_AS2342", Network: "10.23.0.0/16"},
+			api.Route{NeighbourId: "ID2_AS23", Network: "10.23.0.0/16"},
+		},
+		Filtered: []api.Route{
+			api.Route{NeighbourId: "ID1_AS2342", Network: "192.168.0.0/16"},
+		},
+	}
+	store.refreshMap[0] = time.Unix(1500000000, 0)
+	return store
+}
+
+func requestRoutesExport(url string, header http.Header) *httptest.ResponseRecorder {
+	res := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", url, nil)
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	params := httprouter.Params{
+		httprouter.Param{Key: "id", Value: strings.Split(url, "/")[3]},
+	}
+	guard(RATE_LIMIT_EXPENSIVE, apiRoutesExport)(res, req, params)
+	return res
+}
+
+func TestApiRoutesExport(t *testing.T) {
+	AliceRoutesStore = makeRoutesExportTestStore()
+	defer func() {
+		AliceRoutesStore = nil
+		AliceConfig = nil
+	}()
+
+	res := requestRoutesExport("/api/routeservers/0/export", nil)
+	lines := strings.Split(strings.TrimSpace(res.Body.String()), "\n")
+	if res.Code != http.StatusOK || len(lines) != 3 {
+		t.Fatal("Expected 3 routes, got:", res.Code, lines)
+	}
+	if !strings.Contains(lines[2], `"state":"filtered"`) {
+		t.Error("Expected filtered route:", lines[2])
+	}
+
+	// Not modified
+	etag := res.Header().Get("ETag")
+	res = requestRoutesExport("/api/routeservers/0/export",
+		http.Header{"If-None-Match": []string{etag}})
+	if res.Code != http.StatusNotModified {
+		t.Error("Expected 304, got:", res.Code)
+	}
+
+	// The MRT export defaults to the imported routes
+	res = requestRoutesExport("/api/routeservers/0/export?format=mrt", nil)
+	records := readMrtTestRecords(t, res.Body.Bytes())
+	if len(records) != 2 {
+		t.Error("Expected peer index table and one RIB entry, got:", len(records))
+	}
+	if res.Header().Get("ETag") == etag {
+		t.Error("Expected ETag to depend on the format")
+	}
+
+	res = requestRoutesExport("/api/routeservers/0/export?state=rejected", nil)
+	if res.Code != http.StatusBadRequest {
+		t.Error("Expected 400, got:", res.Code)
+	}
+	res = requestRoutesExport("/api/routeservers/0/export?state=rejected",
+		http.Header{"If-None-Match": []string{"*"}})
+	if res.Code != http.StatusBadRequest {
+		t.Error("Expected 400 for conditional request, got:", res.Code)
+	}
+
+	// The store was not refreshed yet
+	res = requestRoutesExport("/api/routeservers/1/export", nil)
+	if res.Code != http.StatusServiceUnavailable || res.Header().Get("Retry-After") == "" {
+		t.Error("Expected 503 with retry, got:", res.Code)
+	}
+}
+
+func TestApiRoutesExportAccess(t *testing.T) {
+	AliceRoutesStore = makeRoutesExportTestStore()
+	AliceConfig.Export.Tiers = []string{"staff"}
+	AliceAccessKeys = NewAccessKeys(makeAccessConfig())
+	defer func() {
+		AliceRoutesStore = nil
+		AliceConfig = nil
+		AliceAccessKeys = nil
+	}()
+
+	expected := []struct {
+		key    string
+		status int
+	}{
+		{"", http.StatusUnauthorized},
+		{"partner-key", http.StatusForbidden},
+		{"staff-key", http.StatusOK},
+	}
+
+	for _, e := range expected {
+		header := http.Header{}
+		if e.key != "" {
+			header.Set(API_KEY_HEADER, e.key)
+		}
+		res := requestRoutesExport("/api/routeservers/0/export", header)
+		if res.Code != e.status {
+			t.Error("Expected status", e.status, "for key", e.key, "got:", res.Code)
+		}
+	}
+}
+
+func TestApiRoutesExportCacheHeaders(t *testing.T) {
+	AliceRoutesStore = makeRoutesExportTestStore()
+	AliceAccessKeys = NewAccessKeys(makeAccessConfig())
+	defer func() {
+		AliceRoutesStore = nil
+		AliceConfig = nil
+		AliceAccessKeys = nil
+	}()
+
+	res := requestRoutesExport("/api/routeservers/0/export", nil)
+	if !strings.HasPrefix(res.Header().Get("Cache-Control"), "private") ||
+		!strings.Contains(res.Header().Get("Vary"), API_KEY_HEADER) {
+		t.Error("Unexpected cache headers:", res.Header())
+	}
+	etag := res.Header().Get("ETag")
+
+	// The export of another tier is not matched
+	header := http.Header{"If-None-Match": []string{etag}}
+	header.Set(API_KEY_HEADER, "staff-key")
+	res = requestRoutesExport("/api/routeservers/0/export", header)
+	if res.Code != http.StatusOK || res.Header().Get("ETag") == etag {
+		t.Error("Expected ETag to depend on the access tier, got:", res.Code)
+	}
+}
@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"log"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -14,6 +16,9 @@ type RoutesStore struct {
 	statusMap map[int]StoreStatus
 	configMap map[int]SourceConfig
 
+	// Time of the last successful refresh
+	refreshMap map[int]time.Time
+
 	rwlock *sync.RWMutex
 }
 
@@ -39,20 +44,22 @@ func NewRoutesStore(config *Config) *RoutesStore {
 		statusMap: statusMap,
 		configMap: configMap,
 
+		refreshMap: make(map[int]time.Time),
+
 		rwlock: &sync.RWMutex{},
 	}
 	return store
 }
 
-func (self *RoutesStore) Start() {
+func (self *RoutesStore) Start(ctx context.Context) {
 	log.Println("Starting local routes store")
-	go self.init()
+	go self.init(ctx)
 }
 
 // Service initialization
-func (self *RoutesStore) init() {
+func (self *RoutesStore) init(ctx context.Context) {
 	// Initial refresh
-	self.update()
+	self.update(ctx)
 
 	// Initial stats
 	self.Stats().Log()
@@ -60,13 +67,15 @@ func (self *RoutesStore) init() {
 	// Periodically update store
 	for {
 		// TODO: Add config option
-		time.Sleep(5 * time.Minute)
-		self.update()
+		if !waitInterval(ctx, 5*time.Minute) {
+			return
+		}
+		self.update(ctx)
 	}
 }
 
 // Update all routes
-func (self *RoutesStore) update() {
+func (self *RoutesStore) update(ctx context.Context) {
 	for sourceId, _ := range self.routesMap {
 		source := self.configMap[sourceId].getInstance()
 
@@ -82,7 +91,7 @@ func (self *RoutesStore) update() {
 		}
 		self.rwlock.Unlock()
 
-		routes, err := source.AllRoutes()
+		routes, err := source.AllRoutes(ctx)
 		if err != nil {
 			self.rwlock.Lock()
 			self.statusMap[sourceId] = StoreStatus{
@@ -95,15 +104,27 @@ func (self *RoutesStore) update() {
 			continue
 		}
 
+		// Validate and enrich routes
+		annotateRoutesResponse(sourceId, &routes)
+
 		self.rwlock.Lock()
+		previous := self.routesMap[sourceId]
+		refresh := time.Now()
 		// Update data
 		self.routesMap[sourceId] = routes
+		self.refreshMap[sourceId] = refresh
 		// Update state
 		self.statusMap[sourceId] = StoreStatus{
-			LastRefresh: time.Now(),
+			LastRefresh: refresh,
 			State:       STATE_READY,
 		}
 		self.rwlock.Unlock()
+
+		// Notify about the refresh
+		if AliceEvents != nil {
+			AliceEvents.PublishRoutesRefreshed(
+				self.configMap[sourceId], previous, routes)
+		}
 	}
 }
 
@@ -148,6 +169,23 @@ func (self *RoutesStore) Stats() RoutesStoreStats {
 	return storeStats
 }
 
+// Count RPKI invalid imported routes per neighbour
+func (self *RoutesStore) RpkiInvalidCountsAt(sourceId int) map[string]int {
+	counts := make(map[string]int)
+
+	self.rwlock.RLock()
+	routes := self.routesMap[sourceId]
+	self.rwlock.RUnlock()
+
+	for _, route := range routes.Imported {
+		if route.Rpki == api.RPKI_INVALID {
+			counts[route.NeighbourId] += 1
+		}
+	}
+
+	return counts
+}
+
 // Lookup routes transform
 func routeToLookupRoute(source SourceConfig, state string, route api.Route) api.LookupRoute {
 
@@ -162,8 +200,9 @@ func routeToLookupRoute(source SourceConfig, state string, route api.Route) api.
 		Neighbour:   neighbour,
 
 		Routeserver: api.Routeserver{
-			Id:   source.Id,
-			Name: source.Name,
+			Id:    source.Id,
+			Name:  source.Name,
+			Group: source.Group,
 		},
 
 		State: state,
@@ -175,6 +214,12 @@ func routeToLookupRoute(source SourceConfig, state string, route api.Route) api.
 		Bgp:       route.Bgp,
 		Age:       route.Age,
 		Type:      route.Type,
+		Primary:   route.Primary,
+
+		Rpki:            route.Rpki,
+		Irr:             route.Irr,
+		CommunityLabels: route.CommunityLabels,
+		Warnings:        route.Warnings,
 	}
 
 	return lookup
@@ -217,6 +262,37 @@ func filterRoutesByNeighbourIds(
 	return results
 }
 
+func filterBogonRoutes(
+	source SourceConfig,
+	routes []api.Route,
+	detector *BogonDetector,
+	state string,
+) []api.LookupRoute {
+
+	results := []api.LookupRoute{}
+	for _, route := range routes {
+		if len(detector.Check(route)) > 0 {
+			lookup := routeToLookupRoute(source, state, route)
+			results = append(results, lookup)
+		}
+	}
+	return results
+}
+
+func filterLookupRoutesByRpkiState(
+	routes []api.LookupRoute,
+	state string,
+) []api.LookupRoute {
+
+	results := []api.LookupRoute{}
+	for _, route := range routes {
+		if route.Rpki == state {
+			results = append(results, route)
+		}
+	}
+	return results
+}
+
 // Single RS lookup by neighbour id
 func (self *RoutesStore) LookupNeighboursPrefixesAt(
 	sourceId int,
@@ -333,3 +409,47 @@ func (self *RoutesStore) LookupPrefixForNeighbours(
 
 	return result
 }
+
+// Find imported bogon routes on all route servers
+func (self *RoutesStore) LookupBogons(
+	detector *BogonDetector,
+) []api.LookupRoute {
+	result := []api.LookupRoute{}
+
+	self.rwlock.RLock()
+	routesMap := make(map[int]api.RoutesResponse)
+	for sourceId, routes := range self.routesMap {
+		routesMap[sourceId] = routes
+	}
+	self.rwlock.RUnlock()
+
+	for sourceId, routes := range routesMap {
+		bogons := filterBogonRoutes(
+			self.configMap[sourceId],
+			routes.Imported,
+			detector,
+			"imported")
+		result = append(result, bogons...)
+	}
+
+	sortLookupRoutes(result)
+
+	return result
+}
+
+// Keep the order of lookup results stable for pagination:
+// The routes are collected from the sources concurrently.
+func sortLookupRoutes(routes []api.LookupRoute) {
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Routeserver.Id != routes[j].Routeserver.Id {
+			return routes[i].Routeserver.Id < routes[j].Routeserver.Id
+		}
+		if routes[i].Network != routes[j].Network {
+			return routes[i].Network < routes[j].Network
+		}
+		if routes[i].NeighbourId != routes[j].NeighbourId {
+			return routes[i].NeighbourId < routes[j].NeighbourId
+		}
+		return routes[i].Id < routes[j].Id
+	})
+}
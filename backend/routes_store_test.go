@@ -0,0 +1,37 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+func TestSortLookupRoutes(t *testing.T) {
+	routes := []api.LookupRoute{
+		{Id: "2", Network: "10.23.0.0/16", NeighbourId: "ID1_AS2342",
+			Routeserver: api.Routeserver{Id: 0}},
+		{Id: "1", Network: "10.42.0.0/16", NeighbourId: "ID1_AS2342",
+			Routeserver: api.Routeserver{Id: 1}},
+		{Id: "1", Network: "10.23.0.0/16", NeighbourId: "ID2_AS23",
+			Routeserver: api.Routeserver{Id: 0}},
+		{Id: "1", Network: "10.23.0.0/16", NeighbourId: "ID1_AS2342",
+			Routeserver: api.Routeserver{Id: 0}},
+	}
+
+	sortLookupRoutes(routes)
+
+	order := []string{}
+	for _, route := range routes {
+		order = append(order, route.NeighbourId+"/"+route.Id)
+	}
+	expected := []string{"ID1_AS2342/1", "ID1_AS2342/2", "ID2_AS23/1", "ID1_AS2342/1"}
+	for i, key := range expected {
+		if order[i] != key {
+			t.Error("Unexpected order:", order)
+			break
+		}
+	}
+	if routes[3].Routeserver.Id != 1 {
+		t.Error("Expected routes of the second routeserver last")
+	}
+}
@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+// RPKI origin validation
+//
+// Validated ROA Payloads are loaded from a local file
+// in the JSON export format shared by rpki-client,
+// Routinator and OctoRPKI:
+//
+//    {"roas": [{"asn": "AS13335", "prefix": "1.1.1.0/24",
+//               "maxLength": 24, "ta": "apnic"}, ...]}
+//
+
+type Vrp struct {
+	Asn       int
+	Network   *net.IPNet
+	MaxLength int
+}
+
+// The index maps the (masked) network of a VRP
+// to all VRPs for this network
+type VrpsIndex map[string][]Vrp
+
+type vrpsFileEntry struct {
+	Asn       interface{} `json:"asn"` // "AS123" or 123
+	Prefix    string      `json:"prefix"`
+	MaxLength int         `json:"maxLength"`
+}
+
+type vrpsFile struct {
+	Roas []vrpsFileEntry `json:"roas"`
+}
+
+type RpkiValidator struct {
+	config RpkiConfig
+
+	vrps      VrpsIndex
+	vrpsCount int
+	reloader  *FileReloader
+
+	rwlock *sync.RWMutex
+}
+
+func NewRpkiValidator(config RpkiConfig) *RpkiValidator {
+	validator := &RpkiValidator{
+		config: config,
+		vrps:   make(VrpsIndex),
+
+		rwlock: &sync.RWMutex{},
+	}
+	validator.reloader = NewFileReloader("RPKI VRPs",
+		[]string{config.VrpsFile}, config.RefreshInterval, validator.load)
+	return validator
+}
+
+func (self *RpkiValidator) Start(ctx context.Context) {
+	log.Println("Starting RPKI validator using:", self.config.VrpsFile)
+
+	// Load the VRPs before the stores start to fetch routes,
+	// so the first refresh can already be annotated.
+	self.reloader.Start(ctx)
+
+	// Initial logging
+	self.Stats().Log()
+}
+
+// Load the VRPs file
+func (self *RpkiValidator) load() error {
+	payload, err := ioutil.ReadFile(self.config.VrpsFile)
+	if err != nil {
+		return err
+	}
+
+	vrps, count, err := parseVrps(payload)
+	if err != nil {
+		return err
+	}
+
+	self.rwlock.Lock()
+	self.vrps = vrps
+	self.vrpsCount = count
+	self.rwlock.Unlock()
+
+	return nil
+}
+
+// Validate a route origin for a given prefix
+// as described in RFC 6811
+func (self *RpkiValidator) ValidateOrigin(prefix string, origin int) string {
+	ip, network, err := net.ParseCIDR(prefix)
+	if err != nil {
+		return api.RPKI_NOT_FOUND
+	}
+
+	length, bits := network.Mask.Size()
+	if ip.To4() != nil {
+		ip = ip.To4()
+	}
+
+	self.rwlock.RLock()
+	defer self.rwlock.RUnlock()
+
+	covered := false
+	for l := length; l >= 0; l-- {
+		mask := net.CIDRMask(l, bits)
+		key := (&net.IPNet{IP: ip.Mask(mask), Mask: mask}).String()
+
+		for _, vrp := range self.vrps[key] {
+			covered = true
+
+			// AS0 VRPs can only make a route invalid (RFC 6483),
+			// AS0 is never a valid origin (RFC 7607).
+			if vrp.Asn == 0 || origin == 0 {
+				continue
+			}
+			if vrp.Asn == origin && length <= vrp.MaxLength {
+				return api.RPKI_VALID
+			}
+		}
+	}
+
+	if covered {
+		return api.RPKI_INVALID
+	}
+
+	return api.RPKI_NOT_FOUND
+}
+
+// Validate a route: The origin is the last ASN
+// in the AS path.
+func (self *RpkiValidator) Validate(route api.Route) string {
+	origin := 0 // AS0 is never a valid origin
+	if len(route.Bgp.AsPath) > 0 {
+		origin = route.Bgp.AsPath[len(route.Bgp.AsPath)-1]
+	}
+	return self.ValidateOrigin(route.Network, origin)
+}
+
+// Set the RPKI state on a list of routes
+func (self *RpkiValidator) AnnotateRoutes(routes []api.Route) {
+	for i, route := range routes {
+		routes[i].Rpki = self.Validate(route)
+	}
+}
+
+// Build some stats for monitoring
+func (self *RpkiValidator) Stats() RpkiValidatorStats {
+	status := self.reloader.Status()
+
+	self.rwlock.RLock()
+	defer self.rwlock.RUnlock()
+
+	lastError := ""
+	if status.LastError != nil {
+		lastError = status.LastError.Error()
+	}
+
+	return RpkiValidatorStats{
+		File:      self.config.VrpsFile,
+		State:     stateToString(status.State),
+		Vrps:      self.vrpsCount,
+		UpdatedAt: status.LastRefresh,
+		LastError: lastError,
+	}
+}
+
+// Parse the ASN of a VRP: Exports differ in
+// providing either "AS123" or plain numbers.
+func parseVrpAsn(value interface{}) (int, error) {
+	switch asn := value.(type) {
+	case float64:
+		return int(asn), nil
+	case string:
+		asn = strings.TrimPrefix(strings.ToUpper(asn), "AS")
+		return strconv.Atoi(asn)
+	}
+	return 0, fmt.Errorf("Invalid ASN in VRP: %v", value)
+}
+
+// Parse VRPs json export and build index
+func parseVrps(payload []byte) (VrpsIndex, int, error) {
+	file := vrpsFile{}
+	err := json.Unmarshal(payload, &file)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	index := make(VrpsIndex)
+	count := 0
+	for _, entry := range file.Roas {
+		asn, err := parseVrpAsn(entry.Asn)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		_, network, err := net.ParseCIDR(entry.Prefix)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		// Without a max length, only the prefix itself is covered
+		maxLength := entry.MaxLength
+		if maxLength == 0 {
+			maxLength, _ = network.Mask.Size()
+		}
+
+		key := network.String()
+		index[key] = append(index[key], Vrp{
+			Asn:       asn,
+			Network:   network,
+			MaxLength: maxLength,
+		})
+		count += 1
+	}
+
+	return index, count, nil
+}
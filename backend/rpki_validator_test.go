@@ -0,0 +1,123 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+const VRPS_FILE_CONTENT = `
+{"metadata": {"generated": 1508316000},
+ "roas": [
+  {"asn": "AS13335", "prefix": "1.1.1.0/24", "maxLength": 24, "ta": "apnic"},
+  {"asn": 31078, "prefix": "193.200.0.0/16", "maxLength": 24, "ta": "ripe"},
+  {"asn": "AS65001", "prefix": "2001:db8::/32", "maxLength": 48, "ta": "ripe"},
+  {"asn": "AS0", "prefix": "198.51.100.0/24", "maxLength": 24, "ta": "ripe"}
+ ]}`
+
+func makeRpkiValidator(t *testing.T) *RpkiValidator {
+	validator := NewRpkiValidator(RpkiConfig{})
+	vrps, count, err := parseVrps([]byte(VRPS_FILE_CONTENT))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 4 {
+		t.Error("Expected 4 VRPs, got:", count)
+	}
+	validator.vrps = vrps
+	return validator
+}
+
+func TestParseVrpAsn(t *testing.T) {
+	expected := []struct {
+		value interface{}
+		asn   int
+	}{
+		{"AS13335", 13335},
+		{"as13335", 13335},
+		{"13335", 13335},
+		{float64(13335), 13335},
+	}
+
+	for _, e := range expected {
+		asn, err := parseVrpAsn(e.value)
+		if err != nil {
+			t.Error(err)
+		}
+		if asn != e.asn {
+			t.Error("Expected", e.value, "to be parsed as", e.asn, "not:", asn)
+		}
+	}
+
+	_, err := parseVrpAsn(nil)
+	if err == nil {
+		t.Error("Expected error for missing ASN")
+	}
+}
+
+func TestRpkiValidateOrigin(t *testing.T) {
+	validator := makeRpkiValidator(t)
+
+	expected := []struct {
+		prefix string
+		origin int
+		state  string
+	}{
+		{"1.1.1.0/24", 13335, api.RPKI_VALID},
+		{"1.1.1.0/24", 23, api.RPKI_INVALID},
+		{"1.1.1.0/25", 13335, api.RPKI_INVALID}, // too specific
+		{"193.200.230.0/24", 31078, api.RPKI_VALID},
+		{"193.200.0.0/16", 31078, api.RPKI_VALID},
+		{"193.0.0.0/8", 31078, api.RPKI_NOT_FOUND},
+		{"2001:db8:23::/48", 65001, api.RPKI_VALID},
+		{"2001:db8:23::/56", 65001, api.RPKI_INVALID},
+		{"2001:db9::/32", 65001, api.RPKI_NOT_FOUND},
+		{"10.0.0.0/8", 65001, api.RPKI_NOT_FOUND},
+		{"not a prefix", 65001, api.RPKI_NOT_FOUND},
+		{"198.51.100.0/24", 0, api.RPKI_INVALID}, // AS0 VRP
+		{"198.51.100.0/24", 64500, api.RPKI_INVALID},
+		{"1.1.1.0/24", 0, api.RPKI_INVALID}, // empty AS path
+	}
+
+	for _, e := range expected {
+		state := validator.ValidateOrigin(e.prefix, e.origin)
+		if state != e.state {
+			t.Error("Expected", e.prefix, "AS", e.origin,
+				"to be", e.state, "not:", state)
+		}
+	}
+}
+
+func TestRpkiAnnotateRoutes(t *testing.T) {
+	validator := makeRpkiValidator(t)
+
+	routes := []api.Route{
+		api.Route{
+			Network: "193.200.230.0/24",
+			Bgp: api.BgpInfo{
+				AsPath: []int{31078},
+			},
+		},
+		api.Route{
+			Network: "1.1.1.0/24",
+			Bgp: api.BgpInfo{
+				AsPath: []int{31078, 201785},
+			},
+		},
+		api.Route{
+			Network: "1.1.1.0/24",
+		},
+	}
+
+	validator.AnnotateRoutes(routes)
+
+	if routes[0].Rpki != api.RPKI_VALID {
+		t.Error("Expected first route to be valid, not:", routes[0].Rpki)
+	}
+	if routes[1].Rpki != api.RPKI_INVALID {
+		t.Error("Expected second route to be invalid, not:", routes[1].Rpki)
+	}
+	if routes[2].Rpki != api.RPKI_INVALID {
+		t.Error("Expected route without path to be invalid, not:", routes[2].Rpki)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+// Store snapshots
+//
+// On shutdown the routes and neighbours of the stores are
+// written to the snapshot file, which is restored on start.
+// Lookups are available before the first refresh completes.
+// Sources are identified by name, as ids follow the order
+// in the config.
+
+type routesSnapshot struct {
+	RefreshedAt time.Time          `json:"refreshed_at"`
+	Routes      api.RoutesResponse `json:"routes"`
+}
+
+type neighboursSnapshot struct {
+	RefreshedAt time.Time       `json:"refreshed_at"`
+	Neighbours  NeighboursIndex `json:"neighbours"`
+}
+
+type storeSnapshot struct {
+	CreatedAt  time.Time                     `json:"created_at"`
+	Routes     map[string]routesSnapshot     `json:"routes"`
+	Neighbours map[string]neighboursSnapshot `json:"neighbours"`
+}
+
+// Get the routes of all refreshed sources
+func (self *RoutesStore) Snapshot() map[string]routesSnapshot {
+	self.rwlock.RLock()
+	defer self.rwlock.RUnlock()
+
+	snapshots := make(map[string]routesSnapshot)
+	for sourceId, refresh := range self.refreshMap {
+		snapshots[self.configMap[sourceId].Name] = routesSnapshot{
+			RefreshedAt: refresh,
+			Routes:      self.routesMap[sourceId],
+		}
+	}
+	return snapshots
+}
+
+// Restore the routes of sources not refreshed yet
+func (self *RoutesStore) Restore(snapshots map[string]routesSnapshot) int {
+	self.rwlock.Lock()
+	defer self.rwlock.Unlock()
+
+	restored := 0
+	for sourceId, config := range self.configMap {
+		snapshot, ok := snapshots[config.Name]
+		if !ok || !self.refreshMap[sourceId].IsZero() {
+			continue
+		}
+		self.routesMap[sourceId] = snapshot.Routes
+		self.refreshMap[sourceId] = snapshot.RefreshedAt
+		self.statusMap[sourceId] = StoreStatus{
+			LastRefresh: snapshot.RefreshedAt,
+			State:       STATE_READY,
+		}
+		restored++
+	}
+	return restored
+}
+
+func (self *NeighboursStore) Snapshot() map[string]neighboursSnapshot {
+	self.rwlock.RLock()
+	defer self.rwlock.RUnlock()
+
+	snapshots := make(map[string]neighboursSnapshot)
+	for sourceId, refresh := range self.refreshMap {
+		snapshots[self.configMap[sourceId].Name] = neighboursSnapshot{
+			RefreshedAt: refresh,
+			Neighbours:  self.neighboursMap[sourceId],
+		}
+	}
+	return snapshots
+}
+
+func (self *NeighboursStore) Restore(snapshots map[string]neighboursSnapshot) int {
+	self.rwlock.Lock()
+	defer self.rwlock.Unlock()
+
+	restored := 0
+	for sourceId, config := range self.configMap {
+		snapshot, ok := snapshots[config.Name]
+		if !ok || !self.refreshMap[sourceId].IsZero() {
+			continue
+		}
+		self.neighboursMap[sourceId] = snapshot.Neighbours
+		self.refreshMap[sourceId] = snapshot.RefreshedAt
+		self.statusMap[sourceId] = StoreStatus{
+			LastRefresh: snapshot.RefreshedAt,
+			State:       STATE_READY,
+		}
+		restored++
+	}
+	return restored
+}
+
+// Write the stores to the snapshot file. The snapshot
+// is replaced only if it was written completely.
+func writeStoreSnapshot(filename string) error {
+	snapshot := storeSnapshot{
+		CreatedAt:  time.Now(),
+		Routes:     AliceRoutesStore.Snapshot(),
+		Neighbours: AliceNeighboursStore.Snapshot(),
+	}
+
+	tmpFilename := filename + ".tmp"
+	file, err := os.Create(tmpFilename)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFilename)
+
+	gz := gzip.NewWriter(file)
+	err = json.NewEncoder(gz).Encode(snapshot)
+	if err == nil {
+		err = gz.Close()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	log.Println("Wrote store snapshot:", filename)
+	return os.Rename(tmpFilename, filename)
+}
+
+// Restore the stores from the snapshot file, if present
+func restoreStoreSnapshot(filename string) error {
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+
+	snapshot := storeSnapshot{}
+	if err := json.NewDecoder(gz).Decode(&snapshot); err != nil {
+		return err
+	}
+
+	routes := AliceRoutesStore.Restore(snapshot.Routes)
+	neighbours := AliceNeighboursStore.Restore(snapshot.Neighbours)
+	log.Println("Restored store snapshot from", snapshot.CreatedAt,
+		"- routes:", routes, "neighbours:", neighbours, "sources")
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+func TestStoreSnapshot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "alice-snapshot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "stores.json.gz")
+
+	AliceRoutesStore = makeRoutesExportTestStore()
+	AliceNeighboursStore = NewNeighboursStore(AliceConfig)
+	AliceNeighboursStore.neighboursMap[1] = NeighboursIndex{
+		"ID2_AS23": api.Neighbour{Id: "ID2_AS23", Asn: 23},
+	}
+	AliceNeighboursStore.refreshMap[1] = time.Unix(1500000060, 0)
+	defer func() {
+		AliceRoutesStore = nil
+		AliceNeighboursStore = nil
+		AliceConfig = nil
+	}()
+
+	// A missing snapshot is not an error
+	if err := restoreStoreSnapshot(filename); err != nil {
+		t.Error("Unexpected error:", err)
+	}
+
+	if err := writeStoreSnapshot(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	// Restore to new stores
+	AliceRoutesStore = NewRoutesStore(AliceConfig)
+	AliceNeighboursStore = NewNeighboursStore(AliceConfig)
+	if err := restoreStoreSnapshot(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	routes, refresh := AliceRoutesStore.RoutesAt(0)
+	if len(routes.Imported) != 2 || len(routes.Filtered) != 1 {
+		t.Error("Expected routes to be restored, got:", routes)
+	}
+	if !refresh.Equal(time.Unix(1500000000, 0)) {
+		t.Error("Unexpected refresh time:", refresh)
+	}
+	if AliceRoutesStore.statusMap[0].State != STATE_READY {
+		t.Error("Expected store to be ready")
+	}
+	if _, refresh := AliceRoutesStore.RoutesAt(1); !refresh.IsZero() {
+		t.Error("Expected source without refresh to be skipped")
+	}
+
+	neighbour := AliceNeighboursStore.GetNeighbourAt(1, "ID2_AS23")
+	if neighbour.Asn != 23 {
+		t.Error("Expected neighbour to be restored, got:", neighbour)
+	}
+}
@@ -3,43 +3,80 @@ package birdwatcher
 // Http Birdwatcher Client
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"time"
+
+	"github.com/ecix/alice-lg/backend/sources"
 )
 
 type ClientResponse map[string]interface{}
 
 type Client struct {
 	Api string
+
+	sourceId int
+	http     *http.Client
 }
 
 func NewClient(api string) *Client {
 	client := &Client{
-		Api: api,
+		Api:  api,
+		http: &http.Client{},
 	}
 	return client
 }
 
-// Make API request, parse response and return map or error
-func (self *Client) GetJson(endpoint string) (ClientResponse, error) {
-	res, err := http.Get(self.Api + endpoint)
+// Make a client for a source, requests fail after the timeout
+func NewSourceClient(sourceId int, api string, timeout time.Duration) *Client {
+	client := NewClient(api)
+	client.sourceId = sourceId
+	client.http.Timeout = timeout
+	return client
+}
+
+// Classify a failed request
+func (self *Client) requestError(err error) error {
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		return sources.NewTimeoutError(self.sourceId, err)
+	}
+	return sources.NewUnavailableError(self.sourceId, err)
+}
+
+// Make API request, parse response and return map or error.
+// The request is canceled with the context.
+func (self *Client) GetJson(ctx context.Context, endpoint string) (ClientResponse, error) {
+	req, err := http.NewRequest("GET", self.Api+endpoint, nil)
+	if err != nil {
+		return ClientResponse{}, sources.NewUnavailableError(self.sourceId, err)
+	}
+
+	res, err := self.http.Do(req.WithContext(ctx))
 	if err != nil {
-		return ClientResponse{}, err
+		return ClientResponse{}, self.requestError(err)
 	}
 
 	// Read body
 	defer res.Body.Close()
 	payload, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		return ClientResponse{}, err
+		return ClientResponse{}, self.requestError(err)
+	}
+
+	if res.StatusCode != http.StatusOK {
+		err = fmt.Errorf("Unexpected response status: %s", res.Status)
+		return ClientResponse{}, sources.NewBadResponseError(self.sourceId, err)
 	}
 
 	// Decode json payload
 	result := make(ClientResponse)
 	err = json.Unmarshal(payload, &result)
 	if err != nil {
-		return ClientResponse{}, err
+		return ClientResponse{}, sources.NewBadResponseError(self.sourceId, err)
 	}
 
 	return result, nil
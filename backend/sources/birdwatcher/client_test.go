@@ -0,0 +1,60 @@
+package birdwatcher
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ecix/alice-lg/backend/sources"
+)
+
+func Test_ClientErrors(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(res http.ResponseWriter, req *http.Request) {
+			switch req.URL.Path {
+			case "/status":
+				res.Write([]byte(`{"api": {}}`))
+			case "/broken":
+				res.Write([]byte(`{"api":`))
+			case "/slow":
+				time.Sleep(100 * time.Millisecond)
+				res.Write([]byte(`{}`))
+			default:
+				http.NotFound(res, req)
+			}
+		}))
+	defer server.Close()
+
+	client := NewSourceClient(23, server.URL, 50*time.Millisecond)
+
+	if _, err := client.GetJson(context.Background(), "/status"); err != nil {
+		t.Error(err)
+	}
+
+	expected := map[string]int{
+		"/broken":  sources.ERROR_BAD_RESPONSE,
+		"/missing": sources.ERROR_BAD_RESPONSE,
+		"/slow":    sources.ERROR_TIMEOUT,
+	}
+	for endpoint, kind := range expected {
+		_, err := client.GetJson(context.Background(), endpoint)
+		sourceErr, ok := err.(*sources.SourceError)
+		if !ok {
+			t.Error("Expected source error for", endpoint, "got:", err)
+			continue
+		}
+		if sourceErr.Kind != kind || sourceErr.SourceId != 23 {
+			t.Error("Unexpected error for", endpoint, ":", sourceErr.Kind, sourceErr.SourceId)
+		}
+	}
+
+	// Connection refused
+	unavailable := NewSourceClient(23, "http://127.0.0.1:1", time.Second)
+	_, err := unavailable.GetJson(context.Background(), "/status")
+	if sourceErr, ok := err.(*sources.SourceError); !ok ||
+		sourceErr.Kind != sources.ERROR_UNAVAILABLE {
+		t.Error("Expected unavailable error, got:", err)
+	}
+}
@@ -1,5 +1,9 @@
 package birdwatcher
 
+import (
+	"time"
+)
+
 type Config struct {
 	Id   int
 	Name string
@@ -10,4 +14,6 @@ type Config struct {
 	ServerTimeShort string `ini:"servertime_short"`
 	ServerTimeExt   string `ini:"servertime_ext"`
 	ShowLastReboot  bool   `ini:"show_last_reboot"`
+
+	Timeout time.Duration `ini:"timeout"`
 }
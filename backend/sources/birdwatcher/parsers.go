@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/ecix/alice-lg/backend/api"
@@ -106,6 +107,9 @@ func parseNeighbours(bird ClientResponse, config Config) ([]api.Neighbour, error
 		uptime := parseRelativeServerTime(protocol["state_changed"], config)
 		lastError := mustString(protocol["last_error"], "")
 
+		routesReceived := mustInt(routes["imported"], 0)
+		importLimit, routeLimitUsed := parseRouteLimit(protocol, routesReceived)
+
 		neighbour := api.Neighbour{
 			Id: protocolId,
 
@@ -114,7 +118,7 @@ func parseNeighbours(bird ClientResponse, config Config) ([]api.Neighbour, error
 			State:       mustString(protocol["state"], "unknown"),
 			Description: mustString(protocol["description"], "no description"),
 
-			RoutesReceived:  mustInt(routes["imported"], 0),
+			RoutesReceived:  routesReceived,
 			RoutesExported:  mustInt(routes["exported"], 0),
 			RoutesFiltered:  mustInt(routes["filtered"], 0),
 			RoutesPreferred: mustInt(routes["preferred"], 0),
@@ -122,6 +126,12 @@ func parseNeighbours(bird ClientResponse, config Config) ([]api.Neighbour, error
 			Uptime:    uptime,
 			LastError: lastError,
 
+			ImportLimit:      importLimit,
+			RouteLimitUsed:   routeLimitUsed,
+			ImportLimitUsage: importLimitUsage(routeLimitUsed, importLimit),
+
+			RouteChanges: parseRouteChanges(protocol["route_changes"]),
+
 			Details: protocol,
 		}
 
@@ -133,6 +143,62 @@ func parseNeighbours(bird ClientResponse, config Config) ([]api.Neighbour, error
 	return neighbours, nil
 }
 
+// Get the import limit of a protocol and the number of
+// routes counted against it. The route limit is reported
+// as "<used>/<limit>", e.g. "139/16000".
+func parseRouteLimit(protocol map[string]interface{}, routesReceived int) (int, int) {
+	limit := mustInt(protocol["import_limit"], 0)
+	used := routesReceived
+
+	routeLimit := strings.Split(mustString(protocol["route_limit"], ""), "/")
+	if len(routeLimit) == 2 {
+		if value, err := strconv.Atoi(strings.TrimSpace(routeLimit[0])); err == nil {
+			used = value
+		}
+		if value, err := strconv.Atoi(strings.TrimSpace(routeLimit[1])); err == nil && limit == 0 {
+			limit = value
+		}
+	}
+
+	return limit, used
+}
+
+// Parse the route update counters of a protocol
+func parseRouteChanges(data interface{}) *api.RouteChanges {
+	changes, ok := data.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	counters := func(key string) api.RouteChangesCounters {
+		// Counters may be missing, e.g. export withdraws
+		// are not always reported as a map.
+		values, _ := changes[key].(map[string]interface{})
+		return api.RouteChangesCounters{
+			Received: mustInt(values["received"], 0),
+			Rejected: mustInt(values["rejected"], 0),
+			Filtered: mustInt(values["filtered"], 0),
+			Ignored:  mustInt(values["ignored"], 0),
+			Accepted: mustInt(values["accepted"], 0),
+		}
+	}
+
+	return &api.RouteChanges{
+		ImportUpdates:   counters("import_updates"),
+		ImportWithdraws: counters("import_withdraws"),
+		ExportUpdates:   counters("export_updates"),
+		ExportWithdraws: counters("export_withdraws"),
+	}
+}
+
+// Calculate the percentage of the import limit in use
+func importLimitUsage(used, limit int) float64 {
+	if limit <= 0 {
+		return 0
+	}
+	return float64(used) * 100.0 / float64(limit)
+}
+
 // Parse route bgp info
 func parseRouteBgpInfo(data interface{}) api.BgpInfo {
 	bgpData, ok := data.(map[string]interface{})
@@ -143,6 +209,7 @@ func parseRouteBgpInfo(data interface{}) api.BgpInfo {
 
 	asPath := parseIntList(bgpData["as_path"])
 	communities := parseBgpCommunities(bgpData["communities"])
+	extCommunities := parseExtBgpCommunities(bgpData["ext_communities"])
 	largeCommunities := parseBgpCommunities(bgpData["large_communities"])
 
 	localPref, _ := strconv.Atoi(mustString(bgpData["local_pref"], "0"))
@@ -155,6 +222,7 @@ func parseRouteBgpInfo(data interface{}) api.BgpInfo {
 		LocalPref:        localPref,
 		Med:              med,
 		Communities:      communities,
+		ExtCommunities:   extCommunities,
 		LargeCommunities: largeCommunities,
 	}
 	return bgp
@@ -181,6 +249,36 @@ func parseBgpCommunities(data interface{}) []api.Community {
 	return communities
 }
 
+// Extract extended bgp communities from response:
+// Values are either strings (type) or numbers.
+func parseExtBgpCommunities(data interface{}) []api.ExtCommunity {
+	communities := []api.ExtCommunity{}
+
+	ldata, ok := data.([]interface{})
+	if !ok { // We don't have any
+		return []api.ExtCommunity{}
+	}
+
+	for _, c := range ldata {
+		cdata, ok := c.([]interface{})
+		if !ok {
+			continue
+		}
+		community := api.ExtCommunity{}
+		for _, cinfo := range cdata {
+			switch value := cinfo.(type) {
+			case string:
+				community = append(community, value)
+			case float64:
+				community = append(community, strconv.Itoa(int(value)))
+			}
+		}
+		communities = append(communities, community)
+	}
+
+	return communities
+}
+
 // Assert string, provide default
 func mustString(value interface{}, fallback string) string {
 	sval, ok := value.(string)
@@ -190,6 +288,15 @@ func mustString(value interface{}, fallback string) string {
 	return sval
 }
 
+// Assert bool or return fallback
+func mustBool(value interface{}, fallback bool) bool {
+	bval, ok := value.(bool)
+	if !ok {
+		return fallback
+	}
+	return bval
+}
+
 // Assert list of strings
 func mustStringList(data interface{}) []string {
 	list := []string{}
@@ -247,6 +354,7 @@ func parseRoutesData(birdRoutes []interface{}, config Config) api.Routes {
 			Age:       age,
 			Type:      rtype,
 			Bgp:       bgpInfo,
+			Primary:   mustBool(rdata["primary"], false),
 
 			Details: rdata,
 		}
@@ -299,5 +407,13 @@ func parseRoutesDump(bird ClientResponse, config Config) (api.RoutesResponse, er
 	sort.Sort(filtered)
 	result.Filtered = filtered
 
+	// Routes not exported, if provided by the dump
+	notExportedRoutes, ok := bird["not_exported"].([]interface{})
+	if ok {
+		notExported := parseRoutesData(notExportedRoutes, config)
+		sort.Sort(notExported)
+		result.NotExported = notExported
+	}
+
 	return result, nil
 }
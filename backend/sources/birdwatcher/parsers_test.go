@@ -88,5 +88,80 @@ func Test_RoutesParsing(t *testing.T) {
 		t.Error("Expected parsed routes to be 1, not:", len(routes))
 	}
 
+	if !routes[0].Primary {
+		t.Error("Expected route to be the primary path")
+	}
+
 	// TODO: addo more tests
 }
+
+func Test_ExtCommunitiesParsing(t *testing.T) {
+	bird := parseTestResponse(`{"ext_communities": [["rt", "65000", 1], ["ro", 42, "23"]]}`)
+
+	communities := parseExtBgpCommunities(bird["ext_communities"])
+	if len(communities) != 2 {
+		t.Error("Expected 2 extended communities, got:", len(communities))
+	}
+
+	expected := []string{"rt", "65000", "1"}
+	for i, value := range expected {
+		if communities[0][i] != value {
+			t.Error("Expected", value, "got:", communities[0][i])
+		}
+	}
+}
+
+func Test_NeighboursImportLimitParsing(t *testing.T) {
+	config := Config{Timezone: "UTC"}
+	bird := parseTestResponse(API_RESPONSE_NEIGHBOURS)
+
+	neighbours, err := parseNeighbours(bird, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// AS25074: "route_limit": "139/16000"
+	neighbour := neighbours[0]
+	if neighbour.ImportLimit != 16000 {
+		t.Error("Expected import limit 16000, got:", neighbour.ImportLimit)
+	}
+	if neighbour.RouteLimitUsed != 139 {
+		t.Error("Expected 139 routes counted, got:", neighbour.RouteLimitUsed)
+	}
+	if neighbour.ImportLimitUsage < 0.86 || neighbour.ImportLimitUsage > 0.87 {
+		t.Error("Unexpected import limit usage:", neighbour.ImportLimitUsage)
+	}
+
+	// Without a limit, there is no usage
+	limit, used := parseRouteLimit(map[string]interface{}{}, 23)
+	if limit != 0 || used != 23 || importLimitUsage(used, limit) != 0 {
+		t.Error("Unexpected limit for protocol without limit:", limit, used)
+	}
+}
+
+func Test_NeighboursRouteChangesParsing(t *testing.T) {
+	config := Config{Timezone: "UTC"}
+	bird := parseTestResponse(API_RESPONSE_NEIGHBOURS)
+
+	neighbours, err := parseNeighbours(bird, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := neighbours[0].RouteChanges
+	if changes == nil {
+		t.Fatal("Expected route changes to be parsed")
+	}
+	if changes.ImportUpdates.Received != 13503 ||
+		changes.ImportUpdates.Filtered != 388 {
+		t.Error("Unexpected import updates:", changes.ImportUpdates)
+	}
+	if changes.ExportUpdates.Accepted != 200340 {
+		t.Error("Unexpected export updates:", changes.ExportUpdates)
+	}
+
+	// Export withdraws are not reported as a map
+	if changes.ExportWithdraws.Received != 0 {
+		t.Error("Unexpected export withdraws:", changes.ExportWithdraws)
+	}
+}
@@ -1,7 +1,11 @@
 package birdwatcher
 
 import (
+	"context"
+	"net/url"
+
 	"github.com/ecix/alice-lg/backend/api"
+	"github.com/ecix/alice-lg/backend/sources"
 )
 
 type Birdwatcher struct {
@@ -10,7 +14,7 @@ type Birdwatcher struct {
 }
 
 func NewBirdwatcher(config Config) *Birdwatcher {
-	client := NewClient(config.Api)
+	client := NewSourceClient(config.Id, config.Api, config.Timeout)
 
 	birdwatcher := &Birdwatcher{
 		config: config,
@@ -19,20 +23,25 @@ func NewBirdwatcher(config Config) *Birdwatcher {
 	return birdwatcher
 }
 
-func (self *Birdwatcher) Status() (api.StatusResponse, error) {
-	bird, err := self.client.GetJson("/status")
+// Parsing failed: The upstream response is not as expected
+func (self *Birdwatcher) badResponse(err error) error {
+	return sources.NewBadResponseError(self.config.Id, err)
+}
+
+func (self *Birdwatcher) Status(ctx context.Context) (api.StatusResponse, error) {
+	bird, err := self.client.GetJson(ctx, "/status")
 	if err != nil {
 		return api.StatusResponse{}, err
 	}
 
 	apiStatus, err := parseApiStatus(bird, self.config)
 	if err != nil {
-		return api.StatusResponse{}, err
+		return api.StatusResponse{}, self.badResponse(err)
 	}
 
 	birdStatus, err := parseBirdwatcherStatus(bird, self.config)
 	if err != nil {
-		return api.StatusResponse{}, err
+		return api.StatusResponse{}, self.badResponse(err)
 	}
 
 	response := api.StatusResponse{
@@ -44,20 +53,20 @@ func (self *Birdwatcher) Status() (api.StatusResponse, error) {
 }
 
 // Get bird BGP protocols
-func (self *Birdwatcher) Neighbours() (api.NeighboursResponse, error) {
-	bird, err := self.client.GetJson("/protocols/bgp")
+func (self *Birdwatcher) Neighbours(ctx context.Context) (api.NeighboursResponse, error) {
+	bird, err := self.client.GetJson(ctx, "/protocols/bgp")
 	if err != nil {
 		return api.NeighboursResponse{}, err
 	}
 
 	apiStatus, err := parseApiStatus(bird, self.config)
 	if err != nil {
-		return api.NeighboursResponse{}, err
+		return api.NeighboursResponse{}, self.badResponse(err)
 	}
 
 	neighbours, err := parseNeighbours(bird, self.config)
 	if err != nil {
-		return api.NeighboursResponse{}, err
+		return api.NeighboursResponse{}, self.badResponse(err)
 	}
 
 	return api.NeighboursResponse{
@@ -67,9 +76,9 @@ func (self *Birdwatcher) Neighbours() (api.NeighboursResponse, error) {
 }
 
 // Get filtered and exported routes
-func (self *Birdwatcher) Routes(neighbourId string) (api.RoutesResponse, error) {
+func (self *Birdwatcher) Routes(ctx context.Context, neighbourId string) (api.RoutesResponse, error) {
 	// Exported
-	bird, err := self.client.GetJson("/routes/protocol/" + neighbourId)
+	bird, err := self.client.GetJson(ctx, "/routes/protocol/"+url.PathEscape(neighbourId))
 	if err != nil {
 		return api.RoutesResponse{}, err
 	}
@@ -77,27 +86,27 @@ func (self *Birdwatcher) Routes(neighbourId string) (api.RoutesResponse, error)
 	// Use api status from first request
 	apiStatus, err := parseApiStatus(bird, self.config)
 	if err != nil {
-		return api.RoutesResponse{}, err
+		return api.RoutesResponse{}, self.badResponse(err)
 	}
 
 	imported, err := parseRoutes(bird, self.config)
 	if err != nil {
-		return api.RoutesResponse{}, err
+		return api.RoutesResponse{}, self.badResponse(err)
 	}
 
 	// Filtered
-	bird, err = self.client.GetJson("/routes/filtered/" + neighbourId)
+	bird, err = self.client.GetJson(ctx, "/routes/filtered/"+url.PathEscape(neighbourId))
 	if err != nil {
 		return api.RoutesResponse{}, err
 	}
 
 	filtered, err := parseRoutes(bird, self.config)
 	if err != nil {
-		return api.RoutesResponse{}, err
+		return api.RoutesResponse{}, self.badResponse(err)
 	}
 
 	// Optional: NoExport
-	bird, _ = self.client.GetJson("/routes/noexport/" + neighbourId)
+	bird, _ = self.client.GetJson(ctx, "/routes/noexport/"+url.PathEscape(neighbourId))
 	noexport, err := parseRoutes(bird, self.config)
 
 	return api.RoutesResponse{
@@ -108,8 +117,31 @@ func (self *Birdwatcher) Routes(neighbourId string) (api.RoutesResponse, error)
 	}, nil
 }
 
+// Get the routes not exported to a neighbour
+func (self *Birdwatcher) RoutesNotExported(ctx context.Context, neighbourId string) (api.RoutesResponse, error) {
+	bird, err := self.client.GetJson(ctx, "/routes/noexport/"+url.PathEscape(neighbourId))
+	if err != nil {
+		return api.RoutesResponse{}, err
+	}
+
+	apiStatus, err := parseApiStatus(bird, self.config)
+	if err != nil {
+		return api.RoutesResponse{}, self.badResponse(err)
+	}
+
+	noexport, err := parseRoutes(bird, self.config)
+	if err != nil {
+		return api.RoutesResponse{}, self.badResponse(err)
+	}
+
+	return api.RoutesResponse{
+		Api:         apiStatus,
+		NotExported: noexport,
+	}, nil
+}
+
 // Make routes lookup
-func (self *Birdwatcher) LookupPrefix(prefix string) (api.RoutesLookupResponse, error) {
+func (self *Birdwatcher) LookupPrefix(ctx context.Context, prefix string) (api.RoutesLookupResponse, error) {
 	// Get RS info
 	rs := api.Routeserver{
 		Id:   self.config.Id,
@@ -117,7 +149,7 @@ func (self *Birdwatcher) LookupPrefix(prefix string) (api.RoutesLookupResponse,
 	}
 
 	// Query prefix on RS
-	bird, err := self.client.GetJson("/routes/prefix?prefix=" + prefix)
+	bird, err := self.client.GetJson(ctx, "/routes/prefix?prefix="+url.QueryEscape(prefix))
 	if err != nil {
 		return api.RoutesLookupResponse{}, err
 	}
@@ -125,7 +157,7 @@ func (self *Birdwatcher) LookupPrefix(prefix string) (api.RoutesLookupResponse,
 	// Parse API status
 	apiStatus, err := parseApiStatus(bird, self.config)
 	if err != nil {
-		return api.RoutesLookupResponse{}, err
+		return api.RoutesLookupResponse{}, self.badResponse(err)
 	}
 
 	// Parse routes
@@ -150,6 +182,7 @@ func (self *Birdwatcher) LookupPrefix(prefix string) (api.RoutesLookupResponse,
 			Bgp:       src.Bgp,
 			Age:       src.Age,
 			Type:      src.Type,
+			Primary:   src.Primary,
 
 			Details: src.Details,
 		}
@@ -164,11 +197,14 @@ func (self *Birdwatcher) LookupPrefix(prefix string) (api.RoutesLookupResponse,
 	return response, nil
 }
 
-func (self *Birdwatcher) AllRoutes() (api.RoutesResponse, error) {
-	bird, err := self.client.GetJson("/routes/dump")
+func (self *Birdwatcher) AllRoutes(ctx context.Context) (api.RoutesResponse, error) {
+	bird, err := self.client.GetJson(ctx, "/routes/dump")
 	if err != nil {
 		return api.RoutesResponse{}, err
 	}
 	result, err := parseRoutesDump(bird, self.config)
-	return result, err
+	if err != nil {
+		return result, self.badResponse(err)
+	}
+	return result, nil
 }
@@ -0,0 +1,34 @@
+package sources
+
+// Source errors
+//
+// Sources classify failures of the upstream api,
+// so clients can tell them apart from invalid requests.
+
+const (
+	ERROR_UNAVAILABLE  = iota // connection failed
+	ERROR_TIMEOUT             // no response in time
+	ERROR_BAD_RESPONSE        // unexpected status or payload
+)
+
+type SourceError struct {
+	Kind     int
+	SourceId int
+	Err      error
+}
+
+func (self *SourceError) Error() string {
+	return self.Err.Error()
+}
+
+func NewUnavailableError(sourceId int, err error) error {
+	return &SourceError{Kind: ERROR_UNAVAILABLE, SourceId: sourceId, Err: err}
+}
+
+func NewTimeoutError(sourceId int, err error) error {
+	return &SourceError{Kind: ERROR_TIMEOUT, SourceId: sourceId, Err: err}
+}
+
+func NewBadResponseError(sourceId int, err error) error {
+	return &SourceError{Kind: ERROR_BAD_RESPONSE, SourceId: sourceId, Err: err}
+}
@@ -1,12 +1,16 @@
 package sources
 
 import (
+	"context"
+
 	"github.com/ecix/alice-lg/backend/api"
 )
 
+// Requests to the source are canceled with the context
 type Source interface {
-	Status() (api.StatusResponse, error)
-	Neighbours() (api.NeighboursResponse, error)
-	Routes(neighbourId string) (api.RoutesResponse, error)
-	AllRoutes() (api.RoutesResponse, error)
+	Status(ctx context.Context) (api.StatusResponse, error)
+	Neighbours(ctx context.Context) (api.NeighboursResponse, error)
+	Routes(ctx context.Context, neighbourId string) (api.RoutesResponse, error)
+	RoutesNotExported(ctx context.Context, neighbourId string) (api.RoutesResponse, error)
+	AllRoutes(ctx context.Context) (api.RoutesResponse, error)
 }
@@ -7,6 +7,10 @@ type AppStatus struct {
 	Version    string               `json:"version"`
 	Routes     RoutesStoreStats     `json:"routes"`
 	Neighbours NeighboursStoreStats `json:"neighbours"`
+	Rpki       *RpkiValidatorStats  `json:"rpki,omitempty"`
+	Irr        *IrrDatabaseStats    `json:"irr,omitempty"`
+
+	AsnMetadata *AsnMetadataStats `json:"asn_metadata,omitempty"`
 }
 
 // Get application status, perform health checks
@@ -22,10 +26,32 @@ func NewAppStatus() (*AppStatus, error) {
 		neighboursStatus = AliceNeighboursStore.Stats()
 	}
 
+	var rpkiStatus *RpkiValidatorStats
+	if AliceRpkiValidator != nil {
+		stats := AliceRpkiValidator.Stats()
+		rpkiStatus = &stats
+	}
+
+	var irrStatus *IrrDatabaseStats
+	if AliceIrrDatabase != nil {
+		stats := AliceIrrDatabase.Stats()
+		irrStatus = &stats
+	}
+
+	var asnMetadataStatus *AsnMetadataStats
+	if AliceAsnMetadata != nil {
+		stats := AliceAsnMetadata.Stats()
+		asnMetadataStatus = &stats
+	}
+
 	status := &AppStatus{
 		Version:    version,
 		Routes:     routesStatus,
 		Neighbours: neighboursStatus,
+		Rpki:       rpkiStatus,
+		Irr:        irrStatus,
+
+		AsnMetadata: asnMetadataStatus,
 	}
 	return status, nil
 }
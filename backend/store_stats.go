@@ -55,10 +55,21 @@ type RouteServerNeighboursStats struct {
 	UpdatedAt  time.Time `json:"updated_at"`
 }
 
+type FlappingNeighbourStats struct {
+	RouteserverId int     `json:"routeserver_id"`
+	NeighbourId   string  `json:"neighbour_id"`
+	Asn           int     `json:"asn"`
+	Description   string  `json:"description"`
+	State         string  `json:"state"`
+	FlapCount     int     `json:"flap_count"`
+	FlapScore     float64 `json:"flap_score"`
+}
+
 type NeighboursStoreStats struct {
 	TotalNeighbours int `json:"total_neighbours"`
 
-	RouteServers []RouteServerNeighboursStats `json:"route_servers"`
+	RouteServers       []RouteServerNeighboursStats `json:"route_servers"`
+	FlappingNeighbours []FlappingNeighbourStats     `json:"flapping_neighbours"`
 }
 
 // Print stats
@@ -75,4 +86,66 @@ func (stats NeighboursStoreStats) Log() {
 		log.Println("        Neighbours:",
 			rs.Neighbours)
 	}
+
+	log.Println("    Flapping neighbours:",
+		len(stats.FlappingNeighbours))
+}
+
+// RPKI Validator
+
+type RpkiValidatorStats struct {
+	File      string    `json:"file"`
+	State     string    `json:"state"`
+	Vrps      int       `json:"vrps"`
+	UpdatedAt time.Time `json:"updated_at"`
+	LastError string    `json:"last_error"`
+}
+
+// Print stats
+func (stats RpkiValidatorStats) Log() {
+	log.Println("RPKI validator:")
+	log.Println("    File:", stats.File)
+	log.Println("    State:", stats.State)
+	log.Println("    UpdatedAt:", stats.UpdatedAt)
+	log.Println("    VRPs:", stats.Vrps)
+}
+
+// IRR Database
+
+type IrrDatabaseStats struct {
+	Files     []string  `json:"files"`
+	State     string    `json:"state"`
+	Routes    int       `json:"routes"`
+	AsSets    int       `json:"as_sets"`
+	UpdatedAt time.Time `json:"updated_at"`
+	LastError string    `json:"last_error"`
+}
+
+// Print stats
+func (stats IrrDatabaseStats) Log() {
+	log.Println("IRR database:")
+	log.Println("    Files:", stats.Files)
+	log.Println("    State:", stats.State)
+	log.Println("    UpdatedAt:", stats.UpdatedAt)
+	log.Println("    Routes:", stats.Routes, "AS-SETs:", stats.AsSets)
+}
+
+// ASN Metadata
+
+type AsnMetadataStats struct {
+	File      string    `json:"file"`
+	Format    string    `json:"format"`
+	State     string    `json:"state"`
+	Asns      int       `json:"asns"`
+	UpdatedAt time.Time `json:"updated_at"`
+	LastError string    `json:"last_error"`
+}
+
+// Print stats
+func (stats AsnMetadataStats) Log() {
+	log.Println("ASN metadata:")
+	log.Println("    File:", stats.File, "Format:", stats.Format)
+	log.Println("    State:", stats.State)
+	log.Println("    UpdatedAt:", stats.UpdatedAt)
+	log.Println("    ASNs:", stats.Asns)
 }
@@ -29,6 +29,18 @@ func MemberOf(list []string, key string) bool {
 	return false
 }
 
+/*
+ Check int array membership
+*/
+func memberOfInts(list []int, key int) bool {
+	for _, v := range list {
+		if v == key {
+			return true
+		}
+	}
+	return false
+}
+
 /*
  Check if something could be a prefix
 */
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+// Webhooks
+//
+// Events matching the rules of a webhook are POSTed
+// as JSON to the target url. When a secret is configured,
+// the payload is signed:
+//
+//    X-Alice-Signature: sha256=<hex encoded HMAC of the body>
+//
+// Failed deliveries are retried with exponential backoff.
+// Events are queued while a delivery is retried, events
+// exceeding the queue are dropped by the event bus.
+
+const WEBHOOK_QUEUE_SIZE = 1000
+
+type Webhook struct {
+	config WebhookConfig
+	client *http.Client
+
+	// Time to wait before the first retry,
+	// doubled with every attempt
+	backoff time.Duration
+}
+
+func NewWebhook(config WebhookConfig) *Webhook {
+	webhook := &Webhook{
+		config: config,
+		client: &http.Client{
+			Timeout: config.Timeout,
+		},
+		backoff: time.Second,
+	}
+	return webhook
+}
+
+// Subscribe to the event bus and deliver events
+// until the context is done
+func (self *Webhook) Start(ctx context.Context, bus *EventBus) {
+	log.Println("Starting webhook:", self.config.Name)
+
+	events := bus.Subscribe(WEBHOOK_QUEUE_SIZE)
+	go func() {
+		defer bus.Unsubscribe(events)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event := <-events:
+				if !self.Match(event) {
+					continue
+				}
+				err := self.Deliver(ctx, event)
+				if err != nil {
+					log.Println("Webhook", self.config.Name, "failed:", err)
+				}
+			}
+		}
+	}()
+}
+
+// Check if the event matches the rules of the webhook
+func (self *Webhook) Match(event api.Event) bool {
+	if len(self.config.Events) > 0 &&
+		!MemberOf(self.config.Events, event.Type) {
+		return false
+	}
+
+	if len(self.config.Routeservers) > 0 &&
+		!memberOfInts(self.config.Routeservers, event.Routeserver.Id) {
+		return false
+	}
+
+	if len(self.config.Asns) > 0 {
+		if event.Neighbour == nil ||
+			!memberOfInts(self.config.Asns, event.Neighbour.Asn) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Sign payload with the configured secret
+func (self *Webhook) Sign(payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(self.config.Secret))
+	mac.Write(payload)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Post event to the target, retry on failure
+func (self *Webhook) Deliver(ctx context.Context, event api.Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	backoff := self.backoff
+	for attempt := 0; ; attempt++ {
+		err = self.post(ctx, event, payload)
+		if err == nil || attempt >= self.config.Retries {
+			return err
+		}
+
+		if !waitInterval(ctx, backoff) {
+			return ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+func (self *Webhook) post(ctx context.Context, event api.Event, payload []byte) error {
+	req, err := http.NewRequest("POST", self.config.Url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Alice-Event", event.Type)
+	if self.config.Secret != "" {
+		req.Header.Set("X-Alice-Signature", self.Sign(payload))
+	}
+
+	res, err := self.client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return fmt.Errorf("Unexpected response status: %s", res.Status)
+	}
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ecix/alice-lg/backend/api"
+)
+
+func TestWebhookMatch(t *testing.T) {
+	webhook := NewWebhook(WebhookConfig{
+		Events: []string{EVENT_NEIGHBOUR_DOWN},
+		Asns:   []int{31078},
+	})
+
+	event := api.Event{
+		Type:      EVENT_NEIGHBOUR_DOWN,
+		Neighbour: &api.Neighbour{Asn: 31078},
+	}
+	if !webhook.Match(event) {
+		t.Error("Expected event to match")
+	}
+
+	event.Neighbour.Asn = 25074
+	if webhook.Match(event) {
+		t.Error("Expected event with other ASN not to match")
+	}
+
+	event = api.Event{Type: EVENT_ROUTES_REFRESHED}
+	if webhook.Match(event) {
+		t.Error("Expected other event type not to match")
+	}
+}
+
+func TestWebhookDeliver(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(
+		func(res http.ResponseWriter, req *http.Request) {
+			attempts += 1
+			if attempts < 2 {
+				http.Error(res, "try again", http.StatusServiceUnavailable)
+				return
+			}
+
+			payload, _ := ioutil.ReadAll(req.Body)
+			webhook := NewWebhook(WebhookConfig{Secret: "secret"})
+			if req.Header.Get("X-Alice-Signature") != webhook.Sign(payload) {
+				t.Error("Invalid signature")
+			}
+			if req.Header.Get("X-Alice-Event") != EVENT_NEIGHBOUR_UP {
+				t.Error("Unexpected event header")
+			}
+		}))
+	defer server.Close()
+
+	webhook := NewWebhook(WebhookConfig{
+		Url:     server.URL,
+		Secret:  "secret",
+		Retries: 2,
+	})
+	webhook.backoff = 0
+
+	err := webhook.Deliver(context.Background(), api.Event{Type: EVENT_NEIGHBOUR_UP})
+	if err != nil {
+		t.Error(err)
+	}
+	if attempts != 2 {
+		t.Error("Expected 2 attempts, got:", attempts)
+	}
+
+	// Give up after retries
+	webhook.config.Retries = 0
+	attempts = -10
+	err = webhook.Deliver(context.Background(), api.Event{Type: EVENT_NEIGHBOUR_UP})
+	if err == nil {
+		t.Error("Expected delivery to fail")
+	}
+}
+
+func TestWebhookStop(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(
+		func(res http.ResponseWriter, req *http.Request) {
+			http.Error(res, "try again", http.StatusServiceUnavailable)
+		}))
+	defer server.Close()
+
+	webhook := NewWebhook(WebhookConfig{
+		Url:     server.URL,
+		Retries: 3,
+	})
+	webhook.backoff = time.Hour
+
+	// Retries are canceled
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- webhook.Deliver(ctx, api.Event{Type: EVENT_NEIGHBOUR_UP})
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Error("Expected delivery to be canceled, got:", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected delivery to stop")
+	}
+
+	// The subscription ends with the context
+	bus := NewEventBus(EventsConfig{})
+	ctx, cancel = context.WithCancel(context.Background())
+	webhook.Start(ctx, bus)
+	cancel()
+	for i := 0; i < 100; i++ {
+		bus.rwlock.RLock()
+		subscribers := len(bus.subscribers)
+		bus.rwlock.RUnlock()
+		if subscribers == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Error("Expected webhook to unsubscribe")
+}